@@ -0,0 +1,47 @@
+// Package api holds the types and the constants shared by the HTTP server,
+// see the handler package, and its clients
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// HeaderKeyID, HeaderTimestamp and HeaderSignature carry the signature of
+	// a request, see HMACSignature
+	HeaderKeyID     = "X-Golden-Key-Id"
+	HeaderTimestamp = "X-Golden-Timestamp"
+	HeaderSignature = "X-Golden-Signature"
+)
+
+type JsonResponse struct {
+	// Reserved field to add some meta information to the API response
+	Meta interface{} `json:"meta"`
+	Data interface{} `json:"data"`
+}
+
+type JsonErrorResponse struct {
+	Error *ApiError `json:"error"`
+}
+
+type ApiError struct {
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Title, e.Status)
+}
+
+// HMACSignature returns the hex encoded HMAC-SHA256 of a request, computed
+// with secret over the method, the path and query, the Unix timestamp of the
+// signature and the SHA-256 of the body, each on its own line
+func HMACSignature(secret string, method string, requestURI string, timestamp string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestURI, timestamp, hex.EncodeToString(bodySum[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
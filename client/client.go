@@ -0,0 +1,233 @@
+// Package client is a Go client of the golden-sdk HTTP API. Its methods take
+// and return the input and output types of the service package, e.g.
+//
+//	c := client.New("http://localhost:8080", client.WithAuth(client.BearerToken(token)))
+//	projects, err := c.ListProjects(ctx)
+//
+// Error responses are returned as *api.ApiError. The requests which failed with
+// a 5xx status or a transport error are retried when their method is
+// idempotent, e.g. GET, the POST requests which start runs or provision
+// resources are sent once.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golden-sdk/api"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries = 2
+	defaultBackoff = 200 * time.Millisecond
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	auth       Authenticator
+	// retries is the number of retries of an idempotent request which failed
+	// with a 5xx status or a transport error
+	retries int
+	backoff time.Duration
+}
+
+type Option func(c *Client)
+
+// WithHTTPClient replaces http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAuth adds credentials to every request
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) { c.auth = auth }
+}
+
+// WithRetries sets the number of retries of the idempotent requests and the
+// delay before the first one, which doubles at each retry
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// New returns a client of the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Authenticator adds credentials to a request
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to Authenticator
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error { return f(req) }
+
+// BearerToken sends a token in the Authorization header
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKey sends a key in a header, e.g. X-API-Key
+func APIKey(header string, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// HMAC signs requests with a secret shared with the server, see
+// api.HMACSignature
+func HMAC(keyID string, secret string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		var body []byte
+		if req.GetBody != nil {
+			r, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			if body, err = io.ReadAll(r); err != nil {
+				return err
+			}
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(api.HeaderKeyID, keyID)
+		req.Header.Set(api.HeaderTimestamp, timestamp)
+		req.Header.Set(api.HeaderSignature, api.HMACSignature(secret, req.Method, req.URL.RequestURI(), timestamp, body))
+		return nil
+	})
+}
+
+// response is api.JsonResponse with the data left encoded
+type response struct {
+	Meta interface{}     `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+// do sends a request, retrying the idempotent ones on 5xx statuses and
+// transport errors, and returns the body of a successful response
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, contentType string, body []byte) ([]byte, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		b, retry, err := c.send(ctx, method, u, contentType, body)
+		if !retry || !idempotent(method) || attempt >= c.retries {
+			return b, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// idempotent tells whether sending a request of method twice has the effect of
+// sending it once, a POST may have been served when its response is lost
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (c *Client) send(ctx context.Context, method string, u string, contentType string, body []byte) ([]byte, bool, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return nil, false, fmt.Errorf("error when authenticating request: %v", err)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("error when calling %s: %v", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("error when reading response: %v", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, resp.StatusCode >= 500, decodeError(resp, b)
+	}
+	return b, false, nil
+}
+
+// decodeError returns the ApiError of a JsonErrorResponse, or an ApiError
+// with the HTTP status when the body is not one
+func decodeError(resp *http.Response, body []byte) error {
+	var errResp api.JsonErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
+		return errResp.Error
+	}
+	return &api.ApiError{Status: resp.StatusCode, Title: resp.Status}
+}
+
+// call sends input as JSON and decodes the data of the response into out
+func (c *Client) call(ctx context.Context, method string, path string, query url.Values, input interface{}, out interface{}) error {
+	var body []byte
+	if input != nil {
+		var err error
+		if body, err = json.Marshal(input); err != nil {
+			return fmt.Errorf("error when encoding request: %v", err)
+		}
+	}
+	b, err := c.do(ctx, method, path, query, "application/json", body)
+	if err != nil {
+		return err
+	}
+	return decodeData(b, out)
+}
+
+func decodeData(b []byte, out interface{}) error {
+	var resp response
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return fmt.Errorf("error when decoding response: %v", err)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("error when decoding response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/golden-sdk/api"
+	"github.com/golden-sdk/handler"
+	"github.com/golden-sdk/service"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	handler.SetClientProvider(service.NewFakeClientProvider())
+	os.Exit(m.Run())
+}
+
+func newServer(t *testing.T, wrap func(next http.Handler) http.Handler) *httptest.Server {
+	var h http.Handler = handler.NewRouter(handler.AllRoutes())
+	if wrap != nil {
+		h = wrap(h)
+	}
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := New(newServer(t, nil).URL)
+
+	project, err := c.CreateProject(ctx, &service.CreateProjectInput{
+		ID: "project-client",
+		Resources: []service.Resource{
+			{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "client-bucket"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Resources[0].ID != "client-bucket" {
+		t.Fatalf("Expected the resource ID to default to the bucket name, got %+v", project.Resources[0])
+	}
+	plan, err := c.CreateProjectResources(ctx, &service.CreateProjectResourcesInput{ProjectID: "project-client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Plan) != 1 || plan.Plan[0].Action != service.PlanActionCreate {
+		t.Fatalf("Expected the bucket to be created, got %+v", plan.Plan)
+	}
+	projects, err := c.ListProjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range projects {
+		found = found || p.ID == "project-client"
+	}
+	if !found {
+		t.Fatalf("Expected project-client to be listed, got %+v", projects)
+	}
+
+	if _, err := c.CreateWorkflow(ctx, &service.CreateWorkflowInput{ID: "workflow-client", ProjectID: "project-client"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateWorkflowTrigger(ctx, &service.CreateWorkflowTriggerInput{ID: "trigger-client", WorkflowID: "workflow-client"}); err != nil {
+		t.Fatal(err)
+	}
+	workflows, err := c.ListWorkflows(ctx, "project-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workflows) != 1 || workflows[0].ID != "workflow-client" {
+		t.Fatalf("Expected workflow-client, got %+v", workflows)
+	}
+	document, err := c.ExportWorkflow(ctx, "workflow-client", service.DefinitionFormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(document), "id: trigger-client") {
+		t.Fatalf("Expected the workflow definition, got %s", document)
+	}
+
+	definitions, err := service.ParseDefinitions(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.Apply(ctx, &service.ApplyInput{Definitions: definitions, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range out.Plan {
+		if change.Action != service.PlanActionNone {
+			t.Fatalf("Expected the exported workflow to be unchanged, got %+v", out.Plan)
+		}
+	}
+}
+
+func TestClientError(t *testing.T) {
+	c := New(newServer(t, nil).URL)
+	_, err := c.CreateProjectResources(context.Background(), &service.CreateProjectResourcesInput{ProjectID: "project-404"})
+	var apiErr *api.ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an ApiError, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadRequest || !strings.Contains(apiErr.Title, "project project-404 not found") {
+		t.Fatalf("Expected a bad request, got %+v", apiErr)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	// the first two calls fail with a 503
+	server := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	c := New(server.URL, WithRetries(1, time.Millisecond))
+	_, err := c.ListWorkflowTriggers(context.Background())
+	var apiErr *api.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected a 503 after one retry, got %v", err)
+	}
+
+	c = New(server.URL, WithRetries(2, time.Millisecond))
+	if _, err := c.ListWorkflowTriggers(context.Background()); err != nil {
+		t.Fatalf("Expected the request to succeed on the first retry, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("Expected 3 calls, got %d", calls)
+	}
+
+	// a POST is sent once, the server may have served it
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.RunWorkflow(context.Background(), &service.RunWorkflowInput{ID: "workflow-retry"})
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the 503 of the first call, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected the run to be requested once, got %d calls", calls)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	server := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	if _, err := New(server.URL).ListWorkflows(context.Background(), ""); err == nil {
+		t.Fatalf("Expected an unauthenticated request to fail")
+	}
+	if _, err := New(server.URL, WithAuth(APIKey("X-API-Key", "secret"))).ListWorkflows(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientHMAC(t *testing.T) {
+	auth := handler.NewHMACAuthenticator(map[string]string{"deploy": "shared-secret"})
+	server := httptest.NewServer(handler.NewRouter(handler.AllRoutes(), handler.WithAuthenticators(auth)))
+	defer server.Close()
+
+	c := New(server.URL, WithAuth(HMAC("deploy", "shared-secret")))
+	if _, err := c.CreateProject(context.Background(), &service.CreateProjectInput{ID: "project-hmac"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListWorkflows(context.Background(), "project-hmac"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(server.URL, WithAuth(HMAC("deploy", "wrong-secret"))).ListWorkflows(context.Background(), "")
+	var apiErr *api.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Fatalf("Expected a 401 with the wrong secret, got %v", err)
+	}
+}
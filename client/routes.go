@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golden-sdk/service"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateProject calls the CreateProject route and returns the stored project
+// definition, with the resource IDs defaulted
+func (c *Client) CreateProject(ctx context.Context, input *service.CreateProjectInput) (service.CreateProjectInput, error) {
+	var out service.CreateProjectInput
+	err := c.call(ctx, "POST", "/resourceManager/createProject", nil, input, &out)
+	return out, err
+}
+
+func (c *Client) CreateProjectResources(ctx context.Context, input *service.CreateProjectResourcesInput) (service.CreateProjectResourcesOutput, error) {
+	var out service.CreateProjectResourcesOutput
+	err := c.call(ctx, "POST", "/resourceManager/createProjectResources", nil, input, &out)
+	return out, err
+}
+
+func (c *Client) DestroyProjectResources(ctx context.Context, input *service.DestroyProjectResourcesInput) (service.DestroyProjectResourcesOutput, error) {
+	var out service.DestroyProjectResourcesOutput
+	err := c.call(ctx, "POST", "/resourceManager/destroyProjectResources", nil, input, &out)
+	return out, err
+}
+
+func (c *Client) ImportTemplate(ctx context.Context, input *service.ImportTemplateInput) (service.ImportTemplateOutput, error) {
+	var out service.ImportTemplateOutput
+	err := c.call(ctx, "POST", "/resourceManager/importTemplate", nil, input, &out)
+	return out, err
+}
+
+func (c *Client) ListProjects(ctx context.Context) ([]service.Project, error) {
+	b, err := c.do(ctx, "GET", "/resourceManager/listProjects", nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	// the projects are not wrapped in a JsonResponse
+	var projects []service.Project
+	if err := json.Unmarshal(b, &projects); err != nil {
+		return nil, fmt.Errorf("error when decoding response: %v", err)
+	}
+	return projects, nil
+}
+
+func (c *Client) CreateWorkflow(ctx context.Context, input *service.CreateWorkflowInput) (service.CreateWorkflowOutput, error) {
+	var out service.CreateWorkflowOutput
+	err := c.call(ctx, "POST", "/workflowManager/createWorkflow", nil, input, &out)
+	return out, err
+}
+
+// ListWorkflows lists all workflows, or the workflows of a project when
+// projectID is not empty
+func (c *Client) ListWorkflows(ctx context.Context, projectID string) ([]service.Workflow, error) {
+	query := url.Values{}
+	if projectID != "" {
+		query.Set("projectId", projectID)
+	}
+	var workflows []service.Workflow
+	err := c.call(ctx, "GET", "/workflowManager/listWorkflows", query, nil, &workflows)
+	return workflows, err
+}
+
+// ExportWorkflow returns the definition file of a workflow, format is
+// service.DefinitionFormatYAML or service.DefinitionFormatJSON
+func (c *Client) ExportWorkflow(ctx context.Context, workflowID string, format string) ([]byte, error) {
+	return c.do(ctx, "GET", "/workflowManager/exportWorkflow", url.Values{"id": {workflowID}, "format": {format}}, "", nil)
+}
+
+// ImportWorkflows sends a YAML or JSON document of workflow definitions
+func (c *Client) ImportWorkflows(ctx context.Context, document []byte) (service.ImportWorkflowsOutput, error) {
+	b, err := c.do(ctx, "POST", "/workflowManager/importWorkflows", nil, "application/yaml", document)
+	if err != nil {
+		return service.ImportWorkflowsOutput{}, err
+	}
+	var out service.ImportWorkflowsOutput
+	err = decodeData(b, &out)
+	return out, err
+}
+
+func (c *Client) CreateWorkflowTrigger(ctx context.Context, input *service.CreateWorkflowTriggerInput) (service.CreateWorkflowTriggerInput, error) {
+	var out service.CreateWorkflowTriggerInput
+	err := c.call(ctx, "POST", "/workflowTriggerManager/createTrigger", nil, input, &out)
+	return out, err
+}
+
+func (c *Client) ListWorkflowTriggers(ctx context.Context) ([]service.CreateWorkflowTriggerInput, error) {
+	var triggers []service.CreateWorkflowTriggerInput
+	err := c.call(ctx, "GET", "/workflowTriggerManager/listTriggers", nil, nil, &triggers)
+	return triggers, err
+}
+
+// PauseWorkflowTrigger deactivates a trigger and returns it
+func (c *Client) PauseWorkflowTrigger(ctx context.Context, triggerID string) (service.CreateWorkflowTriggerInput, error) {
+	var out service.CreateWorkflowTriggerInput
+	err := c.call(ctx, "POST", "/workflowTriggerManager/pauseTrigger", nil, &service.PauseWorkflowTriggerInput{ID: triggerID}, &out)
+	return out, err
+}
+
+// RunWorkflow starts a run of a workflow on the server, see GetWorkflowRun
+// for its progress
+func (c *Client) RunWorkflow(ctx context.Context, input *service.RunWorkflowInput) (service.WorkflowRun, error) {
+	var out service.WorkflowRun
+	err := c.call(ctx, "POST", "/workflowManager/runWorkflow", nil, input, &out)
+	return out, err
+}
+
+func (c *Client) GetWorkflowRun(ctx context.Context, runID string) (service.WorkflowRun, error) {
+	var out service.WorkflowRun
+	err := c.call(ctx, "GET", "/workflowManager/getRun", url.Values{"id": {runID}}, nil, &out)
+	return out, err
+}
+
+// GetWorkflowRunLogs returns the events of the steps of a run, oldest first
+func (c *Client) GetWorkflowRunLogs(ctx context.Context, runID string) ([]service.StepEvent, error) {
+	var events []service.StepEvent
+	err := c.call(ctx, "GET", "/workflowManager/getRunLogs", url.Values{"id": {runID}}, nil, &events)
+	return events, err
+}
+
+func (c *Client) CancelWorkflowRun(ctx context.Context, runID string) (service.WorkflowRun, error) {
+	var out service.WorkflowRun
+	err := c.call(ctx, "POST", "/workflowManager/cancelRun", nil, &service.CancelRunInput{RunID: runID}, &out)
+	return out, err
+}
+
+// Apply sends the definitions of input to the Apply route
+func (c *Client) Apply(ctx context.Context, input *service.ApplyInput) (service.ApplyOutput, error) {
+	document, err := definitionsDocument(input.Definitions)
+	if err != nil {
+		return service.ApplyOutput{}, err
+	}
+	query := url.Values{}
+	if input.DryRun {
+		query.Set("dryRun", "true")
+	}
+	if input.Prune {
+		query.Set("prune", "true")
+	}
+	b, err := c.do(ctx, "POST", "/definitions/apply", query, "application/yaml", document)
+	if err != nil {
+		return service.ApplyOutput{}, err
+	}
+	var out service.ApplyOutput
+	err = decodeData(b, &out)
+	return out, err
+}
+
+// ListAuditEntries returns the audit entries selected by query, oldest first
+func (c *Client) ListAuditEntries(ctx context.Context, query service.AuditQuery) ([]service.AuditEntry, error) {
+	var entries []service.AuditEntry
+	err := c.call(ctx, "GET", "/audit/listEntries", auditQueryValues(query), nil, &entries)
+	return entries, err
+}
+
+// ExportAuditEntries returns the audit entries selected by query as JSON lines
+func (c *Client) ExportAuditEntries(ctx context.Context, query service.AuditQuery) ([]byte, error) {
+	return c.do(ctx, "GET", "/audit/exportEntries", auditQueryValues(query), "", nil)
+}
+
+func auditQueryValues(q service.AuditQuery) url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{"subject": q.Subject, "route": q.Route, "target": q.Target, "outcome": q.Outcome} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	if !q.Since.IsZero() {
+		values.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		values.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// definitionsDocument encodes definitions as a stream of JSON documents
+// with their kind
+func definitionsDocument(definitions service.Definitions) ([]byte, error) {
+	documents := make([]string, 0)
+	add := func(kind string, definition interface{}) error {
+		b, err := json.Marshal(definition)
+		if err != nil {
+			return err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return err
+		}
+		fields["kind"] = kind
+		b, err = json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, string(b))
+		return nil
+	}
+	for _, p := range definitions.Projects {
+		if err := add(service.DefinitionKindProject, p); err != nil {
+			return nil, fmt.Errorf("error when encoding project %s: %v", p.ID, err)
+		}
+	}
+	for _, w := range definitions.Workflows {
+		if err := add(service.DefinitionKindWorkflow, w); err != nil {
+			return nil, fmt.Errorf("error when encoding workflow %s: %v", w.ID, err)
+		}
+	}
+	for _, t := range definitions.Triggers {
+		if err := add(service.DefinitionKindTrigger, t); err != nil {
+			return nil, fmt.Errorf("error when encoding trigger %s: %v", t.ID, err)
+		}
+	}
+	return []byte(strings.Join(documents, "\n---\n")), nil
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/golden-sdk/service"
+	"os"
+	"strings"
+	"time"
+)
+
+func projectCreate(c *cli, flags *flag.FlagSet, args []string) error {
+	file := flags.String("f", "", "project definition file, - for the standard input")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	input := service.CreateProjectInput{}
+	if err := c.readDocument(*file, &input); err != nil {
+		return err
+	}
+	project, err := c.client.CreateProject(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(project, func(t *table) {
+		t.row("PROJECT", "RESOURCES", "STATUS")
+		t.row(project.ID, len(project.Resources), "created")
+	})
+}
+
+func projectList(c *cli, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	projects, err := c.client.ListProjects(c.ctx)
+	if err != nil {
+		return err
+	}
+	sortByID(projects, func(p service.Project) string { return p.ID })
+	return c.print(projects, func(t *table) {
+		t.row("ID", "NAME", "STATUS", "RESOURCES", "PROVISIONED")
+		for _, p := range projects {
+			provisioned := 0
+			for _, s := range p.States {
+				if s.Status == service.ResourceStatusProvisioned {
+					provisioned++
+				}
+			}
+			t.row(p.ID, p.Name, p.Status, len(p.Resources), provisioned)
+		}
+	})
+}
+
+func projectProvision(c *cli, flags *flag.FlagSet, args []string) error {
+	input := service.CreateProjectResourcesInput{}
+	flags.StringVar(&input.ProjectID, "id", "", "project ID")
+	flags.BoolVar(&input.DryRun, "dry-run", false, "print the plan without applying it")
+	flags.IntVar(&input.Concurrency, "concurrency", 0, "number of resources provisioned at the same time")
+	flags.StringVar(&input.OnFailure, "on-failure", "", "stop or rollback")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if input.ProjectID == "" {
+		return fmt.Errorf("a project ID is required, use -id")
+	}
+	out, err := c.client.CreateProjectResources(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(out, func(t *table) { resourcePlan(t, out.Plan) })
+}
+
+func projectDestroy(c *cli, flags *flag.FlagSet, args []string) error {
+	input := service.DestroyProjectResourcesInput{}
+	flags.StringVar(&input.ProjectID, "id", "", "project ID")
+	flags.BoolVar(&input.EmptyFirst, "empty-first", false, "delete the objects of the buckets first")
+	flags.BoolVar(&input.DryRun, "dry-run", false, "print the plan without applying it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if input.ProjectID == "" {
+		return fmt.Errorf("a project ID is required, use -id")
+	}
+	out, err := c.client.DestroyProjectResources(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(out, func(t *table) { resourcePlan(t, out.Plan) })
+}
+
+func projectImport(c *cli, flags *flag.FlagSet, args []string) error {
+	input := service.ImportTemplateInput{Parameters: make(map[string]string)}
+	file := flags.String("f", "", "template file, - for the standard input")
+	flags.StringVar(&input.ProjectID, "id", "", "project ID")
+	flags.StringVar(&input.Name, "name", "", "project name")
+	flags.Var(keyValues(input.Parameters), "param", "template parameter KEY=VALUE, repeatable")
+	flags.BoolVar(&input.DryRun, "dry-run", false, "print the project without creating it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	template, err := c.readFile(*file)
+	if err != nil {
+		return err
+	}
+	input.Template = string(template)
+	out, err := c.client.ImportTemplate(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(out, func(t *table) {
+		t.row("RESOURCE", "TYPE", "DEPENDS ON")
+		for _, r := range out.Project.Resources {
+			t.row(r.ID, r.Type, strings.Join(r.DependsOn, ","))
+		}
+		for _, issue := range out.Unsupported {
+			t.note("skipped %s %s: %s", issue.ResourceID, issue.Type, issue.Message)
+		}
+	})
+}
+
+func resourcePlan(t *table, plan []service.ResourceChange) {
+	t.row("RESOURCE", "TYPE", "ACTION", "DRIFT")
+	for _, change := range plan {
+		drift := make([]string, 0, len(change.Drift))
+		for _, d := range change.Drift {
+			drift = append(drift, d.Property)
+		}
+		t.row(change.ResourceID, change.Type, change.Action, strings.Join(drift, ","))
+	}
+}
+
+func workflowCreate(c *cli, flags *flag.FlagSet, args []string) error {
+	file := flags.String("f", "", "workflow definition file, - for the standard input")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	input := service.CreateWorkflowInput{}
+	if err := c.readDocument(*file, &input); err != nil {
+		return err
+	}
+	out, err := c.client.CreateWorkflow(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(out, func(t *table) {
+		t.row("WORKFLOW", "STEPS", "STATUS")
+		t.row(input.ID, len(out.Metadata), "created")
+	})
+}
+
+func workflowList(c *cli, flags *flag.FlagSet, args []string) error {
+	projectID := flags.String("project", "", "list the workflows of a project")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	workflows, err := c.client.ListWorkflows(c.ctx, *projectID)
+	if err != nil {
+		return err
+	}
+	sortByID(workflows, func(w service.Workflow) string { return w.ID })
+	return c.print(workflows, func(t *table) {
+		t.row("ID", "NAME", "PROJECT", "STATUS", "STEPS")
+		for _, w := range workflows {
+			t.row(w.ID, w.Name, w.ProjectID, w.Status, len(w.Components))
+		}
+	})
+}
+
+func workflowExport(c *cli, flags *flag.FlagSet, args []string) error {
+	id := flags.String("id", "", "workflow ID")
+	format := flags.String("format", service.DefinitionFormatYAML, "yaml or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("a workflow ID is required, use -id")
+	}
+	b, err := c.client.ExportWorkflow(c.ctx, *id, *format)
+	if err != nil {
+		return err
+	}
+	_, err = c.stdout.Write(b)
+	return err
+}
+
+func workflowImport(c *cli, flags *flag.FlagSet, args []string) error {
+	file := flags.String("f", "", "workflow definition file, - for the standard input")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	document, err := c.readFile(*file)
+	if err != nil {
+		return err
+	}
+	out, err := c.client.ImportWorkflows(c.ctx, document)
+	if err != nil {
+		return err
+	}
+	return c.print(out, func(t *table) {
+		t.row("KIND", "ID", "STATUS")
+		for _, id := range out.Workflows {
+			t.row(service.DefinitionKindWorkflow, id, "imported")
+		}
+		for _, id := range out.Triggers {
+			t.row(service.DefinitionKindTrigger, id, "imported")
+		}
+	})
+}
+
+func workflowRun(c *cli, flags *flag.FlagSet, args []string) error {
+	inputs := keyValues{}
+	id := flags.String("id", "", "workflow ID")
+	flags.Var(inputs, "input", "workflow input KEY=VALUE, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("a workflow ID is required, use -id")
+	}
+	input := service.RunWorkflowInput{ID: *id, Input: make(map[string]interface{}, len(inputs))}
+	for k, v := range inputs {
+		input.Input[k] = v
+	}
+	run, err := c.client.RunWorkflow(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(run, func(t *table) { runStatus(t, run) })
+}
+
+// runFlag defines the -run flag of the commands of a workflow run
+func runFlag(flags *flag.FlagSet, args []string) (string, error) {
+	runID := flags.String("run", "", "run ID, printed by workflow run")
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+	if *runID == "" {
+		return "", fmt.Errorf("a run ID is required, use -run")
+	}
+	return *runID, nil
+}
+
+func workflowStatus(c *cli, flags *flag.FlagSet, args []string) error {
+	runID, err := runFlag(flags, args)
+	if err != nil {
+		return err
+	}
+	run, err := c.client.GetWorkflowRun(c.ctx, runID)
+	if err != nil {
+		return err
+	}
+	return c.print(run, func(t *table) { runStatus(t, run) })
+}
+
+func workflowLogs(c *cli, flags *flag.FlagSet, args []string) error {
+	runID, err := runFlag(flags, args)
+	if err != nil {
+		return err
+	}
+	events, err := c.client.GetWorkflowRunLogs(c.ctx, runID)
+	if err != nil {
+		return err
+	}
+	return c.print(events, func(t *table) {
+		t.row("STEP", "TYPE", "STATUS", "DURATION", "DETAILS")
+		for _, e := range events {
+			details := e.Summary
+			if e.Error != "" {
+				details = e.Error
+			}
+			duration := ""
+			if e.Status != service.StepStatusStarted {
+				duration = e.Duration.Round(time.Millisecond).String()
+			}
+			t.row(e.StepID, e.Type, e.Status, duration, details)
+		}
+	})
+}
+
+func workflowCancel(c *cli, flags *flag.FlagSet, args []string) error {
+	runID, err := runFlag(flags, args)
+	if err != nil {
+		return err
+	}
+	run, err := c.client.CancelWorkflowRun(c.ctx, runID)
+	if err != nil {
+		return err
+	}
+	return c.print(run, func(t *table) { runStatus(t, run) })
+}
+
+func runStatus(t *table, run service.WorkflowRun) {
+	t.row("RUN", "WORKFLOW", "STATUS", "STARTED", "ENDED", "ERROR")
+	ended := ""
+	if run.EndedAt != nil {
+		ended = run.EndedAt.Format(time.RFC3339)
+	}
+	t.row(run.ID, run.WorkflowID, run.Status, run.StartedAt.Format(time.RFC3339), ended, run.Error)
+}
+
+func triggerCreate(c *cli, flags *flag.FlagSet, args []string) error {
+	file := flags.String("f", "", "trigger definition file, - for the standard input")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	input := service.CreateWorkflowTriggerInput{}
+	if err := c.readDocument(*file, &input); err != nil {
+		return err
+	}
+	trigger, err := c.client.CreateWorkflowTrigger(c.ctx, &input)
+	if err != nil {
+		return err
+	}
+	return c.print(trigger, func(t *table) {
+		t.row("TRIGGER", "WORKFLOW", "STATUS")
+		t.row(trigger.ID, trigger.WorkflowID, "created")
+	})
+}
+
+func triggerList(c *cli, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	triggers, err := c.client.ListWorkflowTriggers(c.ctx)
+	if err != nil {
+		return err
+	}
+	sortByID(triggers, func(t service.CreateWorkflowTriggerInput) string { return t.ID })
+	return c.print(triggers, func(t *table) {
+		t.row("ID", "NAME", "TYPE", "WORKFLOW", "STATUS")
+		for _, trigger := range triggers {
+			t.row(trigger.ID, trigger.Name, trigger.Type, trigger.WorkflowID, trigger.Status)
+		}
+	})
+}
+
+func triggerPause(c *cli, flags *flag.FlagSet, args []string) error {
+	id := flags.String("id", "", "trigger ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("a trigger ID is required, use -id")
+	}
+	trigger, err := c.client.PauseWorkflowTrigger(c.ctx, *id)
+	if err != nil {
+		return err
+	}
+	return c.print(trigger, func(t *table) {
+		t.row("TRIGGER", "WORKFLOW", "STATUS")
+		t.row(trigger.ID, trigger.WorkflowID, trigger.Status)
+	})
+}
+
+func apply(c *cli, flags *flag.FlagSet, args []string) error {
+	path := flags.String("f", "", "definition file or directory")
+	dryRun := flags.Bool("dry-run", false, "print the plan without applying it")
+	prune := flags.Bool("prune", false, "delete the projects, workflows and triggers which are not defined")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return fmt.Errorf("a file or a directory is required, use -f")
+	}
+	// the definitions are validated locally before they are sent
+	definitions, err := service.LoadDefinitions(*path)
+	if err != nil {
+		return err
+	}
+	out, err := c.client.Apply(c.ctx, &service.ApplyInput{Definitions: definitions, DryRun: *dryRun, Prune: *prune})
+	if err != nil {
+		return err
+	}
+	return c.print(out, func(t *table) {
+		t.row("KIND", "ID", "ACTION", "CHANGED")
+		for _, change := range out.Plan {
+			fields := make([]string, 0, len(change.Diff))
+			for _, d := range change.Diff {
+				fields = append(fields, d.Property)
+			}
+			t.row(change.Kind, change.ID, change.Action, strings.Join(fields, ","))
+		}
+	})
+}
+
+// auditQueryFlags defines the flags which select audit entries, the times are
+// RFC 3339
+func auditQueryFlags(flags *flag.FlagSet) func() (service.AuditQuery, error) {
+	query := service.AuditQuery{}
+	flags.StringVar(&query.Subject, "subject", "", "caller of the requests")
+	flags.StringVar(&query.Route, "route", "", "route name, e.g. CreateProject")
+	flags.StringVar(&query.Target, "target", "", "ID of a project, workflow or trigger")
+	flags.StringVar(&query.Outcome, "outcome", "", "succeeded, failed or denied")
+	since := flags.String("since", "", "oldest time, e.g. 2024-05-01T00:00:00Z")
+	until := flags.String("until", "", "newest time")
+	return func() (service.AuditQuery, error) {
+		var err error
+		if *since != "" {
+			if query.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+				return query, fmt.Errorf("invalid -since: %v", err)
+			}
+		}
+		if *until != "" {
+			if query.Until, err = time.Parse(time.RFC3339, *until); err != nil {
+				return query, fmt.Errorf("invalid -until: %v", err)
+			}
+		}
+		return query, nil
+	}
+}
+
+func auditList(c *cli, flags *flag.FlagSet, args []string) error {
+	parseQuery := auditQueryFlags(flags)
+	limit := flags.Int("limit", 0, "number of most recent entries")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	query, err := parseQuery()
+	if err != nil {
+		return err
+	}
+	query.Limit = *limit
+	entries, err := c.client.ListAuditEntries(c.ctx, query)
+	if err != nil {
+		return err
+	}
+	return c.print(entries, func(t *table) {
+		t.row("ID", "TIME", "SUBJECT", "ROUTE", "TARGETS", "OUTCOME")
+		for _, e := range entries {
+			targets := make([]string, len(e.Targets))
+			for i, target := range e.Targets {
+				targets[i] = target.Type + "/" + target.ID
+			}
+			t.row(e.ID, e.Time.Format(time.RFC3339), e.Subject, e.Route, strings.Join(targets, ","), e.Outcome)
+		}
+	})
+}
+
+func auditExport(c *cli, flags *flag.FlagSet, args []string) error {
+	parseQuery := auditQueryFlags(flags)
+	file := flags.String("o", "", "JSON lines file, - for the standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("an output file is required, use -o")
+	}
+	query, err := parseQuery()
+	if err != nil {
+		return err
+	}
+	b, err := c.client.ExportAuditEntries(c.ctx, query)
+	if err != nil {
+		return err
+	}
+	if *file == "-" {
+		_, err = c.stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*file, b, 0600)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+)
+
+// readFile reads a file, - is the standard input
+func (c *cli) readFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("a file is required, use -f")
+	}
+	if path == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// readDocument reads a YAML or JSON document into v, through JSON so that the
+// JSON field names of the service types apply
+func (c *cli) readDocument(path string, v interface{}) error {
+	data, err := c.readFile(path)
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("error when parsing %s: %v", path, err)
+	}
+	b, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("error when parsing %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("error when parsing %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/golden-sdk/service"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runLocal runs a workflow of a definition file in the process, without a
+// server. The projects of the file are created too, so that the steps can
+// reference their resources.
+func runLocal(c *cli, flags *flag.FlagSet, args []string) error {
+	inputs := make(keyValues)
+	awsConfig := service.AWSConfig{}
+	workflowID := flags.String("workflow", "", "workflow to run when the file defines several")
+	storage := flags.String("storage", "aws", "storage backend, aws or fake for an in-memory S3")
+	grace := flags.Duration("grace", 30*time.Second, "time given to the step in progress after SIGINT or SIGTERM")
+	flags.Var(inputs, "input", "workflow input KEY=VALUE, repeatable")
+	flags.StringVar(&awsConfig.Region, "aws-region", "", "default AWS region")
+	flags.StringVar(&awsConfig.Endpoint, "aws-endpoint", "", "URL of an S3 compatible endpoint, e.g. http://localhost:9000")
+	flags.BoolVar(&awsConfig.UsePathStyle, "aws-path-style", false, "use path-style addressing for S3 buckets")
+	flags.StringVar(&awsConfig.Profile, "aws-profile", "", "shared credentials profile")
+	// the file comes first, e.g. golden run backup.yaml -input dir=/data
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if file == "" && flags.NArg() > 0 {
+		file = flags.Arg(0)
+	}
+	if file == "" {
+		return fmt.Errorf("a workflow definition file is required")
+	}
+
+	var clients service.ClientProvider
+	switch *storage {
+	case "aws":
+		clients = service.NewAWSClientProvider(awsConfig)
+	case "fake":
+		clients = service.NewFakeClientProvider()
+	default:
+		return fmt.Errorf("unsupported storage backend %q", *storage)
+	}
+
+	definitions, err := service.LoadDefinitions(file)
+	if err != nil {
+		return err
+	}
+	if *workflowID == "" {
+		if len(definitions.Workflows) != 1 {
+			return fmt.Errorf("%s defines %d workflows, choose one with -workflow", file, len(definitions.Workflows))
+		}
+		*workflowID = definitions.Workflows[0].ID
+	}
+	wm := &service.WorkflowManager{Clients: clients, Projects: &service.ResourceManager{Clients: clients}}
+	if _, err := wm.Apply(c.ctx, &service.ApplyInput{Definitions: definitions}); err != nil {
+		return err
+	}
+
+	engine := &service.WorkflowEngine{Workflows: wm, Progress: c.printStep}
+	input := service.RunWorkflowInput{ID: *workflowID, Input: make(map[string]interface{}, len(inputs))}
+	for k, v := range inputs {
+		input.Input[k] = v
+	}
+	// a signal stops the run after the step in progress, which is interrupted
+	// when it does not end within the grace period
+	signals, stop := signal.NotifyContext(c.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-finished:
+			return
+		case <-signals.Done():
+		}
+		fmt.Fprintf(c.stdout, "stopping, waiting up to %v for the step in progress\n", *grace)
+		ctx, cancel := context.WithTimeout(context.Background(), *grace)
+		defer cancel()
+		engine.Shutdown(ctx)
+	}()
+
+	if err := engine.RunWorkflow(c.ctx, input); err != nil {
+		return err
+	}
+	if c.output != "json" {
+		fmt.Fprintf(c.stdout, "workflow %s succeeded\n", *workflowID)
+	}
+	return nil
+}
+
+// printStep prints a step event as a line, or as a JSON document with
+// -output json
+func (c *cli) printStep(event service.StepEvent) {
+	if c.output == "json" {
+		b, _ := json.Marshal(event)
+		fmt.Fprintf(c.stdout, "%s\n", b)
+		return
+	}
+	switch event.Status {
+	case service.StepStatusStarted:
+		fmt.Fprintf(c.stdout, "==> %s (%s)\n", event.StepID, event.Type)
+	case service.StepStatusSucceeded:
+		fmt.Fprintf(c.stdout, "    ok   %s in %s", event.StepID, event.Duration.Round(time.Millisecond))
+		if event.Summary != "" {
+			fmt.Fprintf(c.stdout, ": %s", event.Summary)
+		}
+		fmt.Fprintln(c.stdout)
+	case service.StepStatusFailed:
+		fmt.Fprintf(c.stdout, "    FAIL %s in %s: %s\n", event.StepID, event.Duration.Round(time.Millisecond), event.Error)
+	case service.StepStatusInterrupted:
+		fmt.Fprintf(c.stdout, "    STOP %s in %s: %s\n", event.StepID, event.Duration.Round(time.Millisecond), event.Error)
+	}
+}
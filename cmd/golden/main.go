@@ -0,0 +1,255 @@
+// Command golden is the command-line client of the golden-sdk server.
+//
+//	golden [-server URL] [-output table|json] <command> [flags]
+//
+// The server URL defaults to $GOLDEN_SERVER, then to http://localhost:8080,
+// and -token, which defaults to $GOLDEN_TOKEN, is sent as a bearer token.
+// golden run executes a workflow file in the process instead of calling the
+// server. Run golden help for the list of commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/golden-sdk/client"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// cli holds the global flags of a command
+type cli struct {
+	server string
+	token  string
+	// output is table or json
+	output string
+	client *client.Client
+	ctx    context.Context
+	stdout io.Writer
+	stdin  io.Reader
+}
+
+type command struct {
+	usage       string
+	description string
+	run         func(c *cli, flags *flag.FlagSet, args []string) error
+}
+
+// commands are keyed by their name, e.g. "project list"
+var commands = map[string]command{
+	"project create": {
+		usage:       "-f FILE",
+		description: "create a project from a YAML or JSON definition",
+		run:         projectCreate,
+	},
+	"project list": {
+		description: "list the projects",
+		run:         projectList,
+	},
+	"project provision": {
+		usage:       "-id ID [-dry-run] [-concurrency N] [-on-failure stop|rollback]",
+		description: "create or update the resources of a project",
+		run:         projectProvision,
+	},
+	"project destroy": {
+		usage:       "-id ID [-empty-first] [-dry-run]",
+		description: "delete the resources of a project",
+		run:         projectDestroy,
+	},
+	"project import": {
+		usage:       "-f TEMPLATE -id ID [-name NAME] [-param KEY=VALUE]... [-dry-run]",
+		description: "create a project from a CloudFormation style template",
+		run:         projectImport,
+	},
+	"workflow create": {
+		usage:       "-f FILE",
+		description: "create a workflow from a YAML or JSON definition",
+		run:         workflowCreate,
+	},
+	"workflow list": {
+		usage:       "[-project ID]",
+		description: "list the workflows",
+		run:         workflowList,
+	},
+	"workflow export": {
+		usage:       "-id ID [-format yaml|json]",
+		description: "print the definition file of a workflow",
+		run:         workflowExport,
+	},
+	"workflow import": {
+		usage:       "-f FILE",
+		description: "create the workflows and triggers of definition files",
+		run:         workflowImport,
+	},
+	"workflow run": {
+		usage:       "-id ID [-input KEY=VALUE]...",
+		description: "start a run of a workflow on the server",
+		run:         workflowRun,
+	},
+	"workflow status": {
+		usage:       "-run ID",
+		description: "show the status of a workflow run",
+		run:         workflowStatus,
+	},
+	"workflow logs": {
+		usage:       "-run ID",
+		description: "show the steps of a workflow run",
+		run:         workflowLogs,
+	},
+	"workflow cancel": {
+		usage:       "-run ID",
+		description: "interrupt a workflow run",
+		run:         workflowCancel,
+	},
+	"trigger create": {
+		usage:       "-f FILE",
+		description: "create a trigger from a YAML or JSON definition",
+		run:         triggerCreate,
+	},
+	"trigger list": {
+		description: "list the triggers",
+		run:         triggerList,
+	},
+	"trigger pause": {
+		usage:       "-id ID",
+		description: "pause a trigger",
+		run:         triggerPause,
+	},
+	"run": {
+		usage:       "FILE [-input KEY=VALUE]... [-workflow ID] [-storage aws|fake] [-grace DURATION]",
+		description: "run a workflow of a definition file locally, without a server",
+		run:         runLocal,
+	},
+	"audit list": {
+		usage:       "[-subject NAME] [-route NAME] [-target ID] [-outcome succeeded|failed|denied] [-since TIME] [-until TIME] [-limit N]",
+		description: "list the entries of the audit log",
+		run:         auditList,
+	},
+	"audit export": {
+		usage:       "-o FILE [-subject NAME] [-route NAME] [-target ID] [-outcome succeeded|failed|denied] [-since TIME] [-until TIME]",
+		description: "write the entries of the audit log to a JSON lines file",
+		run:         auditExport,
+	},
+	"apply": {
+		usage:       "-f PATH [-dry-run] [-prune]",
+		description: "apply a file or a directory of project, workflow and trigger definitions",
+		run:         apply,
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes a command line and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	c := &cli{
+		ctx:    context.Background(),
+		stdout: stdout,
+		stdin:  stdin,
+	}
+	global := flag.NewFlagSet("golden", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.StringVar(&c.server, "server", "", "URL of the golden-sdk server, defaults to $GOLDEN_SERVER or "+defaultServer)
+	global.StringVar(&c.token, "token", os.Getenv("GOLDEN_TOKEN"), "bearer token sent to the server, defaults to $GOLDEN_TOKEN")
+	global.StringVar(&c.output, "output", "table", "output format, table or json")
+	global.Usage = func() { usage(stderr, global) }
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+	if c.server == "" {
+		c.server = os.Getenv("GOLDEN_SERVER")
+	}
+	if c.server == "" {
+		c.server = defaultServer
+	}
+	options := []client.Option{client.WithHTTPClient(&http.Client{Timeout: 5 * time.Minute})}
+	if c.token != "" {
+		options = append(options, client.WithAuth(client.BearerToken(c.token)))
+	}
+	c.client = client.New(c.server, options...)
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(stderr, "unsupported output format %q\n", c.output)
+		return 2
+	}
+
+	args = global.Args()
+	if len(args) == 0 || args[0] == "help" {
+		usage(stderr, global)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	name, cmd, ok := lookupCommand(args)
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q, run golden help\n", strings.Join(args, " "))
+		return 2
+	}
+
+	flags := flag.NewFlagSet("golden "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: golden %s %s\n", name, cmd.usage)
+		flags.PrintDefaults()
+	}
+	rest := args[len(strings.Fields(name)):]
+	if err := cmd.run(c, flags, rest); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func lookupCommand(args []string) (string, command, bool) {
+	if len(args) > 1 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return args[0] + " " + args[1], cmd, true
+		}
+	}
+	cmd, ok := commands[args[0]]
+	return args[0], cmd, ok
+}
+
+func usage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintf(w, "usage: golden [flags] <command> [command flags]\n\nflags:\n")
+	global.PrintDefaults()
+	fmt.Fprintf(w, "\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-18s %s\n", name, commands[name].description)
+	}
+}
+
+// keyValues collects repeated -param KEY=VALUE flags
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", value)
+	}
+	kv[k] = v
+	return nil
+}
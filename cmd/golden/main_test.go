@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golden-sdk/handler"
+	"github.com/golden-sdk/service"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	handler.SetClientProvider(service.NewFakeClientProvider())
+	os.Exit(m.Run())
+}
+
+// golden runs a command line against the server
+func golden(t *testing.T, server string, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-server", server}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	server := httptest.NewServer(handler.NewRouter(handler.AllRoutes()))
+	defer server.Close()
+
+	project := "id: project-cli\nname: CLI\nresources:\n  - id: logs\n    type: S3:Bucket\n    properties:\n      BucketName: cli-logs\n"
+	if _, stderr, code := golden(t, server.URL, project, "project", "create", "-f", "-"); code != 0 {
+		t.Fatalf("Expected project create to succeed, got %d: %s", code, stderr)
+	}
+	stdout, stderr, code := golden(t, server.URL, "", "project", "provision", "-id", "project-cli")
+	if code != 0 {
+		t.Fatalf("Expected project provision to succeed, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "logs") || !strings.Contains(stdout, "create") {
+		t.Fatalf("Expected the plan table, got %s", stdout)
+	}
+	stdout, _, _ = golden(t, server.URL, "", "project", "list")
+	if !strings.Contains(stdout, "ID") || !strings.Contains(stdout, "project-cli") {
+		t.Fatalf("Expected the project table, got %s", stdout)
+	}
+
+	workflow := "id: workflow-cli\nprojectId: project-cli\nsteps:\n  - id: upload\n    type: S3:PutObject\ntriggers:\n  - id: trigger-cli\n    type: scheduled\n"
+	if _, stderr, code := golden(t, server.URL, workflow, "workflow", "import", "-f", "-"); code != 0 {
+		t.Fatalf("Expected workflow import to succeed, got %d: %s", code, stderr)
+	}
+	stdout, _, _ = golden(t, server.URL, "", "-output", "json", "workflow", "list", "-project", "project-cli")
+	if !strings.Contains(stdout, `"id": "workflow-cli"`) {
+		t.Fatalf("Expected the workflows as JSON, got %s", stdout)
+	}
+	stdout, _, _ = golden(t, server.URL, "", "workflow", "export", "-id", "workflow-cli")
+	if !strings.Contains(stdout, "id: trigger-cli") {
+		t.Fatalf("Expected the workflow definition, got %s", stdout)
+	}
+	stdout, _, _ = golden(t, server.URL, "", "trigger", "list")
+	if !strings.Contains(stdout, "trigger-cli") {
+		t.Fatalf("Expected the trigger table, got %s", stdout)
+	}
+	stdout, stderr, code = golden(t, server.URL, "", "trigger", "pause", "-id", "trigger-cli")
+	if code != 0 || !strings.Contains(stdout, string(service.StatusDeactive)) {
+		t.Fatalf("Expected the trigger to be paused, got %d: %s%s", code, stdout, stderr)
+	}
+
+	files := t.TempDir()
+	if err := os.WriteFile(filepath.Join(files, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	read := "id: workflow-run-cli\nsteps:\n  - id: read\n    type: ReadFile\n    input:\n      directory: " + files + "\n"
+	if _, stderr, code := golden(t, server.URL, read, "workflow", "import", "-f", "-"); code != 0 {
+		t.Fatalf("Expected workflow import to succeed, got %d: %s", code, stderr)
+	}
+	stdout, stderr, code = golden(t, server.URL, "", "-output", "json", "workflow", "run", "-id", "workflow-run-cli")
+	if code != 0 {
+		t.Fatalf("Expected workflow run to succeed, got %d: %s", code, stderr)
+	}
+	var run service.WorkflowRun
+	if err := json.Unmarshal([]byte(stdout), &run); err != nil || run.ID == "" {
+		t.Fatalf("Expected the run as JSON, got %s", stdout)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(stdout, service.RunStatusSucceeded) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		stdout, _, _ = golden(t, server.URL, "", "workflow", "status", "-run", run.ID)
+	}
+	if !strings.Contains(stdout, service.RunStatusSucceeded) {
+		t.Fatalf("Expected the run to succeed, got %s", stdout)
+	}
+	stdout, _, _ = golden(t, server.URL, "", "workflow", "logs", "-run", run.ID)
+	if !strings.Contains(stdout, "read") || !strings.Contains(stdout, "1 files") {
+		t.Fatalf("Expected the steps of the run, got %s", stdout)
+	}
+	if _, stderr, code := golden(t, server.URL, "", "workflow", "cancel", "-run", run.ID); code != 1 || !strings.Contains(stderr, "is not running") {
+		t.Fatalf("Expected an ended run not to be canceled, got %d: %s", code, stderr)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "workflow.yaml"), []byte(workflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code = golden(t, server.URL, "", "apply", "-f", dir, "-dry-run")
+	if code != 0 {
+		t.Fatalf("Expected apply to succeed, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "workflow-cli") || !strings.Contains(stdout, "none") {
+		t.Fatalf("Expected an empty plan, got %s", stdout)
+	}
+}
+
+func TestAuditCommands(t *testing.T) {
+	server := httptest.NewServer(handler.NewRouter(handler.AllRoutes(), handler.WithAuditLog(&service.AuditLog{})))
+	defer server.Close()
+
+	if _, stderr, code := golden(t, server.URL, "id: project-audit-cli\nresources: []\n", "project", "create", "-f", "-"); code != 0 {
+		t.Fatalf("Expected project create to succeed, got %d: %s", code, stderr)
+	}
+	golden(t, server.URL, "", "project", "provision", "-id", "project-audit-404")
+	stdout, stderr, code := golden(t, server.URL, "", "audit", "list", "-outcome", "failed")
+	if code != 0 {
+		t.Fatalf("Expected audit list to succeed, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "CreateProjectResources") || !strings.Contains(stdout, "project/project-audit-404") || strings.Contains(stdout, "project-audit-cli") {
+		t.Fatalf("Expected the failed provision, got %s", stdout)
+	}
+
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	if _, stderr, code := golden(t, server.URL, "", "audit", "export", "-o", file, "-route", "CreateProject"); code != 0 {
+		t.Fatalf("Expected audit export to succeed, got %d: %s", code, stderr)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"id":"project-audit-cli"`) {
+		t.Fatalf("Expected the created project as a JSON line, got %s", b)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	server := httptest.NewServer(handler.NewRouter(handler.AllRoutes()))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{name: "unknown command", args: []string{"project", "rename"}, code: 2, stderr: `unknown command "project rename"`},
+		{name: "server error", args: []string{"project", "provision", "-id", "project-404"}, code: 1, stderr: "project project-404 not found (400)"},
+		{name: "missing flag", args: []string{"workflow", "export"}, code: 1, stderr: "a workflow ID is required"},
+		{name: "unknown run", args: []string{"workflow", "status", "-run", "run-404"}, code: 1, stderr: "run run-404 not found (404)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, stderr, code := golden(t, server.URL, "", test.args...)
+			if code != test.code || !strings.Contains(stderr, test.stderr) {
+				t.Fatalf("Expected exit code %d and %q, got %d and %q", test.code, test.stderr, code, stderr)
+			}
+		})
+	}
+}
+
+func TestRunLocal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "backup.yaml")
+	definition := `kind: Project
+id: project-local
+resources:
+  - id: backup
+    type: S3:Bucket
+    properties:
+      BucketName: local-backup
+---
+kind: Workflow
+id: backup
+projectId: project-local
+provisionResources: true
+input:
+  dir: ""
+steps:
+  - id: read
+    type: ReadFile
+    next: upload
+    input:
+      directory: ${input.dir}
+  - id: upload
+    type: S3:PutObject
+    input:
+      bucket: ${resources.backup.name}
+      baseDir: ${input.dir}
+`
+	if err := os.WriteFile(file, []byte(definition), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := golden(t, "", "", "run", file, "-storage", "fake", "--input", "dir="+dir)
+	if code != 0 {
+		t.Fatalf("Expected the run to succeed, got %d: %s", code, stderr)
+	}
+	for _, want := range []string{"==> read (ReadFile)", "ok   read", "1 files", "1 objects uploaded, ", "workflow backup succeeded"} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("Expected %q in the progress, got %s", want, stdout)
+		}
+	}
+
+	_, stderr, code = golden(t, "", "", "run", file, "-storage", "fake")
+	if code != 1 || !strings.Contains(stderr, "input dir has no value") {
+		t.Fatalf("Expected the run without input to fail, got %d: %s", code, stderr)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// table prints aligned columns, with notes below the rows
+type table struct {
+	rows  [][]string
+	notes []string
+}
+
+func (t *table) row(values ...interface{}) {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = fmt.Sprint(v)
+		if row[i] == "" {
+			row[i] = "-"
+		}
+	}
+	t.rows = append(t.rows, row)
+}
+
+func (t *table) note(format string, args ...interface{}) {
+	t.notes = append(t.notes, fmt.Sprintf(format, args...))
+}
+
+// print writes v as JSON with -output json, otherwise the table built by fill
+func (c *cli) print(v interface{}, fill func(t *table)) error {
+	if c.output == "json" {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	t := &table{}
+	fill(t)
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, note := range t.notes {
+		if _, err := fmt.Fprintln(c.stdout, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortByID[T any](items []T, id func(T) string) {
+	sort.Slice(items, func(i, j int) bool {
+		return id(items[i]) < id(items[j])
+	})
+}
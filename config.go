@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/golden-sdk/handler"
+	"github.com/golden-sdk/service"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the server settings. They are read from the defaults, then the
+// YAML or JSON file given by -config or $GOLDEN_CONFIG, then the GOLDEN_*
+// environment variables, then the flags, each overriding the previous ones.
+type Config struct {
+	// Listen is the address of the HTTP server, e.g. :8080
+	Listen string     `yaml:"listen"`
+	TLS    TLSConfig  `yaml:"tls"`
+	CORS   CORSConfig `yaml:"cors"`
+	// Storage is aws, or fake for an in-memory S3
+	Storage string    `yaml:"storage"`
+	AWS     AWSConfig `yaml:"aws"`
+	// LogLevel is debug, info, warn or error
+	LogLevel      string              `yaml:"logLevel"`
+	Scheduler     SchedulerConfig     `yaml:"scheduler"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Authorization AuthorizationConfig `yaml:"authorization"`
+	Audit         AuditConfig         `yaml:"audit"`
+	// ShutdownGrace is how long the requests in progress may run after a
+	// SIGTERM or SIGINT before they are interrupted
+	ShutdownGrace time.Duration `yaml:"shutdownGrace"`
+}
+
+// TLSConfig enables HTTPS when both files are set
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the API from a browser,
+	// * allows any origin. CORS is disabled when empty.
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+}
+
+type AWSConfig struct {
+	Region       string `yaml:"region"`
+	Endpoint     string `yaml:"endpoint"`
+	UsePathStyle bool   `yaml:"usePathStyle"`
+	Profile      string `yaml:"profile"`
+}
+
+// AuthConfig lists the accepted credentials, the API is open when none is
+// configured. Keys and secrets are secret references, env:NAME or file:PATH.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig  `yaml:"apiKeys"`
+	HMAC    []HMACKeyConfig `yaml:"hmac"`
+	JWT     JWTConfig       `yaml:"jwt"`
+}
+
+// AuthorizationConfig grants roles on projects to the authenticated callers,
+// every caller may do anything when there is no binding
+type AuthorizationConfig struct {
+	Bindings []RoleBindingConfig `yaml:"bindings"`
+}
+
+type RoleBindingConfig struct {
+	// Method is the authentication method of the subject: api-key, hmac or jwt
+	Method string `yaml:"method"`
+	// Subject is the name of an API key, the key ID of an HMAC secret or the
+	// sub claim of a JWT
+	Subject string `yaml:"subject"`
+	// Project is a project ID, or * for all projects
+	Project string `yaml:"project"`
+	// Role is viewer, operator or admin
+	Role string `yaml:"role"`
+}
+
+type AuditConfig struct {
+	// File is the JSON lines file the audit entries are appended to, they are
+	// only kept in memory when it is empty
+	File string `yaml:"file"`
+}
+
+type APIKeyConfig struct {
+	// Name identifies the caller
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type HMACKeyConfig struct {
+	KeyID  string `yaml:"keyId"`
+	Secret string `yaml:"secret"`
+}
+
+// JWTConfig enables bearer tokens signed by the keys of a local JWKS file
+type JWTConfig struct {
+	JWKSFile string `yaml:"jwksFile"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+type SchedulerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the period at which the scheduled triggers are checked
+	Interval time.Duration `yaml:"interval"`
+	// MaxConcurrentRuns bounds the workflow runs started by the scheduler
+	MaxConcurrentRuns int `yaml:"maxConcurrentRuns"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Listen:   ":8080",
+		Storage:  "aws",
+		LogLevel: "info",
+		Scheduler: SchedulerConfig{
+			Interval:          time.Minute,
+			MaxConcurrentRuns: 4,
+		},
+		ShutdownGrace: 30 * time.Second,
+	}
+}
+
+// setting is a value which can be set by an environment variable and a flag
+type setting struct {
+	flag  string
+	usage string
+	// isBool lets the flag be given without a value, e.g. -aws-path-style
+	isBool bool
+	set    func(c *Config, value string) error
+}
+
+// env returns the environment variable of a setting, e.g. GOLDEN_AWS_REGION
+func (s setting) env() string {
+	return "GOLDEN_" + strings.ToUpper(strings.ReplaceAll(s.flag, "-", "_"))
+}
+
+var settings = []setting{
+	{flag: "listen", usage: "address of the HTTP server", set: func(c *Config, v string) error {
+		c.Listen = v
+		return nil
+	}},
+	{flag: "tls-cert", usage: "TLS certificate file", set: func(c *Config, v string) error {
+		c.TLS.CertFile = v
+		return nil
+	}},
+	{flag: "tls-key", usage: "TLS private key file", set: func(c *Config, v string) error {
+		c.TLS.KeyFile = v
+		return nil
+	}},
+	{flag: "cors-origins", usage: "comma separated origins allowed by CORS, * for any", set: func(c *Config, v string) error {
+		c.CORS.AllowedOrigins = splitList(v)
+		return nil
+	}},
+	{flag: "storage", usage: "storage backend, aws or fake for an in-memory S3", set: func(c *Config, v string) error {
+		c.Storage = v
+		return nil
+	}},
+	{flag: "aws-region", usage: "default AWS region", set: func(c *Config, v string) error {
+		c.AWS.Region = v
+		return nil
+	}},
+	{flag: "aws-endpoint", usage: "URL of an AWS compatible endpoint used by all the clients, e.g. http://localhost:4566", set: func(c *Config, v string) error {
+		c.AWS.Endpoint = v
+		return nil
+	}},
+	{flag: "aws-path-style", usage: "use path-style addressing for S3 buckets", isBool: true, set: func(c *Config, v string) (err error) {
+		c.AWS.UsePathStyle, err = strconv.ParseBool(v)
+		return err
+	}},
+	{flag: "aws-profile", usage: "shared credentials profile", set: func(c *Config, v string) error {
+		c.AWS.Profile = v
+		return nil
+	}},
+	{flag: "log-level", usage: "debug, info, warn or error", set: func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+	{flag: "scheduler-enabled", usage: "run the scheduled triggers", isBool: true, set: func(c *Config, v string) (err error) {
+		c.Scheduler.Enabled, err = strconv.ParseBool(v)
+		return err
+	}},
+	{flag: "scheduler-interval", usage: "period at which the scheduled triggers are checked, e.g. 30s", set: func(c *Config, v string) (err error) {
+		c.Scheduler.Interval, err = time.ParseDuration(v)
+		return err
+	}},
+	{flag: "scheduler-max-runs", usage: "maximum number of runs started by the scheduler at the same time", set: func(c *Config, v string) (err error) {
+		c.Scheduler.MaxConcurrentRuns, err = strconv.Atoi(v)
+		return err
+	}},
+	{flag: "jwks-file", usage: "JWKS file of the keys which sign the JWT bearer tokens", set: func(c *Config, v string) error {
+		c.Auth.JWT.JWKSFile = v
+		return nil
+	}},
+	{flag: "jwt-issuer", usage: "expected issuer of the JWT bearer tokens", set: func(c *Config, v string) error {
+		c.Auth.JWT.Issuer = v
+		return nil
+	}},
+	{flag: "jwt-audience", usage: "expected audience of the JWT bearer tokens", set: func(c *Config, v string) error {
+		c.Auth.JWT.Audience = v
+		return nil
+	}},
+	{flag: "audit-file", usage: "JSON lines file the audit log is appended to", set: func(c *Config, v string) error {
+		c.Audit.File = v
+		return nil
+	}},
+	{flag: "shutdown-grace", usage: "time given to the requests in progress at shutdown, e.g. 2m", set: func(c *Config, v string) (err error) {
+		c.ShutdownGrace, err = time.ParseDuration(v)
+		return err
+	}},
+}
+
+// settingFlag records the value of a flag, which is applied after the file
+// and the environment variables
+type settingFlag struct {
+	value  *string
+	isBool bool
+}
+
+func (f settingFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f settingFlag) Set(v string) error {
+	*f.value = v
+	return nil
+}
+
+func (f settingFlag) IsBoolFlag() bool { return f.isBool }
+
+// loadConfig reads the server settings from the command line arguments,
+// getenv and the config file
+func loadConfig(args []string, getenv func(string) string, stderr io.Writer) (Config, error) {
+	flags := flag.NewFlagSet("golden-sdk", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("config", getenv("GOLDEN_CONFIG"), "YAML or JSON config file, defaults to $GOLDEN_CONFIG")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag] = new(string)
+		flags.Var(settingFlag{value: values[s.flag], isBool: s.isBool}, s.flag, fmt.Sprintf("%s, defaults to $%s", s.usage, s.env()))
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := defaultConfig()
+	if *file != "" {
+		b, err := os.ReadFile(*file)
+		if err != nil {
+			return Config{}, fmt.Errorf("error when reading config file: %v", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && err != io.EOF {
+			return Config{}, fmt.Errorf("error when parsing config file %s: %v", *file, err)
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env()); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %v", s.env(), err)
+			}
+		}
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				if setErr := s.set(&cfg, *values[s.flag]); setErr != nil {
+					err = fmt.Errorf("invalid -%s: %v", s.flag, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.validate()
+}
+
+func (c Config) validate() error {
+	if c.Listen == "" {
+		return fmt.Errorf("a listen address is required")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("both the TLS certificate and key files are required")
+	}
+	if c.Storage != "aws" && c.Storage != "fake" {
+		return fmt.Errorf("unsupported storage backend %q", c.Storage)
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unsupported log level %q", c.LogLevel)
+	}
+	if c.Scheduler.Interval <= 0 {
+		return fmt.Errorf("the scheduler interval must be positive")
+	}
+	if c.Scheduler.MaxConcurrentRuns < 1 {
+		return fmt.Errorf("the scheduler must allow at least one run")
+	}
+	if len(c.Authorization.Bindings) > 0 && len(c.Auth.APIKeys) == 0 && len(c.Auth.HMAC) == 0 && c.Auth.JWT.JWKSFile == "" {
+		return fmt.Errorf("role bindings need credentials to authenticate the callers")
+	}
+	if c.ShutdownGrace < 0 {
+		return fmt.Errorf("the shutdown grace period must not be negative")
+	}
+	return nil
+}
+
+// authenticators builds the authenticators of the configured credentials
+func (a AuthConfig) authenticators() ([]handler.Authenticator, error) {
+	auths := make([]handler.Authenticator, 0)
+	if len(a.APIKeys) > 0 {
+		keys := make(map[string]string, len(a.APIKeys))
+		for _, k := range a.APIKeys {
+			if k.Name == "" {
+				return nil, fmt.Errorf("an API key has no name")
+			}
+			if _, ok := keys[k.Name]; ok {
+				return nil, fmt.Errorf("duplicate API key %s", k.Name)
+			}
+			key, err := service.ResolveSecret(k.Key)
+			if err != nil {
+				return nil, fmt.Errorf("error when resolving API key %s: %v", k.Name, err)
+			}
+			keys[k.Name] = key
+		}
+		auths = append(auths, handler.NewAPIKeyAuthenticator(keys))
+	}
+	if len(a.HMAC) > 0 {
+		secrets := make(map[string]string, len(a.HMAC))
+		for _, k := range a.HMAC {
+			if k.KeyID == "" {
+				return nil, fmt.Errorf("an HMAC key has no ID")
+			}
+			if _, ok := secrets[k.KeyID]; ok {
+				return nil, fmt.Errorf("duplicate HMAC key %s", k.KeyID)
+			}
+			secret, err := service.ResolveSecret(k.Secret)
+			if err != nil {
+				return nil, fmt.Errorf("error when resolving HMAC key %s: %v", k.KeyID, err)
+			}
+			secrets[k.KeyID] = secret
+		}
+		auths = append(auths, handler.NewHMACAuthenticator(secrets))
+	}
+	if a.JWT.JWKSFile != "" {
+		auth, err := handler.NewJWTAuthenticator(handler.JWTConfig{JWKSFile: a.JWT.JWKSFile, Issuer: a.JWT.Issuer, Audience: a.JWT.Audience})
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, auth)
+	}
+	return auths, nil
+}
+
+// policy returns the policy of the role bindings, nil when there is none
+func (a AuthorizationConfig) policy() (*handler.Policy, error) {
+	if len(a.Bindings) == 0 {
+		return nil, nil
+	}
+	bindings := make([]handler.RoleBinding, len(a.Bindings))
+	for i, b := range a.Bindings {
+		bindings[i] = handler.RoleBinding{Method: b.Method, Subject: b.Subject, ProjectID: b.Project, Role: handler.Role(b.Role)}
+	}
+	return handler.NewPolicy(bindings)
+}
+
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
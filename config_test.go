@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadConfig(t *testing.T) {
+	file := writeConfig(t, `listen: ":9000"
+cors:
+  allowedOrigins: [https://console.example.com]
+storage: fake
+aws:
+  region: eu-west-1
+  profile: backup
+logLevel: warn
+scheduler:
+  enabled: true
+  interval: 30s
+`)
+	env := map[string]string{
+		"GOLDEN_CONFIG":     file,
+		"GOLDEN_AWS_REGION": "us-east-1",
+		"GOLDEN_LOG_LEVEL":  "debug",
+		"GOLDEN_AUDIT_FILE": "/var/log/golden/audit.jsonl",
+	}
+	cfg, err := loadConfig([]string{"-log-level", "error", "-aws-path-style"}, func(k string) string { return env[k] }, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment overrides the file, the flags override both
+	if cfg.Listen != ":9000" || cfg.Storage != "fake" || cfg.AWS.Profile != "backup" {
+		t.Fatalf("Expected the settings of the file, got %+v", cfg)
+	}
+	if cfg.AWS.Region != "us-east-1" {
+		t.Fatalf("Expected the region of the environment, got %s", cfg.AWS.Region)
+	}
+	if cfg.Audit.File != "/var/log/golden/audit.jsonl" {
+		t.Fatalf("Expected the audit file of the environment, got %s", cfg.Audit.File)
+	}
+	if cfg.LogLevel != "error" || !cfg.AWS.UsePathStyle {
+		t.Fatalf("Expected the settings of the flags, got %+v", cfg)
+	}
+	if !cfg.Scheduler.Enabled || cfg.Scheduler.Interval != 30*time.Second || cfg.Scheduler.MaxConcurrentRuns != 4 {
+		t.Fatalf("Expected the scheduler settings with defaults, got %+v", cfg.Scheduler)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "https://console.example.com" {
+		t.Fatalf("Expected the allowed origins of the file, got %v", cfg.CORS.AllowedOrigins)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{"unknown field", nil, nil, "listen: :80\nport: 80\n", "field port not found"},
+		{"tls key missing", []string{"-tls-cert", "cert.pem"}, nil, "", "both the TLS certificate and key files are required"},
+		{"storage", nil, map[string]string{"GOLDEN_STORAGE": "gcs"}, "", `unsupported storage backend "gcs"`},
+		{"log level", []string{"-log-level", "trace"}, nil, "", `unsupported log level "trace"`},
+		{"interval", nil, map[string]string{"GOLDEN_SCHEDULER_INTERVAL": "soon"}, "", "invalid GOLDEN_SCHEDULER_INTERVAL"},
+		{"bindings without credentials", nil, nil, "authorization:\n  bindings:\n    - {method: api-key, subject: ci, project: '*', role: admin}\n", "role bindings need credentials"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := test.env
+			if test.file != "" {
+				env = map[string]string{"GOLDEN_CONFIG": writeConfig(t, test.file)}
+			}
+			_, err := loadConfig(test.args, func(k string) string { return env[k] }, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Expected %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestEnableCors(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		origins []string
+		origin  string
+		want    string
+	}{
+		{nil, "https://evil.example.com", ""},
+		{[]string{"https://console.example.com"}, "https://console.example.com", "https://console.example.com"},
+		{[]string{"https://console.example.com"}, "https://evil.example.com", ""},
+		{[]string{"*"}, "https://any.example.com", "*"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", test.origin)
+		w := httptest.NewRecorder()
+		enableCors(next, test.origins).ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.want {
+			t.Fatalf("Expected %q to be allowed as %q by %v, got %q", test.origin, test.want, test.origins, got)
+		}
+	}
+}
+
+func TestAuthConfig(t *testing.T) {
+	t.Setenv("TEST_GOLDEN_CI_KEY", "secret-key")
+	file := writeConfig(t, `auth:
+  apiKeys:
+    - name: ci
+      key: env:TEST_GOLDEN_CI_KEY
+  hmac:
+    - keyId: deploy
+      secret: env:TEST_GOLDEN_DEPLOY_SECRET
+`)
+	cfg, err := loadConfig([]string{"-config", file}, func(string) string { return "" }, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Auth.authenticators(); err == nil || !strings.Contains(err.Error(), "HMAC key deploy") {
+		t.Fatalf("Expected the unset HMAC secret to be reported, got %v", err)
+	}
+
+	t.Setenv("TEST_GOLDEN_DEPLOY_SECRET", "shared-secret")
+	auths, err := cfg.Auth.authenticators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auths) != 2 {
+		t.Fatalf("Expected the API key and HMAC authenticators, got %d", len(auths))
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "secret-key")
+	if identity, err := auths[0].Authenticate(req); err != nil || identity.Subject != "ci" {
+		t.Fatalf("Expected the key of the environment to be accepted, got %+v, %v", identity, err)
+	}
+}
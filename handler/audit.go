@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golden-sdk/service"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAuditBody is the size of the largest request body kept in an audit
+	// entry
+	maxAuditBody = 64 * 1024
+
+	redacted = "[REDACTED]"
+)
+
+// maxRequestBody is the size of the largest request body accepted by the
+// audited routes, which read it before the request is authenticated
+var maxRequestBody int64 = 16 << 20
+
+// secretValueFields are the fields of a named entry, e.g. a workflow input,
+// whose values are redacted when the name is a secret field
+var secretValueFields = []string{"defaultValue", "default", "value"}
+
+// secretFields are the parts of the field names whose values are redacted
+// from the audited request bodies
+var secretFields = []string{"secret", "password", "passphrase", "token", "credential", "apikey", "accesskey", "privatekey", "encryptionkey"}
+
+type auditLogKey struct{}
+
+type auditEntryKey struct{}
+
+// withAuditLog makes the audit log available to the handlers of the requests
+func withAuditLog(auditLog *service.AuditLog, fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+		fn(w, r.WithContext(context.WithValue(r.Context(), auditLogKey{}, auditLog)), param)
+	}
+}
+
+// audit appends an entry for each request of a route to the audit log, once
+// the request is served
+func audit(auditLog *service.AuditLog, route string, fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+		entry := &service.AuditEntry{Route: route, Method: r.Method, Path: r.URL.RequestURI()}
+		if r.Body != nil {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+			r.Body.Close()
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit))
+				return
+			}
+			if err != nil {
+				writeErrorResponse(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			entry.Body = auditBody(body)
+		}
+
+		aw := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+		fn(aw, r.WithContext(context.WithValue(r.Context(), auditEntryKey{}, entry)), param)
+
+		entry.Status = aw.status
+		switch {
+		case aw.status == http.StatusUnauthorized || aw.status == http.StatusForbidden:
+			entry.Outcome = service.AuditOutcomeDenied
+		case aw.status >= 400:
+			entry.Outcome = service.AuditOutcomeFailed
+		default:
+			entry.Outcome = service.AuditOutcomeSucceeded
+		}
+		var errResp JsonErrorResponse
+		if aw.status >= 400 && json.Unmarshal(aw.body.Bytes(), &errResp) == nil && errResp.Error != nil {
+			entry.Error = errResp.Error.Title
+		}
+		if _, err := auditLog.Append(*entry); err != nil {
+			log.Printf("Failed to audit %s %s: %v", r.Method, r.URL.Path, err)
+		}
+	}
+}
+
+// auditWriter records the status of a response, and the body of the errors
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status >= 400 && w.body.Len() < maxAuditBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// auditIdentity records the caller of an audited request
+func auditIdentity(r *http.Request, identity *Identity) {
+	if entry, ok := r.Context().Value(auditEntryKey{}).(*service.AuditEntry); ok && identity != nil {
+		entry.Subject = identity.Subject
+		entry.AuthMethod = identity.Method
+	}
+}
+
+// auditTargets records the projects, workflows, triggers or runs changed by an
+// audited request, targetType is project, workflow, trigger or run
+func auditTargets(r *http.Request, targetType string, ids ...string) {
+	entry, ok := r.Context().Value(auditEntryKey{}).(*service.AuditEntry)
+	if !ok {
+		return
+	}
+	for _, id := range ids {
+		target := service.AuditTarget{Type: targetType, ID: id}
+		if id == "" || containsTarget(entry.Targets, target) {
+			continue
+		}
+		entry.Targets = append(entry.Targets, target)
+	}
+}
+
+// auditDefinitions records the projects, workflows and triggers of the
+// definitions of an audited request
+func auditDefinitions(r *http.Request, definitions service.Definitions) {
+	for _, p := range definitions.Projects {
+		auditTargets(r, "project", p.ID)
+	}
+	for _, w := range definitions.Workflows {
+		auditTargets(r, "workflow", w.ID)
+		for _, t := range w.Triggers {
+			auditTargets(r, "trigger", t.ID)
+		}
+	}
+	for _, t := range definitions.Triggers {
+		auditTargets(r, "trigger", t.ID)
+	}
+}
+
+func containsTarget(targets []service.AuditTarget, target service.AuditTarget) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// auditBody decodes a JSON or YAML request body and redacts its secrets, the
+// documents of a YAML stream are returned as a list
+func auditBody(body []byte) interface{} {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if len(body) > maxAuditBody {
+		return fmt.Sprintf("<%d bytes, not recorded>", len(body))
+	}
+	documents := make([]interface{}, 0, 1)
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Sprintf("<%d bytes which are not JSON or YAML>", len(body))
+		}
+		documents = append(documents, redact(document))
+	}
+	if len(documents) == 1 {
+		return documents[0]
+	}
+	return documents
+}
+
+// redact replaces the values of the secret fields of a decoded document, and
+// the values of the entries named after a secret field, e.g.
+// {"name": "password", "defaultValue": "..."}
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		secretEntry := isSecretField(name)
+		for k, field := range v {
+			if isSecretField(k) || (secretEntry && isSecretValueField(k)) {
+				v[k] = redacted
+			} else {
+				v[k] = redact(field)
+			}
+		}
+		return v
+	case map[interface{}]interface{}:
+		// YAML mappings with keys which are not strings cannot be encoded as
+		// JSON
+		m := make(map[string]interface{}, len(v))
+		for k, field := range v {
+			m[fmt.Sprint(k)] = field
+		}
+		return redact(m)
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isSecretValueField(name string) bool {
+	for _, field := range secretValueFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretField(name string) bool {
+	if name == "" {
+		return false
+	}
+	name = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+	for _, secret := range secretFields {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// listAuditEntriesHandler returns the audit entries selected by the subject,
+// route, target, outcome, since, until and limit query parameters
+func listAuditEntriesHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	auditLog, query, ok := auditRequest(w, r)
+	if !ok {
+		return
+	}
+	writeOKResponse(w, auditLog.Query(query))
+}
+
+// exportAuditEntriesHandler returns the audit entries as JSON lines, with the
+// query parameters of listAuditEntriesHandler
+func exportAuditEntriesHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	auditLog, query, ok := auditRequest(w, r)
+	if !ok {
+		return
+	}
+	var b bytes.Buffer
+	if err := auditLog.Export(&b, query); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+}
+
+// auditRequest checks that the caller may read the audit log, which needs the
+// admin role on all projects, and parses the query of a request
+func auditRequest(w http.ResponseWriter, r *http.Request) (*service.AuditLog, service.AuditQuery, bool) {
+	auditLog, _ := r.Context().Value(auditLogKey{}).(*service.AuditLog)
+	if auditLog == nil {
+		writeErrorResponse(w, http.StatusNotFound, "the audit log is disabled")
+		return nil, service.AuditQuery{}, false
+	}
+	if !authorize(w, r, RoleAdmin, AllProjects) {
+		return nil, service.AuditQuery{}, false
+	}
+
+	values := r.URL.Query()
+	query := service.AuditQuery{
+		Subject: values.Get("subject"),
+		Route:   values.Get("route"),
+		Target:  values.Get("target"),
+		Outcome: values.Get("outcome"),
+	}
+	var err error
+	for name, t := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := values.Get(name); value != "" {
+			if *t, err = time.Parse(time.RFC3339, value); err != nil {
+				writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid %s, expected an RFC 3339 time: %q", name, value))
+				return nil, service.AuditQuery{}, false
+			}
+		}
+	}
+	if value := values.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 0 {
+			writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", value))
+			return nil, service.AuditQuery{}, false
+		}
+	}
+	return auditLog, query, true
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golden-sdk/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	policy, err := NewPolicy([]RoleBinding{
+		{Method: AuthMethodAPIKey, Subject: "alice", ProjectID: "project-audit", Role: RoleAdmin},
+		{Method: AuthMethodAPIKey, Subject: "root", ProjectID: AllProjects, Role: RoleAdmin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog := &service.AuditLog{}
+	router := NewRouter(AllRoutes(),
+		WithAuthenticators(NewAPIKeyAuthenticator(map[string]string{"alice": "alice-key", "root": "root-key"})),
+		WithPolicy(policy),
+		WithAuditLog(auditLog))
+	serve := func(key string, method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderAPIKey, key)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	serve("alice-key", "POST", "/resourceManager/createProject", `{"id":"project-audit","resources":[{"type":"S3:Bucket","properties":{"BucketName":"audit-bucket"}}]}`)
+	serve("alice-key", "POST", "/resourceManager/createProject", `{"id":"project-audit-other","resources":[]}`)
+	serve("", "POST", "/resourceManager/destroyProjectResources", `{"projectId":"project-audit"}`)
+	serve("alice-key", "POST", "/workflowManager/importWorkflows", "id: workflow_audit\nprojectId: project-audit\ninput:\n  - name: dbPassword\n    defaultValue: plain-secret\nsteps:\n  - id: zip\n    type: ZipFile\n    input:\n      encryptionKey: plain-secret\ntriggers:\n  - id: trigger-audit\n    type: scheduled\n")
+	serve("alice-key", "POST", "/resourceManager/createProject", `{"id":`)
+	serve("alice-key", "GET", "/resourceManager/listProjects", "")
+
+	entries := auditLog.Query(service.AuditQuery{})
+	want := []struct {
+		subject string
+		route   string
+		targets string
+		outcome string
+	}{
+		{"alice", "CreateProject", "project/project-audit", service.AuditOutcomeSucceeded},
+		{"alice", "CreateProject", "project/project-audit-other", service.AuditOutcomeDenied},
+		{"", "DestroyProjectResources", "", service.AuditOutcomeDenied},
+		{"alice", "ImportWorkflows", "workflow/workflow_audit,trigger/trigger-audit", service.AuditOutcomeSucceeded},
+		{"alice", "CreateProject", "", service.AuditOutcomeFailed},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		targets := make([]string, len(e.Targets))
+		for j, target := range e.Targets {
+			targets[j] = target.Type + "/" + target.ID
+		}
+		if e.Subject != w.subject || e.Route != w.route || strings.Join(targets, ",") != w.targets || e.Outcome != w.outcome {
+			t.Fatalf("Expected entry %d to be %+v, got %+v", i, w, e)
+		}
+	}
+	if entries[1].Status != http.StatusForbidden || !strings.Contains(entries[1].Error, "needs the admin role") {
+		t.Fatalf("Expected the status and the error of the denied request, got %+v", entries[1])
+	}
+
+	// the secrets of the bodies are redacted
+	for _, e := range entries {
+		b, _ := json.Marshal(e.Body)
+		if strings.Contains(string(b), "plain-secret") {
+			t.Fatalf("Expected the secrets to be redacted, got %s", b)
+		}
+	}
+	if b, _ := json.Marshal(entries[3].Body); !strings.Contains(string(b), `"encryptionKey":"[REDACTED]"`) || !strings.Contains(string(b), "trigger-audit") {
+		t.Fatalf("Expected the body with the encryption key redacted, got %s", b)
+	}
+
+	if b, _ := json.Marshal(entries[3].Body); !strings.Contains(string(b), `"name":"dbPassword"`) {
+		t.Fatalf("Expected the name of the secret input to be kept, got %s", b)
+	}
+
+	// the runs started and canceled are targets of the entries
+	rr := serve("alice-key", "POST", "/workflowManager/runWorkflow", `{"workflowId":"workflow_audit"}`)
+	var started struct {
+		Data service.WorkflowRun `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &started); err != nil || started.Data.ID == "" {
+		t.Fatalf("Expected a run to be started, got %d: %s", rr.Code, rr.Body.String())
+	}
+	serve("alice-key", "POST", "/workflowManager/cancelRun", fmt.Sprintf(`{"runId":%q}`, started.Data.ID))
+	runEntries := auditLog.Query(service.AuditQuery{Target: started.Data.ID})
+	if len(runEntries) != 2 || runEntries[0].Route != "RunWorkflow" || runEntries[1].Route != "CancelWorkflowRun" {
+		t.Fatalf("Expected the start and the cancel of the run, got %+v", runEntries)
+	}
+	for _, e := range runEntries {
+		if !containsTarget(e.Targets, service.AuditTarget{Type: "workflow", ID: "workflow_audit"}) {
+			t.Fatalf("Expected the workflow of the run to be a target, got %+v", e.Targets)
+		}
+	}
+
+	if rr := serve("alice-key", "GET", "/audit/listEntries", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected the audit log to need the admin role on all projects, got %d", rr.Code)
+	}
+	rr = serve("root-key", "GET", "/audit/listEntries?subject=alice&outcome=denied", "")
+	var resp struct {
+		Data []service.AuditEntry `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].Route != "CreateProject" {
+		t.Fatalf("Expected the denied request of alice, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve("root-key", "GET", "/audit/listEntries?since=yesterday", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected an invalid time to be rejected, got %d", rr.Code)
+	}
+	rr = serve("root-key", "GET", "/audit/exportEntries?target=project-audit", "")
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if rr.Header().Get("Content-Type") != "application/x-ndjson; charset=UTF-8" || len(lines) != 1 || !strings.Contains(lines[0], `"route":"CreateProject"`) {
+		t.Fatalf("Expected the entry of the project as a JSON line, got %s", rr.Body.String())
+	}
+}
+
+func TestAuditRequestBodyLimit(t *testing.T) {
+	defer func(limit int64) { maxRequestBody = limit }(maxRequestBody)
+	maxRequestBody = 16
+	auditLog := &service.AuditLog{}
+	router := NewRouter(AllRoutes(), WithAuditLog(auditLog))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/resourceManager/createProject", strings.NewReader(`{"id":"project-audit-large","resources":[]}`)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected response code to be 413, got %v", rr.Code)
+	}
+}
+
+func TestRedactNamedEntries(t *testing.T) {
+	body := auditBody([]byte(`{"inputs":[{"name":"password","defaultValue":"plain-secret"},{"name":"dir","defaultValue":"/tmp"}],"variables":[{"name":"api_token","value":"plain-secret","default":"plain-secret"}]}`))
+	b, _ := json.Marshal(body)
+	if strings.Contains(string(b), "plain-secret") || !strings.Contains(string(b), `"defaultValue":"/tmp"`) {
+		t.Fatalf("Expected only the values of the secret entries to be redacted, got %s", b)
+	}
+}
+
+func TestAuditDisabled(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewRouter(AllRoutes()).ServeHTTP(rr, httptest.NewRequest("GET", "/audit/listEntries", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected response code to be 404, got %v", rr.Code)
+	}
+}
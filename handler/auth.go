@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golden-sdk/api"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AuthMethodAPIKey = "api-key"
+	AuthMethodHMAC   = "hmac"
+	AuthMethodJWT    = "jwt"
+
+	// HeaderAPIKey carries a static API key, which may also be sent as a
+	// bearer token
+	HeaderAPIKey = "X-API-Key"
+	// HeaderKeyID, HeaderTimestamp and HeaderSignature carry the signature of
+	// a request, see api.HMACSignature
+	HeaderKeyID     = api.HeaderKeyID
+	HeaderTimestamp = api.HeaderTimestamp
+	HeaderSignature = api.HeaderSignature
+
+	// maxClockSkew is the maximum age of a signed request, and the leeway of
+	// the JWT time claims
+	maxClockSkew = 5 * time.Minute
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// none of the credentials it checks
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Subject names the caller, e.g. the name of an API key or the sub claim
+	// of a JWT
+	Subject string `json:"subject"`
+	// Method is AuthMethodAPIKey, AuthMethodHMAC or AuthMethodJWT
+	Method string `json:"method"`
+	// Claims are the claims of a JWT
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Authenticator returns the identity of the caller of a request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the caller of a request authenticated by the
+// router, it is nil when authentication is disabled
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// publicRoutes are served without authentication
+var publicRoutes = map[string]bool{"Index": true}
+
+// authenticate serves the requests authenticated by one of auths, the first
+// which finds its credentials in a request decides
+func authenticate(auths []Authenticator, fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+		for _, auth := range auths {
+			identity, err := auth.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				writeErrorResponse(w, http.StatusUnauthorized, fmt.Sprintf("invalid credentials: %v", err))
+				return
+			}
+			auditIdentity(r, identity)
+			fn(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)), param)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="golden-sdk"`)
+		writeErrorResponse(w, http.StatusUnauthorized, "authentication required")
+	}
+}
+
+// bearerToken returns the token of an Authorization: Bearer header
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type apiKey struct {
+	name string
+	sum  [sha256.Size]byte
+}
+
+type apiKeyAuthenticator struct {
+	keys []apiKey
+}
+
+// NewAPIKeyAuthenticator checks static API keys, given by name, in the
+// X-API-Key header or as bearer tokens
+func NewAPIKeyAuthenticator(keys map[string]string) Authenticator {
+	a := &apiKeyAuthenticator{}
+	for name, key := range keys {
+		a.keys = append(a.keys, apiKey{name: name, sum: sha256.Sum256([]byte(key))})
+	}
+	return a
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	bearer := key == ""
+	if bearer {
+		key = bearerToken(r)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	// the hashes have the same length, so the comparisons take the same time
+	// whatever the key
+	sum := sha256.Sum256([]byte(key))
+	var identity *Identity
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.sum[:]) == 1 {
+			identity = &Identity{Subject: k.name, Method: AuthMethodAPIKey}
+		}
+	}
+	if identity != nil {
+		return identity, nil
+	}
+	if bearer {
+		// the token may be checked by another authenticator, e.g. a JWT
+		return nil, ErrNoCredentials
+	}
+	return nil, fmt.Errorf("unknown API key")
+}
+
+type hmacAuthenticator struct {
+	secrets map[string]string
+	now     func() time.Time
+}
+
+// NewHMACAuthenticator checks the requests signed with the secrets, given by
+// key ID, see api.HMACSignature
+func NewHMACAuthenticator(secrets map[string]string) Authenticator {
+	return &hmacAuthenticator{secrets: secrets, now: time.Now}
+}
+
+func (a *hmacAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	keyID := r.Header.Get(HeaderKeyID)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+	secret, ok := a.secrets[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", keyID)
+	}
+	timestamp := r.Header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header", HeaderTimestamp)
+	}
+	if age := a.now().Sub(time.Unix(seconds, 0)); age > maxClockSkew || age < -maxClockSkew {
+		return nil, fmt.Errorf("request signed at %s is outside of the allowed clock skew", time.Unix(seconds, 0).UTC().Format(time.RFC3339))
+	}
+
+	// the body is read to be signed, then restored for the handler
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, fmt.Errorf("error when reading body: %v", err)
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	signature, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header", HeaderSignature)
+	}
+	expected, _ := hex.DecodeString(api.HMACSignature(secret, r.Method, r.URL.RequestURI(), timestamp, body))
+	if !hmac.Equal(signature, expected) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	return &Identity{Subject: keyID, Method: AuthMethodHMAC}, nil
+}
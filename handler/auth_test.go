@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golden-sdk/api"
+	"github.com/julienschmidt/httprouter"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// whoami responds with the subject of the caller
+func whoami(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	identity := IdentityFromContext(r.Context())
+	writeOKResponse(w, identity.Method+":"+identity.Subject)
+}
+
+func serveAuth(auths []Authenticator, req *http.Request) *httptest.ResponseRecorder {
+	router := NewRouter(Routes{
+		Route{"Index", "GET", "/", index},
+		Route{"WhoAmI", "GET", "/whoami", whoami},
+		Route{"Echo", "POST", "/echo", whoami},
+	}, WithAuthenticators(auths...))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAuthenticate(t *testing.T) {
+	auths := []Authenticator{NewAPIKeyAuthenticator(map[string]string{"ci": "secret-key"})}
+
+	rr := serveAuth(auths, httptest.NewRequest("GET", "/whoami", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected response code to be 401, got %v", rr.Code)
+	}
+	var errResp JsonErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &errResp); err != nil || errResp.Error == nil || errResp.Error.Status != http.StatusUnauthorized {
+		t.Fatalf("Expected a JsonErrorResponse, got %s", rr.Body.String())
+	}
+	if rr := serveAuth(auths, httptest.NewRequest("GET", "/", nil)); rr.Code != http.StatusOK {
+		t.Fatalf("Expected the index to be public, got %v", rr.Code)
+	}
+
+	tests := []struct {
+		header string
+		value  string
+		want   int
+	}{
+		{HeaderAPIKey, "secret-key", http.StatusOK},
+		{"Authorization", "Bearer secret-key", http.StatusOK},
+		{HeaderAPIKey, "wrong-key", http.StatusUnauthorized},
+		{"Authorization", "Bearer wrong-key", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		req.Header.Set(test.header, test.value)
+		rr := serveAuth(auths, req)
+		if rr.Code != test.want {
+			t.Fatalf("Expected %s: %s to get %d, got %d", test.header, test.value, test.want, rr.Code)
+		}
+		if test.want == http.StatusOK && !strings.Contains(rr.Body.String(), "api-key:ci") {
+			t.Fatalf("Expected the identity of the key, got %s", rr.Body.String())
+		}
+	}
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	auths := []Authenticator{NewHMACAuthenticator(map[string]string{"deploy": "shared-secret"})}
+	sign := func(body string, signedBody string, at time.Time) *http.Request {
+		req := httptest.NewRequest("POST", "/echo?dryRun=true", strings.NewReader(body))
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		req.Header.Set(HeaderKeyID, "deploy")
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, api.HMACSignature("shared-secret", "POST", "/echo?dryRun=true", timestamp, []byte(signedBody)))
+		return req
+	}
+
+	rr := serveAuth(auths, sign(`{"id":"a"}`, `{"id":"a"}`, time.Now()))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "hmac:deploy") {
+		t.Fatalf("Expected the signed request to be accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serveAuth(auths, sign(`{"id":"b"}`, `{"id":"a"}`, time.Now())); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected a tampered body to be rejected, got %d", rr.Code)
+	}
+	if rr := serveAuth(auths, sign(`{"id":"a"}`, `{"id":"a"}`, time.Now().Add(-time.Hour))); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected an old signature to be rejected, got %d", rr.Code)
+	}
+}
+
+// testKeys writes a JWKS file with an RSA key and a P-256 key
+func testKeys(t *testing.T) (string, *rsa.PrivateKey, *ecdsa.PrivateKey) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}}
+	b, _ := json.Marshal(jwks)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file, rsaKey, ecKey
+}
+
+func signJWT(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	file, rsaKey, ecKey := testKeys(t)
+	auth, err := NewJWTAuthenticator(JWTConfig{JWKSFile: file, Issuer: "https://idp.example.com", Audience: "golden-sdk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// API keys are checked first, JWT bearer tokens are left to the next
+	// authenticator
+	auths := []Authenticator{NewAPIKeyAuthenticator(map[string]string{"ci": "secret-key"}), auth}
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://idp.example.com",
+			"aud": []string{"other", "golden-sdk"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"RS256", signJWT(t, "RS256", "rsa-1", rsaKey, claims(nil)), http.StatusOK},
+		{"ES256", signJWT(t, "ES256", "ec-1", ecKey, claims(nil)), http.StatusOK},
+		{"expired", signJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), http.StatusUnauthorized},
+		{"no expiry", signJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"exp": nil})), http.StatusUnauthorized},
+		{"audience", signJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"aud": "other"})), http.StatusUnauthorized},
+		{"issuer", signJWT(t, "ES256", "ec-1", ecKey, claims(map[string]interface{}{"iss": "https://evil.example.com"})), http.StatusUnauthorized},
+		{"algorithm of another key type", signJWT(t, "RS256", "ec-1", rsaKey, claims(nil)), http.StatusUnauthorized},
+		{"unknown key", signJWT(t, "RS256", "rsa-2", rsaKey, claims(nil)), http.StatusUnauthorized},
+		{"tampered", signJWT(t, "RS256", "rsa-1", rsaKey, claims(nil))[1:], http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			rr := serveAuth(auths, req)
+			if rr.Code != test.want {
+				t.Fatalf("Expected response code to be %d, got %d: %s", test.want, rr.Code, rr.Body.String())
+			}
+			if test.want == http.StatusOK && !strings.Contains(rr.Body.String(), "jwt:alice") {
+				t.Fatalf("Expected the subject of the token, got %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/golden-sdk/service"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sort"
+)
+
+// Role is a set of permissions on the projects, each role includes the
+// permissions of the previous ones
+type Role string
+
+const (
+	// RoleViewer reads the projects, workflows and triggers
+	RoleViewer Role = "viewer"
+	// RoleOperator runs and cancels workflows, and pauses triggers
+	RoleOperator Role = "operator"
+	// RoleAdmin creates, updates, deletes and provisions
+	RoleAdmin Role = "admin"
+
+	// AllProjects grants a role on every project, and on the workflows which
+	// are not linked to a project
+	AllProjects = "*"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// RoleBinding grants a role on a project to the subject of an Identity
+// authenticated by Method. The names of the API keys, the HMAC key IDs and the
+// JWT subjects are distinct namespaces, so a binding names one of them.
+type RoleBinding struct {
+	Method    string
+	Subject   string
+	ProjectID string
+	Role      Role
+}
+
+// Policy holds the roles of the subjects by project, the subjects are keyed by
+// their authentication method
+type Policy struct {
+	roles map[string]map[string]Role
+}
+
+// NewPolicy returns the policy of bindings, a subject gets the highest role
+// granted on a project
+func NewPolicy(bindings []RoleBinding) (*Policy, error) {
+	p := &Policy{roles: make(map[string]map[string]Role)}
+	for _, b := range bindings {
+		if b.Subject == "" || b.ProjectID == "" {
+			return nil, fmt.Errorf("a role binding needs a subject and a project")
+		}
+		switch b.Method {
+		case AuthMethodAPIKey, AuthMethodHMAC, AuthMethodJWT:
+		default:
+			return nil, fmt.Errorf("unsupported authentication method %q of %s, expected %s, %s or %s", b.Method, b.Subject, AuthMethodAPIKey, AuthMethodHMAC, AuthMethodJWT)
+		}
+		if _, ok := roleRanks[b.Role]; !ok {
+			return nil, fmt.Errorf("unsupported role %q of %s", b.Role, b.Subject)
+		}
+		key := subjectKey(b.Method, b.Subject)
+		if p.roles[key] == nil {
+			p.roles[key] = make(map[string]Role)
+		}
+		if roleRanks[b.Role] > roleRanks[p.roles[key][b.ProjectID]] {
+			p.roles[key][b.ProjectID] = b.Role
+		}
+	}
+	return p, nil
+}
+
+func subjectKey(method string, subject string) string {
+	return method + ":" + subject
+}
+
+// Allows tells whether identity has role on a project, the workflows which
+// are not linked to a project need a role on AllProjects
+func (p *Policy) Allows(identity *Identity, role Role, projectID string) bool {
+	if identity == nil {
+		return false
+	}
+	projects := p.roles[subjectKey(identity.Method, identity.Subject)]
+	granted := roleRanks[projects[AllProjects]]
+	if projectID != "" && roleRanks[projects[projectID]] > granted {
+		granted = roleRanks[projects[projectID]]
+	}
+	return granted >= roleRanks[role]
+}
+
+type policyKey struct{}
+
+// withPolicy makes the policy available to the handlers of the requests
+func withPolicy(policy *Policy, fn httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+		fn(w, r.WithContext(context.WithValue(r.Context(), policyKey{}, policy)), param)
+	}
+}
+
+// allowed tells whether the caller of a request has role on a project, every
+// request is allowed when the router has no policy
+func allowed(r *http.Request, role Role, projectID string) bool {
+	policy, _ := r.Context().Value(policyKey{}).(*Policy)
+	return policy == nil || policy.Allows(IdentityFromContext(r.Context()), role, projectID)
+}
+
+// authorize writes a 403 response unless the caller of a request has role on
+// all of the projects
+func authorize(w http.ResponseWriter, r *http.Request, role Role, projectIDs ...string) bool {
+	for _, projectID := range projectIDs {
+		if allowed(r, role, projectID) {
+			continue
+		}
+		subject := "anonymous"
+		if identity := IdentityFromContext(r.Context()); identity != nil {
+			subject = identity.Subject
+		}
+		target := fmt.Sprintf("project %s", projectID)
+		if projectID == "" {
+			target = "the workflows without a project"
+		} else if projectID == AllProjects {
+			target = "all projects"
+		}
+		writeErrorResponse(w, http.StatusForbidden, fmt.Sprintf("%s needs the %s role on %s", subject, role, target))
+		return false
+	}
+	return true
+}
+
+// workflowProject returns the project of a workflow, or an empty ID when the
+// workflow does not exist or is not linked to a project
+func workflowProject(workflowID string) string {
+	workflow, err := wm.GetWorkflow(baseCtx, workflowID)
+	if err != nil {
+		return ""
+	}
+	return workflow.ProjectID
+}
+
+// workflowDefinitionProjects returns the projects a workflow definition is
+// linked to, which are its project and the one of the existing workflow it
+// replaces
+func workflowDefinitionProjects(definition service.WorkflowDefinition) []string {
+	projects := []string{definition.ProjectID}
+	if workflow, err := wm.GetWorkflow(baseCtx, definition.ID); err == nil && workflow.ProjectID != definition.ProjectID {
+		projects = append(projects, workflow.ProjectID)
+	}
+	return projects
+}
+
+// triggerProjects returns the projects a trigger definition is linked to, which
+// are the project of its workflow and the one of the workflow of the existing
+// trigger it replaces
+func triggerProjects(triggerID string, projectID string) []string {
+	projects := []string{projectID}
+	if trigger, err := wm.GetWorkflowTrigger(baseCtx, triggerID); err == nil {
+		if existing := workflowProject(trigger.WorkflowID); existing != projectID {
+			projects = append(projects, existing)
+		}
+	}
+	return projects
+}
+
+// authorizeNotFound writes a 403 response unless the caller has role on all
+// projects, so that answering that an object is not found does not tell the
+// other callers what the projects they may not see hold
+func authorizeNotFound(w http.ResponseWriter, r *http.Request, role Role) bool {
+	return authorize(w, r, role, AllProjects)
+}
+
+// definitionProjects returns the projects changed by applying definitions, a
+// prune may delete anything so it changes all projects
+func definitionProjects(definitions service.Definitions, prune bool) []string {
+	if prune {
+		return []string{AllProjects}
+	}
+	projects := make(map[string]bool)
+	for _, p := range definitions.Projects {
+		projects[p.ID] = true
+	}
+	defined := make(map[string]string, len(definitions.Workflows))
+	for _, w := range definitions.Workflows {
+		defined[w.ID] = w.ProjectID
+		for _, projectID := range workflowDefinitionProjects(w) {
+			projects[projectID] = true
+		}
+		for _, t := range w.Triggers {
+			for _, projectID := range triggerProjects(t.ID, w.ProjectID) {
+				projects[projectID] = true
+			}
+		}
+	}
+	for _, t := range definitions.Triggers {
+		projectID, ok := defined[t.WorkflowID]
+		if !ok {
+			projectID = workflowProject(t.WorkflowID)
+		}
+		for _, projectID := range triggerProjects(t.ID, projectID) {
+			projects[projectID] = true
+		}
+	}
+	ids := make([]string, 0, len(projects))
+	for id := range projects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	policy, err := NewPolicy([]RoleBinding{
+		{Method: AuthMethodAPIKey, Subject: "alice", ProjectID: "project-rbac-a", Role: RoleAdmin},
+		{Method: AuthMethodAPIKey, Subject: "bob", ProjectID: "project-rbac-a", Role: RoleViewer},
+		{Method: AuthMethodAPIKey, Subject: "bob", ProjectID: "project-rbac-b", Role: RoleAdmin},
+		{Method: AuthMethodAPIKey, Subject: "carol", ProjectID: "project-rbac-a", Role: RoleOperator},
+		{Method: AuthMethodAPIKey, Subject: "root", ProjectID: AllProjects, Role: RoleAdmin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(AllRoutes(),
+		WithAuthenticators(NewAPIKeyAuthenticator(map[string]string{"alice": "alice-key", "bob": "bob-key", "root": "root-key", "carol": "carol-key", "eve": "eve-key"})),
+		WithPolicy(policy))
+	serve := func(key string, method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(HeaderAPIKey, key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"admin creates its project", "alice-key", "POST", "/resourceManager/createProject", `{"id":"project-rbac-a","resources":[]}`, http.StatusOK},
+		{"admin of another project", "alice-key", "POST", "/resourceManager/createProject", `{"id":"project-rbac-b","resources":[]}`, http.StatusForbidden},
+		{"admin of all projects", "root-key", "POST", "/resourceManager/createProject", `{"id":"project-rbac-b","resources":[]}`, http.StatusOK},
+		{"viewer creates a workflow", "bob-key", "POST", "/workflowManager/createWorkflow", `{"id":"workflow_rbac_a","projectId":"project-rbac-a"}`, http.StatusForbidden},
+		{"admin creates a workflow", "alice-key", "POST", "/workflowManager/createWorkflow", `{"id":"workflow_rbac_a","projectId":"project-rbac-a"}`, http.StatusOK},
+		{"workflow moved to another project", "bob-key", "POST", "/workflowManager/createWorkflow", `{"id":"workflow_rbac_a","projectId":"project-rbac-b"}`, http.StatusForbidden},
+		{"workflow without a project", "alice-key", "POST", "/workflowManager/createWorkflow", `{"id":"workflow_rbac_none"}`, http.StatusForbidden},
+		{"viewer exports a workflow", "bob-key", "GET", "/workflowManager/exportWorkflow?id=workflow_rbac_a", "", http.StatusOK},
+		{"export without a role", "eve-key", "GET", "/workflowManager/exportWorkflow?id=workflow_rbac_a", "", http.StatusForbidden},
+		{"viewer creates a trigger", "bob-key", "POST", "/workflowTriggerManager/createTrigger", `{"id":"trigger-rbac","workflowId":"workflow_rbac_a"}`, http.StatusForbidden},
+		{"admin creates a trigger", "alice-key", "POST", "/workflowTriggerManager/createTrigger", `{"id":"trigger-rbac","workflowId":"workflow_rbac_a"}`, http.StatusOK},
+		{"admin of another project creates a workflow", "bob-key", "POST", "/workflowManager/createWorkflow", `{"id":"workflow_rbac_b","projectId":"project-rbac-b"}`, http.StatusOK},
+		{"trigger of another project replaced", "bob-key", "POST", "/workflowTriggerManager/createTrigger", `{"id":"trigger-rbac","workflowId":"workflow_rbac_b"}`, http.StatusForbidden},
+		{"trigger of another project imported", "bob-key", "POST", "/workflowManager/importWorkflows", "id: workflow_rbac_b\nprojectId: project-rbac-b\ntriggers:\n  - id: trigger-rbac\n    type: scheduled\n", http.StatusForbidden},
+		{"trigger of another project applied", "bob-key", "POST", "/definitions/apply?dryRun=true", "id: workflow_rbac_b\nprojectId: project-rbac-b\ntriggers:\n  - id: trigger-rbac\n    type: scheduled\n", http.StatusForbidden},
+		{"unknown workflow exported", "alice-key", "GET", "/workflowManager/exportWorkflow?id=workflow_rbac_unknown", "", http.StatusForbidden},
+		{"unknown workflow exported by a global admin", "root-key", "GET", "/workflowManager/exportWorkflow?id=workflow_rbac_unknown", "", http.StatusNotFound},
+		{"unknown run", "carol-key", "GET", "/workflowManager/getRun?id=unknown", "", http.StatusForbidden},
+		{"unknown run logs", "carol-key", "GET", "/workflowManager/getRunLogs?id=unknown", "", http.StatusForbidden},
+		{"unknown run canceled", "carol-key", "POST", "/workflowManager/cancelRun", `{"runId":"unknown"}`, http.StatusForbidden},
+		{"unknown trigger paused", "carol-key", "POST", "/workflowTriggerManager/pauseTrigger", `{"id":"unknown"}`, http.StatusForbidden},
+		{"unknown run of a global admin", "root-key", "GET", "/workflowManager/getRun?id=unknown", "", http.StatusNotFound},
+		{"operator creates a trigger", "carol-key", "POST", "/workflowTriggerManager/createTrigger", `{"id":"trigger-rbac","workflowId":"workflow_rbac_a"}`, http.StatusForbidden},
+		{"viewer runs a workflow", "bob-key", "POST", "/workflowManager/runWorkflow", `{"workflowId":"workflow_rbac_a"}`, http.StatusForbidden},
+		{"operator runs a workflow", "carol-key", "POST", "/workflowManager/runWorkflow", `{"workflowId":"workflow_rbac_a"}`, http.StatusOK},
+		{"viewer pauses a trigger", "bob-key", "POST", "/workflowTriggerManager/pauseTrigger", `{"id":"trigger-rbac"}`, http.StatusForbidden},
+		{"operator pauses a trigger", "carol-key", "POST", "/workflowTriggerManager/pauseTrigger", `{"id":"trigger-rbac"}`, http.StatusOK},
+		{"import named after its project", "alice-key", "POST", "/resourceManager/importTemplate", `{"name":"project-rbac-a","template":"Resources:\n  Logs:\n    Type: AWS::S3::Bucket\n","dryRun":true}`, http.StatusOK},
+		{"import named after another project", "alice-key", "POST", "/resourceManager/importTemplate", `{"name":"project-rbac-b","template":"Resources:\n  Logs:\n    Type: AWS::S3::Bucket\n","dryRun":true}`, http.StatusForbidden},
+		{"apply to its project", "alice-key", "POST", "/definitions/apply?dryRun=true", "id: workflow_rbac_a\nprojectId: project-rbac-a\n", http.StatusOK},
+		{"prune needs all projects", "alice-key", "POST", "/definitions/apply?dryRun=true&prune=true", "id: workflow_rbac_a\nprojectId: project-rbac-a\n", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		rr := serve(test.key, test.method, test.path, test.body)
+		if rr.Code != test.want {
+			t.Fatalf("%s: expected response code to be %d, got %d: %s", test.name, test.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	// a run is canceled by the operators of its project
+	rr := serve("carol-key", "POST", "/workflowManager/runWorkflow", `{"workflowId":"workflow_rbac_a"}`)
+	var started struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &started); err != nil || started.Data.ID == "" {
+		t.Fatalf("Expected a run to be started, got %d: %s", rr.Code, rr.Body.String())
+	}
+	cancel := fmt.Sprintf(`{"runId":%q}`, started.Data.ID)
+	if rr := serve("bob-key", "POST", "/workflowManager/cancelRun", cancel); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected the viewer not to cancel the run, got %d", rr.Code)
+	}
+	if rr := serve("carol-key", "POST", "/workflowManager/cancelRun", cancel); rr.Code == http.StatusForbidden {
+		t.Fatalf("Expected the operator to cancel the run, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// the lists only hold what the caller may view
+	if rr := serve("bob-key", "GET", "/resourceManager/listProjects", ""); !strings.Contains(rr.Body.String(), "project-rbac-a") || !strings.Contains(rr.Body.String(), "project-rbac-b") {
+		t.Fatalf("Expected both projects to be listed, got %s", rr.Body.String())
+	}
+	if rr := serve("alice-key", "GET", "/resourceManager/listProjects", ""); strings.Contains(rr.Body.String(), "project-rbac-b") {
+		t.Fatalf("Expected the other project to be hidden, got %s", rr.Body.String())
+	}
+	rr = serve("eve-key", "GET", "/workflowManager/listWorkflows", "")
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "workflow_rbac_a") {
+		t.Fatalf("Expected no workflow to be listed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve("bob-key", "GET", "/workflowTriggerManager/listTriggers", ""); !strings.Contains(rr.Body.String(), "trigger-rbac") {
+		t.Fatalf("Expected the trigger of the project to be listed, got %s", rr.Body.String())
+	}
+	if rr := serve("eve-key", "GET", "/workflowTriggerManager/listTriggers", ""); strings.Contains(rr.Body.String(), "trigger-rbac") {
+		t.Fatalf("Expected the trigger to be hidden, got %s", rr.Body.String())
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	if _, err := NewPolicy([]RoleBinding{{Method: AuthMethodAPIKey, Subject: "alice", ProjectID: "project-001", Role: "owner"}}); err == nil {
+		t.Fatalf("Expected an unsupported role to be rejected")
+	}
+	if _, err := NewPolicy([]RoleBinding{{Subject: "alice", ProjectID: "project-001", Role: RoleViewer}}); err == nil {
+		t.Fatalf("Expected a binding without a method to be rejected")
+	}
+	policy, err := NewPolicy([]RoleBinding{
+		{Method: AuthMethodAPIKey, Subject: "alice", ProjectID: "project-001", Role: RoleOperator},
+		{Method: AuthMethodAPIKey, Subject: "alice", ProjectID: "project-001", Role: RoleViewer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &Identity{Subject: "alice", Method: AuthMethodAPIKey}
+	if !policy.Allows(alice, RoleViewer, "project-001") || !policy.Allows(alice, RoleOperator, "project-001") {
+		t.Fatalf("Expected the operator role to include the viewer role")
+	}
+	if policy.Allows(alice, RoleAdmin, "project-001") || policy.Allows(alice, RoleViewer, "project-002") || policy.Allows(nil, RoleViewer, "project-001") {
+		t.Fatalf("Expected the roles to be limited to the bindings")
+	}
+	// an HMAC key ID or a JWT subject named after the API key gets no role
+	for _, method := range []string{AuthMethodHMAC, AuthMethodJWT} {
+		if policy.Allows(&Identity{Subject: "alice", Method: method}, RoleViewer, "project-001") {
+			t.Fatalf("Expected the %s subject alice not to get the roles of the API key", method)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golden-sdk/service"
 	"github.com/julienschmidt/httprouter"
@@ -14,23 +15,107 @@ import (
 
 func init() {
 	rm = service.ResourceManager{}
-	wm = service.WorkflowManager{}
+	wm = service.WorkflowManager{Projects: &rm}
+	engine = service.WorkflowEngine{Workflows: &wm}
 }
 
 var rm service.ResourceManager
 var wm service.WorkflowManager
 
+// engine runs the workflows started by the RunWorkflow route
+var engine service.WorkflowEngine
+
+// baseCtx is the context of the operations started by the handlers. It is not
+// canceled when a client disconnects, so that a provisioning is not left half
+// done, but when the server gives up waiting for them at shutdown.
+var baseCtx = context.Background()
+
+// SetContext sets the context of the operations started by the handlers,
+// canceling it interrupts the operations in progress
+func SetContext(ctx context.Context) {
+	baseCtx = ctx
+}
+
+// SetClientProvider sets the provider of the AWS clients used by the managers
+func SetClientProvider(clients service.ClientProvider) {
+	rm.Clients = clients
+	wm.Clients = clients
+}
+
+// Engine returns the engine of the workflow runs started by the handlers, to
+// be shut down with the server
+func Engine() *service.WorkflowEngine {
+	return &engine
+}
+
+// NewScheduler returns a scheduler of the triggers managed by the handlers,
+// which starts their runs with the engine and the context of the handlers
+func NewScheduler(interval time.Duration, maxConcurrentRuns int) *service.Scheduler {
+	return &service.Scheduler{
+		Workflows:         &wm,
+		Engine:            &engine,
+		Interval:          interval,
+		MaxConcurrentRuns: maxConcurrentRuns,
+		Context:           baseCtx,
+	}
+}
+
 func index(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+var logLevels = map[string]int{LogLevelDebug: 0, LogLevelInfo: 1, LogLevelWarn: 2, LogLevelError: 3}
+
+var logLevel = logLevels[LogLevelInfo]
+
+// SetLogLevel filters the request logs: debug logs the start of the requests,
+// info their end, warn the requests which failed with a 4xx status and error
+// the ones which failed with a 5xx status
+func SetLogLevel(level string) error {
+	l, ok := logLevels[level]
+	if !ok {
+		return fmt.Errorf("unsupported log level %q", level)
+	}
+	logLevel = l
+	return nil
+}
+
+// statusWriter records the status of a response for the logs
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
 // A Logger function which simply wraps the handler function around some log messages
 func logger(fn func(w http.ResponseWriter, r *http.Request, param httprouter.Params)) func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
 	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
 		start := time.Now()
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		fn(w, r, param)
-		log.Printf("Done in %v (%s %s)", time.Since(start), r.Method, r.URL.Path)
+		if logLevel <= logLevels[LogLevelDebug] {
+			log.Printf("%s %s", r.Method, r.URL.Path)
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		fn(sw, r, param)
+		level := LogLevelInfo
+		if sw.status >= 500 {
+			level = LogLevelError
+		} else if sw.status >= 400 {
+			level = LogLevelWarn
+		}
+		if logLevel <= logLevels[level] {
+			log.Printf("Done in %v with %d (%s %s)", time.Since(start), sw.status, r.Method, r.URL.Path)
+		}
 	}
 }
 
@@ -40,11 +125,19 @@ func createProjectHandler(w http.ResponseWriter, r *http.Request, param httprout
 		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable create project input")
 		return
 	}
+	auditTargets(r, "project", input.ID)
+	if !authorize(w, r, RoleAdmin, input.ID) {
+		return
+	}
 
 	resourceManager := &rm
 	_ = resourceManager
-	if err := rm.CreateProject(context.Background(), input); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to create project")
+	if err := rm.CreateProject(baseCtx, input); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrProjectExists) {
+			status = http.StatusConflict
+		}
+		writeErrorResponse(w, status, fmt.Sprintf("failed to create project: %v", err))
 		return
 	}
 	writeOKResponse(w, *input)
@@ -56,23 +149,77 @@ func createProjectResourcesHandler(w http.ResponseWriter, r *http.Request, param
 		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable create project resources input")
 		return
 	}
+	auditTargets(r, "project", input.ProjectID)
+	if !authorize(w, r, RoleAdmin, input.ProjectID) {
+		return
+	}
 
-	if err := rm.CreateProjectResources(context.Background(), input); err != nil {
+	out, err := rm.CreateProjectResources(baseCtx, input)
+	if err != nil {
 		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to create project resources: %v", err))
 		return
 	}
-	writeOKResponse(w, *input)
+	writeOKResponse(w, out)
+}
+
+func destroyProjectResourcesHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	input := &service.DestroyProjectResourcesInput{}
+	if err := populateModelFromHandler(w, r, param, input); err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable destroy project resources input")
+		return
+	}
+	auditTargets(r, "project", input.ProjectID)
+	if !authorize(w, r, RoleAdmin, input.ProjectID) {
+		return
+	}
+
+	out, err := rm.DestroyProjectResources(baseCtx, input)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to destroy project resources: %v", err))
+		return
+	}
+	writeOKResponse(w, out)
+}
+
+func importTemplateHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	input := &service.ImportTemplateInput{}
+	if err := populateModelFromHandler(w, r, param, input); err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable import template input")
+		return
+	}
+	// the project ID defaults to the name
+	projectID := input.ProjectID
+	if projectID == "" {
+		projectID = input.Name
+	}
+	auditTargets(r, "project", projectID)
+	if !authorize(w, r, RoleAdmin, projectID) {
+		return
+	}
+
+	out, err := rm.ImportTemplate(baseCtx, input)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to import template: %v", err))
+		return
+	}
+	writeOKResponse(w, out)
 }
 
 func listProjectsHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
 	resourceManager := &rm
 	_ = resourceManager
-	projects, err := rm.ListProjects(context.Background())
+	projects, err := rm.ListProjects(baseCtx)
 	if err != nil {
 		writeErrorResponse(w, http.StatusBadRequest, "failed to list projects")
 		return
 	}
-	writeListProjectsOKResponse(w, projects)
+	visible := make([]service.Project, 0, len(projects))
+	for _, p := range projects {
+		if allowed(r, RoleViewer, p.ID) {
+			visible = append(visible, p)
+		}
+	}
+	writeListProjectsOKResponse(w, visible)
 }
 
 func createWorkflowHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
@@ -81,25 +228,140 @@ func createWorkflowHandler(w http.ResponseWriter, r *http.Request, param httprou
 		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable create workflow input")
 		return
 	}
+	auditTargets(r, "workflow", input.ID)
+	if !authorize(w, r, RoleAdmin, workflowDefinitionProjects(service.WorkflowDefinition{CreateWorkflowInput: *input})...) {
+		return
+	}
 
 	workflowManager := &wm
 	_ = workflowManager
-	output, err := wm.CreateWorkflow(context.Background(), input)
+	output, err := wm.CreateWorkflow(baseCtx, input)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to create workflow")
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to create workflow: %v", err))
 		return
 	}
 	writeOKResponse(w, output)
 }
 
 func listWorkflowsHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
-	out, err := wm.ListWorkflows()
+	var out service.ListWorkflowsOutput
+	var err error
+	// the workflows of a project are listed with ?projectId=
+	if projectID := r.URL.Query().Get("projectId"); projectID != "" {
+		out, err = wm.ListProjectWorkflows(baseCtx, projectID)
+	} else {
+		out, err = wm.ListWorkflows()
+	}
 	if err != nil {
 		writeErrorResponse(w, http.StatusBadRequest, "failed to list workflows")
 		return
 	}
 
-	writeOKResponse(w, out.Workflows)
+	workflows := make([]service.Workflow, 0, len(out.Workflows))
+	for _, workflow := range out.Workflows {
+		if allowed(r, RoleViewer, workflow.ProjectID) {
+			workflows = append(workflows, workflow)
+		}
+	}
+	writeOKResponse(w, workflows)
+}
+
+// applyHandler applies a YAML or JSON document of project, workflow and
+// trigger definitions, ?dryRun=true returns the plan only and ?prune=true
+// deletes what is not defined
+func applyHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable apply input")
+		return
+	}
+	definitions, err := service.ParseDefinitions(body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("Unprocessable apply input: %v", err))
+		return
+	}
+	input := &service.ApplyInput{
+		Definitions: definitions,
+		DryRun:      r.URL.Query().Get("dryRun") == "true",
+		Prune:       r.URL.Query().Get("prune") == "true",
+	}
+	auditDefinitions(r, definitions)
+	if !authorize(w, r, RoleAdmin, definitionProjects(definitions, input.Prune)...) {
+		return
+	}
+
+	out, err := wm.Apply(baseCtx, input)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to apply definitions: %v", err))
+		return
+	}
+	writeOKResponse(w, out)
+}
+
+// exportWorkflowHandler returns the definition file of a workflow, given by
+// ?id=, as YAML or as JSON with ?format=json
+func exportWorkflowHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	definition, err := wm.ExportWorkflow(baseCtx, r.URL.Query().Get("id"))
+	if err != nil {
+		if !authorizeNotFound(w, r, RoleViewer) {
+			return
+		}
+		writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("failed to export workflow: %v", err))
+		return
+	}
+	if !authorize(w, r, RoleViewer, definition.ProjectID) {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	document, err := service.MarshalWorkflowDefinition(definition, format)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to export workflow: %v", err))
+		return
+	}
+
+	contentType := "application/yaml; charset=UTF-8"
+	if format == service.DefinitionFormatJSON {
+		contentType = "application/json; charset=UTF-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(document)
+}
+
+// importWorkflowsHandler creates the workflows of a YAML or JSON definition
+// document
+func importWorkflowsHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable import workflows input")
+		return
+	}
+	definitions, err := service.ParseWorkflowDefinitions(body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("Unprocessable import workflows input: %v", err))
+		return
+	}
+	projects := make([]string, 0, len(definitions))
+	for _, definition := range definitions {
+		auditTargets(r, "workflow", definition.ID)
+		for _, trigger := range definition.Triggers {
+			auditTargets(r, "trigger", trigger.ID)
+		}
+		projects = append(projects, workflowDefinitionProjects(definition)...)
+		for _, trigger := range definition.Triggers {
+			projects = append(projects, triggerProjects(trigger.ID, definition.ProjectID)...)
+		}
+	}
+	if !authorize(w, r, RoleAdmin, projects...) {
+		return
+	}
+
+	out, err := wm.ImportWorkflows(baseCtx, definitions)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to import workflows: %v", err))
+		return
+	}
+	writeOKResponse(w, out)
 }
 
 func createWorkflowTriggerHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
@@ -108,10 +370,15 @@ func createWorkflowTriggerHandler(w http.ResponseWriter, r *http.Request, param
 		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable create workflow trigger input")
 		return
 	}
+	auditTargets(r, "trigger", input.ID)
+	auditTargets(r, "workflow", input.WorkflowID)
+	if !authorize(w, r, RoleAdmin, triggerProjects(input.ID, workflowProject(input.WorkflowID))...) {
+		return
+	}
 
 	workflowManager := &wm
 	_ = workflowManager
-	err := wm.CreateWorkflowTrigger(context.Background(), input)
+	err := wm.CreateWorkflowTrigger(baseCtx, input)
 	if err != nil {
 		writeErrorResponse(w, http.StatusBadRequest, "failed to create workflow trigger")
 		return
@@ -126,7 +393,129 @@ func listWorkflowTriggersHandler(w http.ResponseWriter, r *http.Request, param h
 		return
 	}
 
-	writeOKResponse(w, out.Triggers)
+	triggers := make([]service.CreateWorkflowTriggerInput, 0, len(out.Triggers))
+	for _, trigger := range out.Triggers {
+		if allowed(r, RoleViewer, workflowProject(trigger.WorkflowID)) {
+			triggers = append(triggers, trigger)
+		}
+	}
+	writeOKResponse(w, triggers)
+}
+
+func pauseWorkflowTriggerHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	input := &service.PauseWorkflowTriggerInput{}
+	if err := populateModelFromHandler(w, r, param, input); err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable pause workflow trigger input")
+		return
+	}
+	trigger, err := wm.GetWorkflowTrigger(baseCtx, input.ID)
+	if err != nil {
+		if !authorizeNotFound(w, r, RoleOperator) {
+			return
+		}
+		writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("failed to pause workflow trigger: %v", err))
+		return
+	}
+	auditTargets(r, "trigger", trigger.ID)
+	if !authorize(w, r, RoleOperator, workflowProject(trigger.WorkflowID)) {
+		return
+	}
+
+	trigger, err = wm.PauseWorkflowTrigger(baseCtx, input.ID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to pause workflow trigger: %v", err))
+		return
+	}
+	writeOKResponse(w, trigger)
+}
+
+// runWorkflowHandler starts a run of a workflow and returns its status, the
+// run goes on in the background
+func runWorkflowHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	input := &service.RunWorkflowInput{}
+	if err := populateModelFromHandler(w, r, param, input); err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable run workflow input")
+		return
+	}
+	auditTargets(r, "workflow", input.ID)
+	if !authorize(w, r, RoleOperator, workflowProject(input.ID)) {
+		return
+	}
+
+	run, err := engine.StartWorkflow(baseCtx, *input)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to run workflow: %v", err))
+		return
+	}
+	auditTargets(r, "run", run.ID)
+	writeOKResponse(w, run)
+}
+
+// getWorkflowRunHandler returns the status of a run, given by ?id=
+func getWorkflowRunHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	run, err := engine.GetRun(r.URL.Query().Get("id"))
+	if err != nil {
+		if !authorizeNotFound(w, r, RoleViewer) {
+			return
+		}
+		writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("failed to get workflow run: %v", err))
+		return
+	}
+	if !authorize(w, r, RoleViewer, workflowProject(run.WorkflowID)) {
+		return
+	}
+	writeOKResponse(w, run)
+}
+
+// getWorkflowRunLogsHandler returns the events of the steps of a run, given
+// by ?id=
+func getWorkflowRunLogsHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	runID := r.URL.Query().Get("id")
+	run, err := engine.GetRun(runID)
+	if err != nil {
+		if !authorizeNotFound(w, r, RoleViewer) {
+			return
+		}
+		writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("failed to get workflow run logs: %v", err))
+		return
+	}
+	if !authorize(w, r, RoleViewer, workflowProject(run.WorkflowID)) {
+		return
+	}
+	events, err := engine.RunLogs(runID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("failed to get workflow run logs: %v", err))
+		return
+	}
+	writeOKResponse(w, events)
+}
+
+func cancelWorkflowRunHandler(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+	input := &service.CancelRunInput{}
+	if err := populateModelFromHandler(w, r, param, input); err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable cancel workflow run input")
+		return
+	}
+	auditTargets(r, "run", input.RunID)
+	run, err := engine.GetRun(input.RunID)
+	if err != nil {
+		if !authorizeNotFound(w, r, RoleOperator) {
+			return
+		}
+		writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("failed to cancel workflow run: %v", err))
+		return
+	}
+	auditTargets(r, "workflow", run.WorkflowID)
+	if !authorize(w, r, RoleOperator, workflowProject(run.WorkflowID)) {
+		return
+	}
+
+	run, err = engine.CancelRun(input.RunID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to cancel workflow run: %v", err))
+		return
+	}
+	writeOKResponse(w, run)
 }
 
 // Writes the response as a standard JSON response with StatusOK
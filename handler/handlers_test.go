@@ -1,13 +1,24 @@
 package handler
 
 import (
+	"encoding/json"
+	"github.com/golden-sdk/service"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
+func TestMain(m *testing.M) {
+	// run against the in-memory AWS backends instead of AWS
+	SetClientProvider(service.NewFakeClientProvider())
+	os.Exit(m.Run())
+}
+
 func TestCreateProject(t *testing.T) {
 	input := strings.NewReader("{\n    \"name\": \"Backup Workflow Resources\",\n    \"id\": \"project-001\",\n    \"resources\": [\n        {\n            \"type\": \"S3:Bucket\",\n            \"properties\": {\n               \"BucketName\": \"resource-bucket\",\n	 \"Region\": \"us-west-2\"\n           }\n        }\n    ]\n}")
 	req1, err := http.NewRequest("POST", "/create-project", input)
@@ -19,10 +30,24 @@ func TestCreateProject(t *testing.T) {
 	if rr1.Code != 200 {
 		t.Fatalf("Expected response code to be 200, got %v", rr1.Code)
 	}
+
+	// an existing project is not replaced
+	req2, err := http.NewRequest("POST", "/create-project", strings.NewReader(`{"id": "project-001"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr2 := newRequestRecorder(req2, "POST", "/create-project", createProjectHandler)
+	if rr2.Code != http.StatusConflict {
+		t.Fatalf("Expected response code to be 409, got %v", rr2.Code)
+	}
+	project, err := rm.GetProject(baseCtx, "project-001")
+	if err != nil || len(project.Resources) != 1 {
+		t.Fatalf("Expected project-001 to keep its resource, got %+v, %v", project, err)
+	}
 }
 
 func TestListProjects(t *testing.T) {
-	createProjectInput := strings.NewReader("{\n    \"name\": \"Backup Workflow Resources\",\n    \"id\": \"project-001\",\n    \"resources\": [\n        {\n            \"type\": \"S3:Bucket\",\n            \"properties\": {\n               \"BucketName\": \"resource-bucket\",\n	 \"Region\": \"us-west-2\"\n           }\n        }\n    ]\n}")
+	createProjectInput := strings.NewReader("{\n    \"name\": \"Backup Workflow Resources\",\n    \"id\": \"project-005\",\n    \"resources\": [\n        {\n            \"type\": \"S3:Bucket\",\n            \"properties\": {\n               \"BucketName\": \"resource-bucket\",\n	 \"Region\": \"us-west-2\"\n           }\n        }\n    ]\n}")
 	createProjectReq, err := http.NewRequest("POST", "/create-project", createProjectInput)
 	if err != nil {
 		t.Fatal(err)
@@ -46,8 +71,7 @@ func TestListProjects(t *testing.T) {
 }
 
 func TestCreateProjectResources(t *testing.T) {
-	// create a existing bucket causing error
-	in := strings.NewReader("{\n    \"name\": \"Backup Workflow Resources\",\n    \"id\": \"project-001\",\n    \"resources\": [\n        {\n            \"type\": \"S3:Bucket\",\n            \"properties\": {\n               \"BucketName\": \"resource-bucket\",\n	 \"Region\": \"us-west-2\"\n           }\n        }\n    ]\n}")
+	in := strings.NewReader("{\n    \"name\": \"Backup Workflow Resources\",\n    \"id\": \"project-006\",\n    \"resources\": [\n        {\n            \"type\": \"S3:Bucket\",\n            \"properties\": {\n               \"BucketName\": \"resource-bucket\",\n	 \"Region\": \"us-west-2\"\n           }\n        }\n    ]\n}")
 	req, err := http.NewRequest("POST", "/create-project", in)
 	if err != nil {
 		t.Fatal(err)
@@ -58,15 +82,66 @@ func TestCreateProjectResources(t *testing.T) {
 		t.Fatalf("Expected response code to be 200, got %v", rr.Code)
 	}
 
-	input := strings.NewReader("{\n    \"projectId\": \"project-001\"\n}")
-	req1, err := http.NewRequest("POST", "/create-project-resources", input)
+	// provisioning is idempotent, the second call finds the bucket created by the first
+	for i := 0; i < 2; i++ {
+		input := strings.NewReader("{\n    \"projectId\": \"project-006\"\n}")
+		req1, err := http.NewRequest("POST", "/create-project-resources", input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr1 := newRequestRecorder(req1, "POST", "/create-project-resources", createProjectResourcesHandler)
+		if rr1.Code != 200 {
+			t.Fatalf("Expected response code to be 200, got %v", rr1.Code)
+		}
+	}
+}
+
+func TestDestroyProjectResources(t *testing.T) {
+	in := strings.NewReader(`{"id": "project-002", "resources": [{"type": "S3:Bucket", "properties": {"BucketName": "ephemeral-bucket"}}]}`)
+	req, err := http.NewRequest("POST", "/create-project", in)
 	if err != nil {
 		t.Fatal(err)
 	}
+	rr := newRequestRecorder(req, "POST", "/create-project", createProjectHandler)
+	if rr.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr.Code)
+	}
 
+	req1, err := http.NewRequest("POST", "/create-project-resources", strings.NewReader(`{"projectId": "project-002"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
 	rr1 := newRequestRecorder(req1, "POST", "/create-project-resources", createProjectResourcesHandler)
-	if rr1.Code != 400 {
-		t.Fatalf("Expected response code to be 400, got %v", rr1.Code)
+	if rr1.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr1.Code)
+	}
+
+	req2, err := http.NewRequest("POST", "/destroy-project-resources", strings.NewReader(`{"projectId": "project-002", "emptyFirst": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr2 := newRequestRecorder(req2, "POST", "/destroy-project-resources", destroyProjectResourcesHandler)
+	if rr2.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr2.Code)
+	}
+	if !strings.Contains(rr2.Body.String(), `"action":"delete"`) {
+		t.Fatalf("Expected bucket to be deleted, got %s", rr2.Body.String())
+	}
+}
+
+func TestImportTemplate(t *testing.T) {
+	in := strings.NewReader(`{"projectId": "project-003", "template": "Resources:\n  Logs:\n    Type: AWS::S3::Bucket\n    Properties:\n      BucketName: imported-logs\n  Table:\n    Type: AWS::DynamoDB::Table\n"}`)
+	req, err := http.NewRequest("POST", "/import-template", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := newRequestRecorder(req, "POST", "/import-template", importTemplateHandler)
+	if rr.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"message":"resource type is not supported"`) {
+		t.Fatalf("Expected the table to be reported, got %s", rr.Body.String())
 	}
 }
 
@@ -107,6 +182,56 @@ func TestListWorkflows(t *testing.T) {
 	}
 }
 
+func TestImportExportWorkflows(t *testing.T) {
+	in := strings.NewReader("id: workflow_restore\nname: Restore Service\ninput:\n  dir: /data\ntriggers:\n  - id: weekly\n    type: scheduled\n")
+	req, err := http.NewRequest("POST", "/import-workflows", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := newRequestRecorder(req, "POST", "/import-workflows", importWorkflowsHandler)
+	if rr.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr.Code)
+	}
+
+	req1, err := http.NewRequest("GET", "/export-workflow?id=workflow_restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr1 := newRequestRecorder(req1, "GET", "/export-workflow", exportWorkflowHandler)
+	if rr1.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr1.Code)
+	}
+	if !strings.Contains(rr1.Body.String(), "defaultValue: /data") || !strings.Contains(rr1.Body.String(), "workflowId: workflow_restore") {
+		t.Fatalf("Expected the workflow definition, got %s", rr1.Body.String())
+	}
+
+	req2, err := http.NewRequest("GET", "/export-workflow?id=workflow_unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr2 := newRequestRecorder(req2, "GET", "/export-workflow", exportWorkflowHandler)
+	if rr2.Code != 404 {
+		t.Fatalf("Expected response code to be 404, got %v", rr2.Code)
+	}
+}
+
+func TestApply(t *testing.T) {
+	definitions := "kind: Project\nid: project-004\nresources: []\n---\nid: workflow_apply\nprojectId: project-004\n"
+	for _, want := range []string{`"action":"create"`, `"action":"none"`} {
+		req, err := http.NewRequest("POST", "/apply", strings.NewReader(definitions))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := newRequestRecorder(req, "POST", "/apply", applyHandler)
+		if rr.Code != 200 {
+			t.Fatalf("Expected response code to be 200, got %v", rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("Expected %s in the plan, got %s", want, rr.Body.String())
+		}
+	}
+}
+
 func TestCreateWorkflowTrigger(t *testing.T) {
 	in := strings.NewReader("{\n    \"id\": \"trigger-01\",\n    \"name\": \"Scheduled-Backup-Service\",\n    \"workflow_trigger_type\": \"scheduled\",\n    \"trigger_conf\": {\n        \"runAt\": \"Sunday 12, 2024\",\n        \"repeat\": \"True\"\n    },\n    \"workflow_id\": \"workflow-01\",\n    \"input\": \"/tmp/user1/backlup\",\n    \"status\": \"Active\"\n}")
 	req, err := http.NewRequest("POST", "/create-workflow-trigger", in)
@@ -144,6 +269,101 @@ func TestListWorkflowTriggers(t *testing.T) {
 	}
 }
 
+func TestPauseWorkflowTrigger(t *testing.T) {
+	in := strings.NewReader(`{"id": "trigger-pause", "type": "scheduled", "workflowId": "workflow-01", "status": "active"}`)
+	req, err := http.NewRequest("POST", "/create-workflow-trigger", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr := newRequestRecorder(req, "POST", "/create-workflow-trigger", createWorkflowTriggerHandler); rr.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr.Code)
+	}
+
+	for id, code := range map[string]int{"trigger-pause": 200, "trigger-404": 404} {
+		req, err := http.NewRequest("POST", "/pause-workflow-trigger", strings.NewReader(`{"id": "`+id+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := newRequestRecorder(req, "POST", "/pause-workflow-trigger", pauseWorkflowTriggerHandler)
+		if rr.Code != code {
+			t.Fatalf("Expected response code of %s to be %d, got %v", id, code, rr.Code)
+		}
+		if code == 200 && !strings.Contains(rr.Body.String(), `"status":"deactive"`) {
+			t.Fatalf("Expected the trigger to be deactivated, got %s", rr.Body.String())
+		}
+	}
+}
+
+func TestRunWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in := strings.NewReader(`{"id": "workflow-run", "steps": [{"id": "read", "type": "ReadFile", "input": {"directory": "` + dir + `"}}]}`)
+	req, err := http.NewRequest("POST", "/create-workflow", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr := newRequestRecorder(req, "POST", "/create-workflow", createWorkflowHandler); rr.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v", rr.Code)
+	}
+
+	req, err = http.NewRequest("POST", "/run-workflow", strings.NewReader(`{"workflowId": "workflow-run"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := newRequestRecorder(req, "POST", "/run-workflow", runWorkflowHandler)
+	if rr.Code != 200 {
+		t.Fatalf("Expected response code to be 200, got %v: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Data service.WorkflowRun `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Data.Status != service.RunStatusRunning {
+		t.Fatalf("Expected a running run, got %s", rr.Body.String())
+	}
+
+	get := func(route string, handler httprouter.Handle, v interface{}) {
+		t.Helper()
+		req, err := http.NewRequest("GET", route+"?id="+resp.Data.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := newRequestRecorder(req, "GET", route, handler)
+		if rr.Code != 200 {
+			t.Fatalf("Expected response code of %s to be 200, got %v", route, rr.Code)
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := resp
+	deadline := time.Now().Add(5 * time.Second)
+	for run.Data.Status == service.RunStatusRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		get("/get-run", getWorkflowRunHandler, &run)
+	}
+	if run.Data.Status != service.RunStatusSucceeded {
+		t.Fatalf("Expected the run to succeed, got %+v", run.Data)
+	}
+	var logs struct {
+		Data []service.StepEvent `json:"data"`
+	}
+	get("/get-run-logs", getWorkflowRunLogsHandler, &logs)
+	if len(logs.Data) != 2 || logs.Data[0].StepID != "read" || logs.Data[0].Status != service.StepStatusStarted ||
+		logs.Data[1].Status != service.StepStatusSucceeded || logs.Data[1].Summary != "1 files" {
+		t.Fatalf("Expected the events of the read step, got %+v", logs.Data)
+	}
+
+	req, err = http.NewRequest("POST", "/run-workflow", strings.NewReader(`{"workflowId": "workflow-404"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr := newRequestRecorder(req, "POST", "/run-workflow", runWorkflowHandler); rr.Code != 400 {
+		t.Fatalf("Expected response code to be 400, got %v", rr.Code)
+	}
+}
+
 // Mocks a handler and returns a httptest.ResponseRecorder
 func newRequestRecorder(req *http.Request, method string, strPath string, fnHandler func(w http.ResponseWriter, r *http.Request, param httprouter.Params)) *httptest.ResponseRecorder {
 	router := httprouter.New()
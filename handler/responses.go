@@ -1,16 +1,10 @@
 package handler
 
-type JsonResponse struct {
-	// Reserved field to add some meta information to the API response
-	Meta interface{} `json:"meta"`
-	Data interface{} `json:"data"`
-}
+import "github.com/golden-sdk/api"
 
-type JsonErrorResponse struct {
-	Error *ApiError `json:"error"`
-}
-
-type ApiError struct {
-	Status int    `json:"status"`
-	Title  string `json:"title"`
-}
+// The response types are shared with the clients
+type (
+	JsonResponse      = api.JsonResponse
+	JsonErrorResponse = api.JsonErrorResponse
+	ApiError          = api.ApiError
+)
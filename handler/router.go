@@ -1,14 +1,63 @@
 package handler
 
-import "github.com/julienschmidt/httprouter"
+import (
+	"github.com/golden-sdk/service"
+	"github.com/julienschmidt/httprouter"
+)
 
-func NewRouter(routes Routes) *httprouter.Router {
+// RouterOption configures NewRouter
+type RouterOption func(o *routerOptions)
+
+type routerOptions struct {
+	authenticators []Authenticator
+	policy         *Policy
+	auditLog       *service.AuditLog
+}
+
+// WithAuthenticators requires the requests to be authenticated by one of
+// auths, except for the public routes. Unauthenticated requests get a 401
+// JsonErrorResponse.
+func WithAuthenticators(auths ...Authenticator) RouterOption {
+	return func(o *routerOptions) { o.authenticators = append(o.authenticators, auths...) }
+}
+
+// WithPolicy requires the callers to have a role on the projects they read or
+// change, the lists only return what the caller can see. It needs the
+// authenticators of WithAuthenticators.
+func WithPolicy(policy *Policy) RouterOption {
+	return func(o *routerOptions) { o.policy = policy }
+}
+
+// WithAuditLog appends an entry for each request which is not a GET to the
+// audit log, including the requests which are denied, and serves the log on the
+// audit routes
+func WithAuditLog(auditLog *service.AuditLog) RouterOption {
+	return func(o *routerOptions) { o.auditLog = auditLog }
+}
+
+func NewRouter(routes Routes, opts ...RouterOption) *httprouter.Router {
+	options := &routerOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
 
 	router := httprouter.New()
 	for _, route := range routes {
 		var handle httprouter.Handle
 
 		handle = route.HandlerFunc
+		if options.policy != nil {
+			handle = withPolicy(options.policy, handle)
+		}
+		if len(options.authenticators) > 0 && !publicRoutes[route.Name] {
+			handle = authenticate(options.authenticators, handle)
+		}
+		if options.auditLog != nil {
+			if route.Method != "GET" {
+				handle = audit(options.auditLog, route.Name, handle)
+			}
+			handle = withAuditLog(options.auditLog, handle)
+		}
 		handle = logger(handle)
 
 		router.Handle(route.Method, route.Path, handle)
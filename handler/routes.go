@@ -21,11 +21,23 @@ func AllRoutes() Routes {
 		Route{"Index", "GET", "/", index},
 		Route{"CreateProject", "POST", "/resourceManager/createProject", createProjectHandler},
 		Route{"CreateProjectResources", "POST", "/resourceManager/createProjectResources", createProjectResourcesHandler},
+		Route{"DestroyProjectResources", "POST", "/resourceManager/destroyProjectResources", destroyProjectResourcesHandler},
+		Route{"ImportTemplate", "POST", "/resourceManager/importTemplate", importTemplateHandler},
 		Route{"ListProjects", "GET", "/resourceManager/listProjects", listProjectsHandler},
 		Route{"CreateWorkflow", "POST", "/workflowManager/createWorkflow", createWorkflowHandler},
 		Route{"ListWorkflows", "GET", "/workflowManager/listWorkflows", listWorkflowsHandler},
+		Route{"ExportWorkflow", "GET", "/workflowManager/exportWorkflow", exportWorkflowHandler},
+		Route{"ImportWorkflows", "POST", "/workflowManager/importWorkflows", importWorkflowsHandler},
+		Route{"RunWorkflow", "POST", "/workflowManager/runWorkflow", runWorkflowHandler},
+		Route{"GetWorkflowRun", "GET", "/workflowManager/getRun", getWorkflowRunHandler},
+		Route{"GetWorkflowRunLogs", "GET", "/workflowManager/getRunLogs", getWorkflowRunLogsHandler},
+		Route{"CancelWorkflowRun", "POST", "/workflowManager/cancelRun", cancelWorkflowRunHandler},
 		Route{"CreateWorkflowTrigger", "POST", "/workflowTriggerManager/createTrigger", createWorkflowTriggerHandler},
 		Route{"ListTriggers", "GET", "/workflowTriggerManager/listTriggers", listWorkflowTriggersHandler},
+		Route{"PauseTrigger", "POST", "/workflowTriggerManager/pauseTrigger", pauseWorkflowTriggerHandler},
+		Route{"Apply", "POST", "/definitions/apply", applyHandler},
+		Route{"ListAuditEntries", "GET", "/audit/listEntries", listAuditEntriesHandler},
+		Route{"ExportAuditEntries", "GET", "/audit/exportEntries", exportAuditEntriesHandler},
 	}
 	return routes
 }
@@ -1,19 +1,198 @@
 package main
 
 import (
+	"context"
 	"github.com/golden-sdk/handler"
+	"github.com/golden-sdk/service"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	router := handler.NewRouter(handler.AllRoutes())
-	log.Fatal(http.ListenAndServe(":8080", enableCors(router)))
+	cfg, err := loadConfig(os.Args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	if err := handler.SetLogLevel(cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	switch cfg.Storage {
+	case "aws":
+		handler.SetClientProvider(service.NewAWSClientProvider(service.AWSConfig{
+			Region:       cfg.AWS.Region,
+			Endpoint:     cfg.AWS.Endpoint,
+			UsePathStyle: cfg.AWS.UsePathStyle,
+			Profile:      cfg.AWS.Profile,
+		}))
+	case "fake":
+		log.Printf("Using the in-memory S3 backend, data is lost on exit")
+		handler.SetClientProvider(service.NewFakeClientProvider())
+	}
+
+	// the operations started by the requests are interrupted when the
+	// shutdown grace period is over
+	operations, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	handler.SetContext(operations)
+
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auths, err := cfg.Auth.authenticators()
+	if err != nil {
+		log.Fatalf("invalid authentication settings: %v", err)
+	}
+	if len(auths) == 0 {
+		log.Printf("No credentials are configured, the API does not require authentication")
+	}
+	options := []handler.RouterOption{handler.WithAuthenticators(auths...)}
+	policy, err := cfg.Authorization.policy()
+	if err != nil {
+		log.Fatalf("invalid authorization settings: %v", err)
+	}
+	if policy != nil {
+		options = append(options, handler.WithPolicy(policy))
+	}
+	auditLog := &service.AuditLog{}
+	if cfg.Audit.File != "" {
+		if auditLog, err = service.OpenAuditLog(cfg.Audit.File); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Printf("No audit file is configured, the audit log is lost on exit")
+	}
+	defer auditLog.Close()
+	options = append(options, handler.WithAuditLog(auditLog))
+	router := handler.NewRouter(handler.AllRoutes(), options...)
+	server := &http.Server{
+		Handler: enableCors(router, cfg.CORS.AllowedOrigins),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if cfg.Scheduler.Enabled {
+		scheduler := handler.NewScheduler(cfg.Scheduler.Interval, cfg.Scheduler.MaxConcurrentRuns)
+		scheduler.Report = func(triggerID string, err error) {
+			log.Printf("Scheduled trigger %s not run: %v", triggerID, err)
+		}
+		log.Printf("Checking the scheduled triggers every %v", cfg.Scheduler.Interval)
+		// the scheduler stops with the start of the shutdown, its runs go on
+		// with the operations
+		go scheduler.Run(ctx)
+	}
+	log.Printf("Listening on %s", listener.Addr())
+	if err := serve(ctx, server, listener, cfg, handler.Engine(), interrupt); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Server stopped")
+}
+
+// interruptTimeout is the time given to the handlers to respond once their
+// operations are interrupted
+const interruptTimeout = 5 * time.Second
+
+// serve runs server until ctx is done, then stops accepting requests and
+// starting workflow runs, and waits up to the shutdown grace period for the
+// runs and the requests in progress. When they are still running after it,
+// interrupt cancels their operations and the handlers are given
+// interruptTimeout to respond before the connections are closed.
+func serve(ctx context.Context, server *http.Server, listener net.Listener, cfg Config, runs *service.WorkflowEngine, interrupt func()) error {
+	conns := &connections{active: make(map[net.Conn]bool)}
+	connState := server.ConnState
+	server.ConnState = func(conn net.Conn, state http.ConnState) {
+		conns.track(conn, state)
+		if connState != nil {
+			connState(conn, state)
+		}
+	}
+	served := make(chan error, 1)
+	go func() {
+		if cfg.TLS.CertFile != "" {
+			served <- server.ServeTLS(listener, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			served <- server.Serve(listener)
+		}
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %v for the runs and the requests in progress", cfg.ShutdownGrace)
+	grace, cancel := context.WithTimeout(context.Background(), cfg.ShutdownGrace)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- runs.Shutdown(grace) }()
+	err := server.Shutdown(grace)
+	if err := <-drained; err != nil {
+		log.Printf("Interrupted the workflow runs in progress: %v", err)
+	}
+	if err == nil {
+		return nil
+	}
+	log.Printf("Interrupting the requests in progress")
+	interrupt()
+	conns.waitIdle(interruptTimeout)
+	return server.Close()
 }
 
-func enableCors(next http.Handler) http.Handler {
+// connections tracks the connections of a server which are serving a request
+type connections struct {
+	mu     sync.Mutex
+	active map[net.Conn]bool
+}
+
+func (c *connections) track(conn net.Conn, state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state == http.StateActive {
+		c.active[conn] = true
+	} else {
+		delete(c.active, conn)
+	}
+}
+
+// waitIdle waits up to timeout for the responses in progress to be written
+func (c *connections) waitIdle(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		n := len(c.active)
+		c.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// enableCors answers the CORS requests of the allowed origins, * allows any
+// origin
+func enableCors(next http.Handler, allowedOrigins []string) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!allowed["*"] && !allowed[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if allowed["*"] {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if r.Method == http.MethodOptions {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/golden-sdk/service"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startServer serves h with serve and returns its URL, the cancel function
+// which starts the shutdown and the result of serve
+func startServer(t *testing.T, h http.Handler, grace time.Duration, runs *service.WorkflowEngine, interrupt func()) (string, context.CancelFunc, chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cfg := defaultConfig()
+	cfg.ShutdownGrace = grace
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, &http.Server{Handler: h}, listener, cfg, runs, interrupt) }()
+	return "http://" + listener.Addr().String(), cancel, served
+}
+
+type result struct {
+	status int
+	body   string
+}
+
+func get(url string) chan result {
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			results <- result{body: err.Error()}
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		results <- result{status: resp.StatusCode, body: string(b)}
+	}()
+	return results
+}
+
+func TestServeDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	interrupted := false
+	runs := &service.WorkflowEngine{Workflows: &service.WorkflowManager{}}
+	url, shutdown, served := startServer(t, h, 5*time.Second, runs, func() { interrupted = true })
+
+	results := get(url)
+	<-started
+	shutdown()
+	if res := <-results; res.status != http.StatusOK || res.body != "done" {
+		t.Fatalf("Expected the request in progress to complete, got %+v", res)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if interrupted {
+		t.Fatalf("Expected the operations not to be interrupted within the grace period")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatalf("Expected new requests to be refused after the shutdown")
+	}
+	if err := runs.RunWorkflow(context.Background(), service.RunWorkflowInput{ID: "workflow-001"}); !errors.Is(err, service.ErrRunInterrupted) {
+		t.Fatalf("Expected the runs to be refused after the shutdown, got %v", err)
+	}
+}
+
+func TestServeInterruptsAfterGrace(t *testing.T) {
+	operations, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-operations.Done()
+		http.Error(w, "interrupted", http.StatusServiceUnavailable)
+	})
+	url, shutdown, served := startServer(t, h, 50*time.Millisecond, &service.WorkflowEngine{}, interrupt)
+
+	results := get(url)
+	<-started
+	shutdown()
+	if res := <-results; res.status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the request to be interrupted, got %+v", res)
+	}
+	if operations.Err() == nil {
+		t.Fatalf("Expected the operations to be interrupted after the grace period")
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,474 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"sort"
+)
+
+// The kinds of definitions, given by the kind field of a definition document.
+// Documents without kind are workflows.
+const (
+	DefinitionKindProject  = "Project"
+	DefinitionKindWorkflow = "Workflow"
+	DefinitionKindTrigger  = "Trigger"
+)
+
+// Definitions is a set of project, workflow and trigger definitions
+type Definitions struct {
+	Projects  []CreateProjectInput         `json:"projects"`
+	Workflows []WorkflowDefinition         `json:"workflows"`
+	Triggers  []CreateWorkflowTriggerInput `json:"triggers"`
+}
+
+// ParseDefinitions reads the YAML or JSON definitions of a document, YAML
+// documents may hold several definitions separated by ---.
+func ParseDefinitions(data []byte) (Definitions, error) {
+	definitions := Definitions{
+		Projects:  make([]CreateProjectInput, 0),
+		Workflows: make([]WorkflowDefinition, 0),
+		Triggers:  make([]CreateWorkflowTriggerInput, 0),
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Definitions{}, fmt.Errorf("error when parsing definition %d: %v", n, err)
+		}
+		if document == nil {
+			continue
+		}
+		fields, ok := document.(map[string]interface{})
+		if !ok {
+			return Definitions{}, fmt.Errorf("definition %d must be a mapping", n)
+		}
+		kind, _ := fields["kind"].(string)
+		delete(fields, "kind")
+
+		// the definition is decoded as JSON, which keeps a single set of
+		// field names and the map forms of the inputs and variables
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return Definitions{}, fmt.Errorf("error when parsing definition %d: %v", n, err)
+		}
+		jsonDecoder := json.NewDecoder(bytes.NewReader(b))
+		jsonDecoder.DisallowUnknownFields()
+
+		switch kind {
+		case DefinitionKindProject:
+			project := CreateProjectInput{}
+			if err := jsonDecoder.Decode(&project); err != nil {
+				return Definitions{}, fmt.Errorf("error when parsing definition %d: %v", n, err)
+			}
+			if project.ID == "" {
+				return Definitions{}, fmt.Errorf("project definition %d has no id", n)
+			}
+			definitions.Projects = append(definitions.Projects, project)
+		case DefinitionKindWorkflow, "":
+			workflow := WorkflowDefinition{}
+			if err := jsonDecoder.Decode(&workflow); err != nil {
+				return Definitions{}, fmt.Errorf("error when parsing definition %d: %v", n, err)
+			}
+			if err := workflow.validate(); err != nil {
+				return Definitions{}, err
+			}
+			definitions.Workflows = append(definitions.Workflows, workflow)
+		case DefinitionKindTrigger:
+			trigger := CreateWorkflowTriggerInput{}
+			if err := jsonDecoder.Decode(&trigger); err != nil {
+				return Definitions{}, fmt.Errorf("error when parsing definition %d: %v", n, err)
+			}
+			if trigger.ID == "" || trigger.WorkflowID == "" {
+				return Definitions{}, fmt.Errorf("trigger definition %d needs an id and a workflowId", n)
+			}
+			definitions.Triggers = append(definitions.Triggers, trigger)
+		default:
+			return Definitions{}, fmt.Errorf("definition %d has unknown kind %s", n, kind)
+		}
+	}
+	if len(definitions.Projects)+len(definitions.Workflows)+len(definitions.Triggers) == 0 {
+		return Definitions{}, fmt.Errorf("no definition found")
+	}
+	return definitions, nil
+}
+
+// LoadDefinitions reads a definition file, or the .yaml, .yml and .json files
+// of a directory.
+func LoadDefinitions(path string) (Definitions, error) {
+	files, err := definitionFiles(path)
+	if err != nil {
+		return Definitions{}, err
+	}
+	definitions := Definitions{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return Definitions{}, fmt.Errorf("error when reading definitions: %v", err)
+		}
+		d, err := ParseDefinitions(data)
+		if err != nil {
+			return Definitions{}, fmt.Errorf("%s: %v", file, err)
+		}
+		definitions.Projects = append(definitions.Projects, d.Projects...)
+		definitions.Workflows = append(definitions.Workflows, d.Workflows...)
+		definitions.Triggers = append(definitions.Triggers, d.Triggers...)
+	}
+	return definitions, nil
+}
+
+type ApplyInput struct {
+	Definitions
+	// Prune deletes the projects, workflows and triggers which are not
+	// defined, otherwise they are left untouched
+	Prune bool `json:"prune"`
+	// DryRun returns the plan without applying it
+	DryRun bool `json:"dryRun"`
+}
+
+type ApplyOutput struct {
+	DryRun bool               `json:"dryRun"`
+	Plan   []DefinitionChange `json:"plan"`
+}
+
+// DefinitionChange is the planned change of a project, a workflow or a
+// trigger. Diff lists the fields which differ from the current definition.
+type DefinitionChange struct {
+	Kind   string          `json:"kind"`
+	ID     string          `json:"id"`
+	Action PlanAction      `json:"action"`
+	Diff   []PropertyDrift `json:"diff,omitempty"`
+}
+
+// applyStep is a planned change with the call applying it
+type applyStep struct {
+	change DefinitionChange
+	apply  func(ctx context.Context) error
+}
+
+// Apply compares the definitions with the projects, the workflows and the
+// triggers currently held, and creates, updates or deletes them to match. The
+// plan is computed and validated first, applying it twice is a no-op. Projects
+// are applied before the workflows linked to them and triggers last, deletions
+// happen in the reverse order. Project resources are not provisioned, see
+// CreateProjectResources.
+func (wm *WorkflowManager) Apply(ctx context.Context, input *ApplyInput) (ApplyOutput, error) {
+	out := ApplyOutput{
+		DryRun: input.DryRun,
+		Plan:   make([]DefinitionChange, 0),
+	}
+	steps, err := wm.planApply(ctx, input)
+	if err != nil {
+		return out, err
+	}
+	for _, step := range steps {
+		out.Plan = append(out.Plan, step.change)
+	}
+	if input.DryRun {
+		return out, nil
+	}
+	for _, step := range steps {
+		if step.apply == nil {
+			continue
+		}
+		if err := step.apply(ctx); err != nil {
+			return out, fmt.Errorf("error when applying %s %s: %v", step.change.Kind, step.change.ID, err)
+		}
+	}
+	return out, nil
+}
+
+func (wm *WorkflowManager) planApply(ctx context.Context, input *ApplyInput) ([]applyStep, error) {
+	if len(input.Projects) > 0 && wm.Projects == nil {
+		return nil, fmt.Errorf("projects cannot be applied, no resource manager is configured")
+	}
+
+	// the triggers of the workflow definitions are applied with the others
+	workflows := make([]WorkflowDefinition, 0, len(input.Workflows))
+	triggers := append([]CreateWorkflowTriggerInput{}, input.Triggers...)
+	for _, w := range input.Workflows {
+		w.Triggers = append([]CreateWorkflowTriggerInput{}, w.Triggers...)
+		if err := w.validate(); err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, w.Triggers...)
+		w.Triggers = nil
+		workflows = append(workflows, w)
+	}
+
+	existingProjects := make(map[string]Project)
+	if wm.Projects != nil {
+		projects, err := wm.Projects.ListProjects(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projects {
+			existingProjects[p.ID] = p
+		}
+	}
+	// a definition may reference what it defines, or what exists and is kept
+	definedProjects := make(map[string]bool, len(input.Projects))
+	for _, p := range input.Projects {
+		if p.ID == "" {
+			return nil, fmt.Errorf("project definition has no id")
+		}
+		if definedProjects[p.ID] {
+			return nil, fmt.Errorf("duplicate project %s", p.ID)
+		}
+		definedProjects[p.ID] = true
+	}
+	definedWorkflows := make(map[string]bool, len(workflows))
+	for _, w := range workflows {
+		if definedWorkflows[w.ID] {
+			return nil, fmt.Errorf("duplicate workflow %s", w.ID)
+		}
+		definedWorkflows[w.ID] = true
+	}
+	definedTriggers := make(map[string]bool, len(triggers))
+	for _, t := range triggers {
+		if t.ID == "" {
+			return nil, fmt.Errorf("trigger of workflow %s has no id", t.WorkflowID)
+		}
+		if definedTriggers[t.ID] {
+			return nil, fmt.Errorf("duplicate trigger %s", t.ID)
+		}
+		definedTriggers[t.ID] = true
+	}
+	projectExists := func(id string) bool {
+		_, ok := existingProjects[id]
+		return definedProjects[id] || (ok && !input.Prune)
+	}
+	workflowExists := func(id string) bool {
+		_, ok := wm.workflow(id)
+		return definedWorkflows[id] || (ok && !input.Prune)
+	}
+
+	steps := make([]applyStep, 0)
+	for i := range input.Projects {
+		project := input.Projects[i]
+		project.Resources = append([]Resource{}, project.Resources...)
+		for j := range project.Resources {
+			if project.Resources[j].ID == "" {
+				project.Resources[j].ID = resourceName(project.Resources[j])
+			}
+		}
+		if _, err := newProject(&CreateProjectInput{ID: project.ID, Resources: append([]Resource{}, project.Resources...)}); err != nil {
+			return nil, fmt.Errorf("invalid project %s: %v", project.ID, err)
+		}
+		step := applyStep{change: DefinitionChange{Kind: DefinitionKindProject, ID: project.ID}}
+		current, ok := existingProjects[project.ID]
+		if !ok {
+			step.change.Action = PlanActionCreate
+			// a project created since the plan is not replaced, which would
+			// drop the states of its resources
+			step.apply = func(ctx context.Context) error {
+				return wm.Projects.storeProject(&project)
+			}
+		} else {
+			diff, err := diffDefinitions(projectFields(CreateProjectInput{Name: current.Name, Resources: current.Resources}), projectFields(project))
+			if err != nil {
+				return nil, err
+			}
+			if err := checkRemovedResources(current, project.Resources); err != nil {
+				return nil, err
+			}
+			step.change.Action, step.change.Diff = planUpdate(diff)
+			if len(diff) > 0 {
+				step.apply = func(ctx context.Context) error {
+					return wm.Projects.UpdateProject(ctx, &project)
+				}
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	for i := range workflows {
+		workflow := workflows[i]
+		if workflow.ProjectID != "" && !projectExists(workflow.ProjectID) {
+			return nil, fmt.Errorf("project %s of workflow %s is not defined", workflow.ProjectID, workflow.ID)
+		}
+		if workflow.ProjectID == "" && workflow.ProvisionResources {
+			return nil, fmt.Errorf("workflow %s provisions resources but is not linked to a project", workflow.ID)
+		}
+		step := applyStep{change: DefinitionChange{Kind: DefinitionKindWorkflow, ID: workflow.ID}}
+		create := func(ctx context.Context) error {
+			_, err := wm.CreateWorkflow(ctx, &workflow.CreateWorkflowInput)
+			return err
+		}
+		current, err := wm.ExportWorkflow(ctx, workflow.ID)
+		if err != nil {
+			step.change.Action = PlanActionCreate
+			step.apply = create
+		} else {
+			diff, err := diffDefinitions(current.CreateWorkflowInput, workflow.CreateWorkflowInput)
+			if err != nil {
+				return nil, err
+			}
+			step.change.Action, step.change.Diff = planUpdate(diff)
+			if len(diff) > 0 {
+				step.apply = create
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	for i := range triggers {
+		trigger := triggers[i]
+		if !workflowExists(trigger.WorkflowID) {
+			return nil, fmt.Errorf("workflow %s of trigger %s is not defined", trigger.WorkflowID, trigger.ID)
+		}
+		step := applyStep{change: DefinitionChange{Kind: DefinitionKindTrigger, ID: trigger.ID}}
+		create := func(ctx context.Context) error {
+			return wm.CreateWorkflowTrigger(ctx, &trigger)
+		}
+		current, err := wm.GetWorkflowTrigger(ctx, trigger.ID)
+		if err != nil {
+			step.change.Action = PlanActionCreate
+			step.apply = create
+		} else {
+			diff, err := diffDefinitions(current, trigger)
+			if err != nil {
+				return nil, err
+			}
+			step.change.Action, step.change.Diff = planUpdate(diff)
+			if len(diff) > 0 {
+				step.apply = create
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	if !input.Prune {
+		return steps, nil
+	}
+	workflowIDs, triggerIDs := wm.definitionIDs()
+	for _, id := range triggerIDs {
+		if definedTriggers[id] {
+			continue
+		}
+		id := id
+		steps = append(steps, applyStep{
+			change: DefinitionChange{Kind: DefinitionKindTrigger, ID: id, Action: PlanActionDelete},
+			apply: func(ctx context.Context) error {
+				return wm.DeleteWorkflowTrigger(ctx, id)
+			},
+		})
+	}
+	for _, id := range workflowIDs {
+		if definedWorkflows[id] {
+			continue
+		}
+		id := id
+		steps = append(steps, applyStep{
+			change: DefinitionChange{Kind: DefinitionKindWorkflow, ID: id, Action: PlanActionDelete},
+			apply: func(ctx context.Context) error {
+				return wm.DeleteWorkflow(ctx, id)
+			},
+		})
+	}
+	for _, id := range sortedDefinitionIDs(existingProjects) {
+		if definedProjects[id] {
+			continue
+		}
+		if err := checkRemovedResources(existingProjects[id], nil); err != nil {
+			return nil, err
+		}
+		id := id
+		steps = append(steps, applyStep{
+			change: DefinitionChange{Kind: DefinitionKindProject, ID: id, Action: PlanActionDelete},
+			apply: func(ctx context.Context) error {
+				return wm.Projects.DeleteProject(ctx, id)
+			},
+		})
+	}
+	return steps, nil
+}
+
+func planUpdate(diff []PropertyDrift) (PlanAction, []PropertyDrift) {
+	if len(diff) == 0 {
+		return PlanActionNone, nil
+	}
+	return PlanActionUpdate, diff
+}
+
+// projectFields lists the name and the resources of a project, so that the
+// resources are compared one by one
+func projectFields(project CreateProjectInput) map[string]interface{} {
+	fields := map[string]interface{}{"name": project.Name}
+	for _, r := range project.Resources {
+		fields["resources."+r.ID] = r
+	}
+	return fields
+}
+
+// diffDefinitions compares the JSON fields of two definitions, empty values
+// such as null, [] or "" are equal
+func diffDefinitions(current interface{}, desired interface{}) ([]PropertyDrift, error) {
+	currentFields, err := definitionFields(current)
+	if err != nil {
+		return nil, err
+	}
+	desiredFields, err := definitionFields(desired)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(currentFields)+len(desiredFields))
+	for name := range currentFields {
+		names[name] = ""
+	}
+	for name := range desiredFields {
+		names[name] = ""
+	}
+
+	diff := make([]PropertyDrift, 0)
+	for _, name := range sortedKeys(names) {
+		if currentFields[name] == desiredFields[name] {
+			continue
+		}
+		diff = append(diff, PropertyDrift{
+			Property: name,
+			Current:  currentFields[name],
+			Desired:  desiredFields[name],
+		})
+	}
+	return diff, nil
+}
+
+func definitionFields(definition interface{}) (map[string]string, error) {
+	b, err := json.Marshal(definition)
+	if err != nil {
+		return nil, fmt.Errorf("error when comparing definitions: %v", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error when comparing definitions: %v", err)
+	}
+	fields := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch v := normalizeJSON(string(value)); v {
+		case "null", "[]", "{}", `""`, "false", "0":
+		default:
+			fields[name] = v
+		}
+	}
+	return fields, nil
+}
+
+// sortedDefinitionIDs is used to plan deletions in a stable order
+func sortedDefinitionIDs[T any](m map[string]T) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
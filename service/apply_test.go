@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const backupDefinitions = `
+kind: Project
+id: project-001
+name: Backup Resources
+resources:
+  - id: backupBucket
+    type: S3:Bucket
+    properties:
+      BucketName: backups
+---
+id: workflow_backup
+projectId: project-001
+steps:
+  - id: upload
+    type: S3:PutObject
+    input:
+      bucket: ${resources.backupBucket.name}
+triggers:
+  - id: nightly
+    type: scheduled
+---
+kind: Trigger
+id: weekly
+workflowId: workflow_backup
+type: scheduled
+`
+
+func planActions(plan []DefinitionChange) string {
+	actions := make([]string, 0, len(plan))
+	for _, change := range plan {
+		actions = append(actions, change.Kind+" "+change.ID+" "+string(change.Action))
+	}
+	return strings.Join(actions, ", ")
+}
+
+func TestApply(t *testing.T) {
+	definitions, err := ParseDefinitions([]byte(backupDefinitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := &ResourceManager{Clients: NewFakeClientProvider()}
+	wm := &WorkflowManager{Projects: rm}
+
+	out, err := wm.Apply(context.Background(), &ApplyInput{Definitions: definitions, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Project project-001 create, Workflow workflow_backup create, Trigger weekly create, Trigger nightly create"
+	if got := planActions(out.Plan); got != want {
+		t.Fatalf("Expected plan %s, got %s", want, got)
+	}
+	if _, err := rm.GetProject(context.Background(), "project-001"); err == nil {
+		t.Fatalf("Expected no project to be created on a dry run")
+	}
+
+	if _, err := wm.Apply(context.Background(), &ApplyInput{Definitions: definitions}); err != nil {
+		t.Fatal(err)
+	}
+	// applying the same definitions again changes nothing
+	out, err = wm.Apply(context.Background(), &ApplyInput{Definitions: definitions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "Project project-001 none, Workflow workflow_backup none, Trigger weekly none, Trigger nightly none"
+	if got := planActions(out.Plan); got != want {
+		t.Fatalf("Expected plan %s, got %s", want, got)
+	}
+
+	// provisioned resources keep their state when the project is updated
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	definitions.Projects[0].Name = "Backups"
+	definitions.Triggers = nil
+	out, err = wm.Apply(context.Background(), &ApplyInput{Definitions: definitions, Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "Project project-001 update, Workflow workflow_backup none, Trigger nightly none, Trigger weekly delete"
+	if got := planActions(out.Plan); got != want {
+		t.Fatalf("Expected plan %s, got %s", want, got)
+	}
+	if diff := out.Plan[0].Diff; len(diff) != 1 || diff[0].Property != "name" || diff[0].Desired != `"Backups"` {
+		t.Fatalf("Expected the name to change, got %+v", diff)
+	}
+	project, err := rm.GetProject(context.Background(), "project-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "Backups" || project.States[0].Status != ResourceStatusProvisioned {
+		t.Fatalf("Expected the provisioned state to be kept, got %+v", project)
+	}
+	triggers, _ := wm.ListWorkflowTriggers()
+	if len(triggers.Triggers) != 1 {
+		t.Fatalf("Expected the weekly trigger to be deleted, got %+v", triggers.Triggers)
+	}
+
+	// a provisioned resource is destroyed before it is removed
+	definitions.Projects[0].Resources = nil
+	if _, err := wm.Apply(context.Background(), &ApplyInput{Definitions: definitions}); err == nil || !strings.Contains(err.Error(), "resource backupBucket of project project-001 is provisioned") {
+		t.Fatalf("Expected the provisioned bucket to be kept, got %v", err)
+	}
+}
+
+func TestApplyConcurrent(t *testing.T) {
+	ctx := context.Background()
+	definitions, err := ParseDefinitions([]byte(backupDefinitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := &ResourceManager{Clients: NewFakeClientProvider()}
+	wm := &WorkflowManager{Projects: rm}
+
+	// a project created after the plan is not replaced
+	steps, err := wm.planApply(ctx, &ApplyInput{Definitions: definitions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.CreateProject(ctx, &definitions.Projects[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := steps[0].apply(ctx); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected the project created since the plan to be kept, got %v", err)
+	}
+
+	// pruning the projects while others are provisioned
+	other := CreateProjectInput{ID: "project-002", Resources: []Resource{{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "other-bucket"}}}}
+	if err := rm.CreateProject(ctx, &other); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := rm.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: "project-001"})
+		done <- err
+	}()
+	if _, err := wm.Apply(ctx, &ApplyInput{Definitions: definitions, Prune: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.GetProject(ctx, "project-002"); err == nil {
+		t.Fatalf("Expected project-002 to be pruned")
+	}
+}
+
+func TestApplyReferences(t *testing.T) {
+	wm := &WorkflowManager{Projects: &ResourceManager{}}
+	tests := []struct {
+		name        string
+		definitions Definitions
+		err         string
+	}{
+		{
+			name:        "unknown project",
+			definitions: Definitions{Workflows: []WorkflowDefinition{{CreateWorkflowInput: CreateWorkflowInput{ID: "workflow-001", ProjectID: "project-404"}}}},
+			err:         "project project-404 of workflow workflow-001 is not defined",
+		},
+		{
+			name:        "unknown workflow",
+			definitions: Definitions{Triggers: []CreateWorkflowTriggerInput{{ID: "trigger-001", WorkflowID: "workflow-404"}}},
+			err:         "workflow workflow-404 of trigger trigger-001 is not defined",
+		},
+		{
+			name: "duplicate workflow",
+			definitions: Definitions{Workflows: []WorkflowDefinition{
+				{CreateWorkflowInput: CreateWorkflowInput{ID: "workflow-001"}},
+				{CreateWorkflowInput: CreateWorkflowInput{ID: "workflow-001"}},
+			}},
+			err: "duplicate workflow workflow-001",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := wm.Apply(context.Background(), &ApplyInput{Definitions: test.definitions})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
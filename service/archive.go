@@ -0,0 +1,209 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	aeszip "github.com/yeka/zip"
+	"io"
+	"os"
+	"strconv"
+)
+
+// defaultCompressionLevel leaves the choice of level to the compressor.
+const defaultCompressionLevel = -1
+
+// archiveOptions configures how files are packed into an archive.
+type archiveOptions struct {
+	format ArchiveFormat
+	level  int
+	// password enables AES-256 encryption, only supported by zip archives
+	password string
+}
+
+// parseArchiveOptions validates the archive step options. An empty format
+// means zip and an empty level means the compressor default. Only zip archives
+// can be encrypted, the key itself is resolved when the step runs.
+func parseArchiveOptions(format, level, encryptionKey string) (archiveOptions, error) {
+	opts := archiveOptions{
+		format: ArchiveFormat(format),
+		level:  defaultCompressionLevel,
+	}
+	if opts.format == "" {
+		opts.format = ArchiveFormatZip
+	}
+
+	if level != "" {
+		l, err := strconv.Atoi(level)
+		if err != nil {
+			return archiveOptions{}, fmt.Errorf("invalid compression level %q: %v", level, err)
+		}
+		opts.level = l
+	}
+
+	switch opts.format {
+	case ArchiveFormatZip, ArchiveFormatTarGz:
+		if opts.level < flate.HuffmanOnly || opts.level > flate.BestCompression {
+			return archiveOptions{}, fmt.Errorf("compression level %d out of range for %s", opts.level, opts.format)
+		}
+	case ArchiveFormatTarZst:
+		if opts.level != defaultCompressionLevel && (opts.level < 1 || opts.level > 22) {
+			return archiveOptions{}, fmt.Errorf("compression level %d out of range for %s", opts.level, opts.format)
+		}
+	case ArchiveFormatTar:
+	default:
+		return archiveOptions{}, fmt.Errorf("unsupported archive format %q", opts.format)
+	}
+	if encryptionKey != "" && opts.format != ArchiveFormatZip {
+		return archiveOptions{}, fmt.Errorf("encryption is not supported for %s archives", opts.format)
+	}
+	return opts, nil
+}
+
+// writeArchive packs the files into w using the given options. Entries are
+// named after the file paths.
+func writeArchive(ctx context.Context, w io.Writer, files []string, opts archiveOptions) error {
+	if opts.password != "" && opts.format != ArchiveFormatZip {
+		return fmt.Errorf("encryption is not supported for %s archives", opts.format)
+	}
+
+	switch opts.format {
+	case ArchiveFormatZip:
+		if opts.password != "" {
+			return writeEncryptedZip(ctx, w, files, opts.password)
+		}
+		return writeZip(ctx, w, files, opts.level)
+	case ArchiveFormatTar:
+		return writeTar(ctx, w, files)
+	case ArchiveFormatTarGz:
+		gzipWriter, err := gzip.NewWriterLevel(w, opts.level)
+		if err != nil {
+			return fmt.Errorf("error when creating gzip writer: %v", err)
+		}
+		if err := writeTar(ctx, gzipWriter, files); err != nil {
+			gzipWriter.Close()
+			return err
+		}
+		return gzipWriter.Close()
+	case ArchiveFormatTarZst:
+		encoderOpts := make([]zstd.EOption, 0)
+		if opts.level != defaultCompressionLevel {
+			encoderOpts = append(encoderOpts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.level)))
+		}
+		zstdWriter, err := zstd.NewWriter(w, encoderOpts...)
+		if err != nil {
+			return fmt.Errorf("error when creating zstd writer: %v", err)
+		}
+		if err := writeTar(ctx, zstdWriter, files); err != nil {
+			zstdWriter.Close()
+			return err
+		}
+		return zstdWriter.Close()
+	default:
+		return fmt.Errorf("unsupported archive format %q", opts.format)
+	}
+}
+
+func writeZip(ctx context.Context, w io.Writer, files []string, level int) error {
+	zipWriter := zip.NewWriter(w)
+	if level != defaultCompressionLevel {
+		zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+
+	for _, file := range files {
+		err := addArchiveEntry(ctx, file, func(fileInfo os.FileInfo) (io.Writer, error) {
+			header, err := zip.FileInfoHeader(fileInfo)
+			if err != nil {
+				return nil, err
+			}
+			header.Name = file
+			header.Method = zip.Deflate
+			return zipWriter.CreateHeader(header)
+		})
+		if err != nil {
+			zipWriter.Close()
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// writeEncryptedZip writes a WinZip AES-256 encrypted archive. Entries are
+// always compressed with the default deflate level.
+func writeEncryptedZip(ctx context.Context, w io.Writer, files []string, password string) error {
+	zipWriter := aeszip.NewWriter(w)
+	for _, file := range files {
+		err := addArchiveEntry(ctx, file, func(fileInfo os.FileInfo) (io.Writer, error) {
+			header, err := aeszip.FileInfoHeader(fileInfo)
+			if err != nil {
+				return nil, err
+			}
+			header.Name = file
+			header.Method = aeszip.Deflate
+			header.SetPassword(password)
+			header.SetEncryptionMethod(aeszip.AES256Encryption)
+			return zipWriter.CreateHeader(header)
+		})
+		if err != nil {
+			zipWriter.Close()
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+func writeTar(ctx context.Context, w io.Writer, files []string) error {
+	tarWriter := tar.NewWriter(w)
+	for _, file := range files {
+		err := addArchiveEntry(ctx, file, func(fileInfo os.FileInfo) (io.Writer, error) {
+			header, err := tar.FileInfoHeader(fileInfo, "")
+			if err != nil {
+				return nil, err
+			}
+			header.Name = file
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return nil, err
+			}
+			return tarWriter, nil
+		})
+		if err != nil {
+			tarWriter.Close()
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// addArchiveEntry copies a file into the entry writer returned by create.
+func addArchiveEntry(ctx context.Context, file string, create func(os.FileInfo) (io.Writer, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fileToZip, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error when opening file %s: %v", file, err)
+	}
+	defer fileToZip.Close()
+
+	fileInfo, err := fileToZip.Stat()
+	if err != nil {
+		return fmt.Errorf("error when stating file %s: %v", file, err)
+	}
+
+	writer, err := create(fileInfo)
+	if err != nil {
+		return fmt.Errorf("error when creating archive entry of file %s: %v", file, err)
+	}
+
+	if _, err := io.Copy(writer, fileToZip); err != nil {
+		return fmt.Errorf("error when archiving content of file %s: %v", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/klauspost/compress/zstd"
+	aeszip "github.com/yeka/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.log")
+	if err := os.WriteFile(file, []byte("backup content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveFormatZip, ArchiveFormatTar, ArchiveFormatTarGz, ArchiveFormatTarZst} {
+		opts, err := parseArchiveOptions(string(format), "", "")
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := writeArchive(context.Background(), buf, []string{file}, opts); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got := readArchiveEntry(t, format, buf.Bytes(), file); got != "backup content" {
+			t.Fatalf("%s: expected entry content to be %q, got %q", format, "backup content", got)
+		}
+	}
+}
+
+func TestZipFileDirectorySymlink(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"data/a.txt": []byte("a")})
+	if err := os.Symlink(filepath.Join(dir, "data"), filepath.Join(dir, "data-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "data", "a.txt"), filepath.Join(dir, "a-link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := (&ComponentReadFile{}).do(context.Background(), ReadFileInput{directory: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipFile := filepath.Join(t.TempDir(), "backup.zip")
+	if _, err := (&ComponentZipFile{}).do(context.Background(), ZipFileInput{files: read.files, zipFile: zipFile}); err != nil {
+		t.Fatalf("Expected the files next to a directory symlink to be archived, got %v", err)
+	}
+	data, err := os.ReadFile(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != 2 {
+		t.Fatalf("Expected the file and the link to a file to be archived, got %d entries", len(r.File))
+	}
+	if got := readArchiveEntry(t, ArchiveFormatZip, data, filepath.Join(dir, "a-link.txt")); got != "a" {
+		t.Fatalf("Expected the link to a file to hold its target, got %q", got)
+	}
+}
+
+func TestWriteEncryptedZip(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.log")
+	if err := os.WriteFile(file, []byte("secret content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	opts := archiveOptions{format: ArchiveFormatZip, level: defaultCompressionLevel, password: "s3cr3t"}
+	if err := writeArchive(context.Background(), buf, []string{file}, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := aeszip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.File[0].IsEncrypted() {
+		t.Fatalf("Expected entry to be encrypted")
+	}
+	r.File[0].SetPassword("s3cr3t")
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "secret content" {
+		t.Fatalf("Expected decrypted content to be %q, got %q", "secret content", content)
+	}
+}
+
+func TestParseArchiveOptions(t *testing.T) {
+	if _, err := parseArchiveOptions("rar", "", ""); err == nil {
+		t.Fatalf("Expected unsupported format to fail")
+	}
+	if _, err := parseArchiveOptions("tar.gz", "12", ""); err == nil {
+		t.Fatalf("Expected out of range gzip level to fail")
+	}
+	if _, err := parseArchiveOptions("tar", "", "env:BACKUP_KEY"); err == nil {
+		t.Fatalf("Expected an encrypted tar archive to fail")
+	}
+	if _, err := parseArchiveOptions("zip", "", "env:BACKUP_KEY"); err != nil {
+		t.Fatalf("Expected an encrypted zip archive to be accepted, got %v", err)
+	}
+	if _, err := parseArchiveOptions("tar.zst", "19", ""); err != nil {
+		t.Fatalf("Expected zstd level 19 to be accepted, got %v", err)
+	}
+	opts, err := parseArchiveOptions("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.format != ArchiveFormatZip || opts.level != defaultCompressionLevel {
+		t.Fatalf("Expected default zip options, got %+v", opts)
+	}
+}
+
+func readArchiveEntry(t *testing.T, format ArchiveFormat, data []byte, name string) string {
+	t.Helper()
+	if format == ArchiveFormatZip {
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := r.Open(name[1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, _ := io.ReadAll(rc)
+		return string(content)
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	switch format {
+	case ArchiveFormatTarGz:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = gzipReader
+	case ArchiveFormatTarZst:
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	}
+	tarReader := tar.NewReader(reader)
+	header, err := tarReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != name {
+		t.Fatalf("Expected entry name %s, got %s", name, header.Name)
+	}
+	content, _ := io.ReadAll(tarReader)
+	return string(content)
+}
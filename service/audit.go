@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// The outcomes of an audited request
+const (
+	AuditOutcomeSucceeded = "succeeded"
+	AuditOutcomeFailed    = "failed"
+	// AuditOutcomeDenied is the outcome of the requests rejected by the
+	// authentication or the authorization
+	AuditOutcomeDenied = "denied"
+)
+
+// AuditEntry records a request which changes projects, workflows or triggers
+type AuditEntry struct {
+	// ID is the sequence number of the entry in the log
+	ID   int64     `json:"id"`
+	Time time.Time `json:"time"`
+	// Subject and AuthMethod identify the caller, they are empty when the
+	// request was not authenticated
+	Subject    string `json:"subject,omitempty"`
+	AuthMethod string `json:"authMethod,omitempty"`
+	// Route is the name of the route of the request
+	Route   string        `json:"route"`
+	Method  string        `json:"method"`
+	Path    string        `json:"path"`
+	Targets []AuditTarget `json:"targets,omitempty"`
+	// Body is the decoded request body, with the values of its secret fields
+	// redacted
+	Body    interface{} `json:"body,omitempty"`
+	Status  int         `json:"status"`
+	Outcome string      `json:"outcome"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// AuditTarget is a project, workflow, trigger or run changed by a request
+type AuditTarget struct {
+	// Type is project, workflow, trigger or run
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// AuditQuery selects audit entries, the empty fields match any entry
+type AuditQuery struct {
+	Subject string
+	Route   string
+	// Target matches the entries with a target of this ID
+	Target  string
+	Outcome string
+	// Since and Until bound the time of the entries, both are inclusive
+	Since time.Time
+	Until time.Time
+	// Limit keeps the most recent entries
+	Limit int
+}
+
+// AuditLog is an append-only log of audit entries. The zero value keeps the
+// entries in memory, OpenAuditLog also writes them to a JSON lines file.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	file    *os.File
+	now     func() time.Time
+}
+
+// OpenAuditLog loads the entries of a JSON lines file and appends the new
+// entries to it
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error when opening audit log: %v", err)
+	}
+	l := &AuditLog{file: f}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error when reading audit log %s line %d: %v", path, n, err)
+		}
+		l.entries = append(l.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error when reading audit log %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Append numbers and timestamps an entry, then adds it to the log
+func (l *AuditLog) Append(entry AuditEntry) (AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.ID = 1
+	if len(l.entries) > 0 {
+		entry.ID = l.entries[len(l.entries)-1].ID + 1
+	}
+	now := time.Now
+	if l.now != nil {
+		now = l.now
+	}
+	entry.Time = now().UTC()
+	if l.file != nil {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return entry, fmt.Errorf("error when encoding audit entry: %v", err)
+		}
+		if _, err := l.file.Write(append(b, '\n')); err != nil {
+			return entry, fmt.Errorf("error when writing audit log: %v", err)
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return entry, nil
+}
+
+// Query returns the entries matching q, oldest first
+func (l *AuditLog) Query(q AuditQuery) []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]AuditEntry, 0)
+	for _, entry := range l.entries {
+		if q.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries
+}
+
+// Export writes the entries matching q to w as JSON lines
+func (l *AuditLog) Export(w io.Writer, q AuditQuery) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range l.Query(q) {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("error when exporting audit log: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file of the log
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	if q.Subject != "" && entry.Subject != q.Subject {
+		return false
+	}
+	if q.Route != "" && entry.Route != q.Route {
+		return false
+	}
+	if q.Outcome != "" && entry.Outcome != q.Outcome {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && entry.Time.After(q.Until) {
+		return false
+	}
+	if q.Target == "" {
+		return true
+	}
+	for _, target := range entry.Targets {
+		if target.ID == q.Target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := start
+	l.now = func() time.Time { at = at.Add(time.Minute); return at }
+	entries := []AuditEntry{
+		{Subject: "alice", Route: "CreateProject", Targets: []AuditTarget{{Type: "project", ID: "project-001"}}, Outcome: AuditOutcomeSucceeded},
+		{Subject: "bob", Route: "CreateProject", Targets: []AuditTarget{{Type: "project", ID: "project-002"}}, Outcome: AuditOutcomeDenied},
+		{Subject: "alice", Route: "CreateWorkflow", Targets: []AuditTarget{{Type: "workflow", ID: "workflow-001"}}, Outcome: AuditOutcomeFailed},
+	}
+	for _, entry := range entries {
+		if _, err := l.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the entries of the file are loaded, and the new ones appended
+	l, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	entry, err := l.Append(AuditEntry{Subject: "root", Route: "Apply", Outcome: AuditOutcomeSucceeded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID != 4 {
+		t.Fatalf("Expected the entry to follow the entries of the file, got %d", entry.ID)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 4 {
+		t.Fatalf("Expected 4 lines in the file, got %d", lines)
+	}
+
+	tests := []struct {
+		query AuditQuery
+		want  []int64
+	}{
+		{AuditQuery{}, []int64{1, 2, 3, 4}},
+		{AuditQuery{Subject: "alice"}, []int64{1, 3}},
+		{AuditQuery{Route: "CreateProject", Outcome: AuditOutcomeDenied}, []int64{2}},
+		{AuditQuery{Target: "workflow-001"}, []int64{3}},
+		{AuditQuery{Since: start.Add(2 * time.Minute), Until: start.Add(3 * time.Minute)}, []int64{2, 3}},
+		{AuditQuery{Limit: 2}, []int64{3, 4}},
+	}
+	for _, test := range tests {
+		got := l.Query(test.query)
+		ids := make([]int64, len(got))
+		for i, e := range got {
+			ids[i] = e.ID
+		}
+		if len(ids) != len(test.want) {
+			t.Fatalf("Expected %v for %+v, got %v", test.want, test.query, ids)
+		}
+		for i := range ids {
+			if ids[i] != test.want[i] {
+				t.Fatalf("Expected %v for %+v, got %v", test.want, test.query, ids)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := l.Export(&out, AuditQuery{Subject: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"route":"CreateWorkflow"`) {
+		t.Fatalf("Expected the entries of alice as JSON lines, got %s", out.String())
+	}
+}
+
+func TestOpenAuditLogErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, []byte("{\"id\":1}\nnot json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditLog(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Expected the invalid line to be reported, got %v", err)
+	}
+}
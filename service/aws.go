@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// S3API is the subset of the S3 client used by the resource manager and the
+// workflow components. It covers the calls of the multipart uploader.
+type S3API interface {
+	CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error)
+	PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error)
+	PutBucketVersioning(ctx context.Context, params *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error)
+	PutBucketEncryption(ctx context.Context, params *s3.PutBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error)
+	PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error)
+	GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
+	GetBucketEncryption(ctx context.Context, params *s3.GetBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error)
+	GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error)
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
+}
+
+// SQSAPI is the subset of the SQS client used by the resource manager.
+type SQSAPI interface {
+	CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error)
+	GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+	SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error)
+	DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error)
+}
+
+// SNSAPI is the subset of the SNS client used by the resource manager.
+type SNSAPI interface {
+	CreateTopic(ctx context.Context, params *sns.CreateTopicInput, optFns ...func(*sns.Options)) (*sns.CreateTopicOutput, error)
+	ListTopics(ctx context.Context, params *sns.ListTopicsInput, optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error)
+	GetTopicAttributes(ctx context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error)
+	SetTopicAttributes(ctx context.Context, params *sns.SetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.SetTopicAttributesOutput, error)
+	DeleteTopic(ctx context.Context, params *sns.DeleteTopicInput, optFns ...func(*sns.Options)) (*sns.DeleteTopicOutput, error)
+}
+
+// CloudWatchAPI is the subset of the CloudWatch client used by the resource
+// manager.
+type CloudWatchAPI interface {
+	PutMetricAlarm(ctx context.Context, params *cloudwatch.PutMetricAlarmInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricAlarmOutput, error)
+	DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error)
+	DeleteAlarms(ctx context.Context, params *cloudwatch.DeleteAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteAlarmsOutput, error)
+}
+
+// ClientProvider builds the AWS clients used by the managers and components.
+// An empty region selects the default region of the provider.
+type ClientProvider interface {
+	S3(ctx context.Context, region string) (S3API, error)
+	SQS(ctx context.Context, region string) (SQSAPI, error)
+	SNS(ctx context.Context, region string) (SNSAPI, error)
+	CloudWatch(ctx context.Context, region string) (CloudWatchAPI, error)
+}
+
+// AWSConfig configures the clients built by NewAWSClientProvider. Empty fields
+// fall back to the AWS SDK defaults (environment, shared config files).
+type AWSConfig struct {
+	// Region is used when a resource or a step does not set its own region
+	Region string
+	// Endpoint is the URL of an AWS compatible server, e.g. LocalStack, used by
+	// all the clients. S3 only servers such as MinIO only support the S3
+	// resources and steps.
+	Endpoint string
+	// UsePathStyle addresses buckets as http://endpoint/bucket instead of
+	// http://bucket.endpoint, which most S3 compatible servers require
+	UsePathStyle bool
+	// Profile is the shared credentials profile
+	Profile string
+}
+
+type awsClientProvider struct {
+	cfg AWSConfig
+}
+
+// NewAWSClientProvider returns a ClientProvider backed by the AWS SDK.
+func NewAWSClientProvider(cfg AWSConfig) ClientProvider {
+	return &awsClientProvider{cfg: cfg}
+}
+
+func (p *awsClientProvider) S3(ctx context.Context, region string) (S3API, error) {
+	cfg, err := p.loadConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = p.cfg.UsePathStyle
+	}), nil
+}
+
+func (p *awsClientProvider) SQS(ctx context.Context, region string) (SQSAPI, error) {
+	cfg, err := p.loadConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return sqs.NewFromConfig(cfg), nil
+}
+
+func (p *awsClientProvider) SNS(ctx context.Context, region string) (SNSAPI, error) {
+	cfg, err := p.loadConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return sns.NewFromConfig(cfg), nil
+}
+
+func (p *awsClientProvider) CloudWatch(ctx context.Context, region string) (CloudWatchAPI, error) {
+	cfg, err := p.loadConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatch.NewFromConfig(cfg), nil
+}
+
+func (p *awsClientProvider) loadConfig(ctx context.Context, region string) (aws.Config, error) {
+	if region == "" {
+		region = p.cfg.Region
+	}
+	opts := make([]func(*config.LoadOptions) error, 0)
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	if p.cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(p.cfg.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if p.cfg.Endpoint != "" {
+		cfg.BaseEndpoint = aws.String(p.cfg.Endpoint)
+	}
+	return cfg, nil
+}
+
+// defaultClientProvider is used by managers without a ClientProvider.
+var defaultClientProvider = NewAWSClientProvider(AWSConfig{})
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAWSClientProviderEndpoint(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	var mu sync.Mutex
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	p := NewAWSClientProvider(AWSConfig{Region: "us-east-1", Endpoint: server.URL, UsePathStyle: true})
+	calls := map[string]func() error{
+		"S3": func() error {
+			c, err := p.S3(ctx, "")
+			if err != nil {
+				return err
+			}
+			_, err = c.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("bucket")})
+			return err
+		},
+		"SQS": func() error {
+			c, err := p.SQS(ctx, "")
+			if err != nil {
+				return err
+			}
+			_, err = c.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String("queue")})
+			return err
+		},
+		"SNS": func() error {
+			c, err := p.SNS(ctx, "")
+			if err != nil {
+				return err
+			}
+			_, err = c.ListTopics(ctx, &sns.ListTopicsInput{})
+			return err
+		},
+		"CloudWatch": func() error {
+			c, err := p.CloudWatch(ctx, "")
+			if err != nil {
+				return err
+			}
+			_, err = c.DescribeAlarms(ctx, &cloudwatch.DescribeAlarmsInput{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		mu.Lock()
+		before := hits
+		mu.Unlock()
+		if err := call(); err == nil {
+			t.Fatalf("Expected the %s call to fail on the test endpoint", name)
+		}
+		mu.Lock()
+		after := hits
+		mu.Unlock()
+		if after == before {
+			t.Fatalf("Expected the %s client to use the endpoint", name)
+		}
+	}
+}
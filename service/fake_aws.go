@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"sort"
+	"sync"
+)
+
+// fakeAccountID is the account of the ARNs returned by the fakes
+const fakeAccountID = "000000000000"
+
+// FakeSQS is an in-memory implementation of SQSAPI, it only keeps the queues
+// and their attributes.
+type FakeSQS struct {
+	mu     sync.Mutex
+	queues map[string]map[string]string
+}
+
+func NewFakeSQS() *FakeSQS {
+	return &FakeSQS{queues: make(map[string]map[string]string)}
+}
+
+func (f *FakeSQS) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(params.QueueName)
+	url := fmt.Sprintf("https://sqs.fake.amazonaws.com/%s/%s", fakeAccountID, name)
+	if existing, ok := f.queues[url]; ok {
+		// like SQS, creating an existing queue only fails for other attributes
+		for k, v := range params.Attributes {
+			if existing[k] != v {
+				return nil, &sqstypes.QueueNameExists{Message: aws.String(fmt.Sprintf("queue %s exists with other attributes", name))}
+			}
+		}
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String(url)}, nil
+	}
+
+	attributes := map[string]string{
+		string(sqstypes.QueueAttributeNameQueueArn): fmt.Sprintf("arn:aws:sqs:fake:%s:%s", fakeAccountID, name),
+	}
+	for k, v := range params.Attributes {
+		attributes[k] = v
+	}
+	f.queues[url] = attributes
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(url)}, nil
+}
+
+func (f *FakeSQS) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	attributes, ok := f.queues[aws.ToString(params.QueueUrl)]
+	if !ok {
+		return nil, &sqstypes.QueueDoesNotExist{Message: aws.String(fmt.Sprintf("queue %s does not exist", aws.ToString(params.QueueUrl)))}
+	}
+	out := &sqs.GetQueueAttributesOutput{Attributes: make(map[string]string)}
+	for _, name := range params.AttributeNames {
+		if name == sqstypes.QueueAttributeNameAll {
+			for k, v := range attributes {
+				out.Attributes[k] = v
+			}
+			continue
+		}
+		if v, ok := attributes[string(name)]; ok {
+			out.Attributes[string(name)] = v
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeSQS) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(params.QueueName)
+	url := fmt.Sprintf("https://sqs.fake.amazonaws.com/%s/%s", fakeAccountID, name)
+	if _, ok := f.queues[url]; !ok {
+		return nil, &sqstypes.QueueDoesNotExist{Message: aws.String(fmt.Sprintf("queue %s does not exist", name))}
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(url)}, nil
+}
+
+func (f *FakeSQS) SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	attributes, ok := f.queues[aws.ToString(params.QueueUrl)]
+	if !ok {
+		return nil, &sqstypes.QueueDoesNotExist{Message: aws.String(fmt.Sprintf("queue %s does not exist", aws.ToString(params.QueueUrl)))}
+	}
+	for k, v := range params.Attributes {
+		attributes[k] = v
+	}
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *FakeSQS) DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	url := aws.ToString(params.QueueUrl)
+	if _, ok := f.queues[url]; !ok {
+		return nil, &sqstypes.QueueDoesNotExist{Message: aws.String(fmt.Sprintf("queue %s does not exist", url))}
+	}
+	delete(f.queues, url)
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+// FakeSNS is an in-memory implementation of SNSAPI, it only keeps the topics
+// and their attributes.
+type FakeSNS struct {
+	mu     sync.Mutex
+	topics map[string]map[string]string
+}
+
+func NewFakeSNS() *FakeSNS {
+	return &FakeSNS{topics: make(map[string]map[string]string)}
+}
+
+func (f *FakeSNS) CreateTopic(ctx context.Context, params *sns.CreateTopicInput, optFns ...func(*sns.Options)) (*sns.CreateTopicOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	arn := fmt.Sprintf("arn:aws:sns:fake:%s:%s", fakeAccountID, aws.ToString(params.Name))
+	attributes := make(map[string]string)
+	for k, v := range params.Attributes {
+		attributes[k] = v
+	}
+	f.topics[arn] = attributes
+	return &sns.CreateTopicOutput{TopicArn: aws.String(arn)}, nil
+}
+
+func (f *FakeSNS) ListTopics(ctx context.Context, params *sns.ListTopicsInput, optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	arns := make([]string, 0, len(f.topics))
+	for arn := range f.topics {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	out := &sns.ListTopicsOutput{}
+	for _, arn := range arns {
+		out.Topics = append(out.Topics, snstypes.Topic{TopicArn: aws.String(arn)})
+	}
+	return out, nil
+}
+
+func (f *FakeSNS) GetTopicAttributes(ctx context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	arn := aws.ToString(params.TopicArn)
+	attributes, ok := f.topics[arn]
+	if !ok {
+		return nil, &snstypes.NotFoundException{Message: aws.String(fmt.Sprintf("topic %s does not exist", arn))}
+	}
+	out := &sns.GetTopicAttributesOutput{Attributes: map[string]string{"TopicArn": arn}}
+	for k, v := range attributes {
+		out.Attributes[k] = v
+	}
+	return out, nil
+}
+
+func (f *FakeSNS) SetTopicAttributes(ctx context.Context, params *sns.SetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.SetTopicAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	arn := aws.ToString(params.TopicArn)
+	attributes, ok := f.topics[arn]
+	if !ok {
+		return nil, &snstypes.NotFoundException{Message: aws.String(fmt.Sprintf("topic %s does not exist", arn))}
+	}
+	attributes[aws.ToString(params.AttributeName)] = aws.ToString(params.AttributeValue)
+	return &sns.SetTopicAttributesOutput{}, nil
+}
+
+func (f *FakeSNS) DeleteTopic(ctx context.Context, params *sns.DeleteTopicInput, optFns ...func(*sns.Options)) (*sns.DeleteTopicOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// like SNS, deleting a missing topic succeeds
+	delete(f.topics, aws.ToString(params.TopicArn))
+	return &sns.DeleteTopicOutput{}, nil
+}
+
+// FakeCloudWatch is an in-memory implementation of CloudWatchAPI, it only
+// keeps the metric alarms.
+type FakeCloudWatch struct {
+	mu     sync.Mutex
+	alarms map[string]cwtypes.MetricAlarm
+}
+
+func NewFakeCloudWatch() *FakeCloudWatch {
+	return &FakeCloudWatch{alarms: make(map[string]cwtypes.MetricAlarm)}
+}
+
+func (f *FakeCloudWatch) PutMetricAlarm(ctx context.Context, params *cloudwatch.PutMetricAlarmInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricAlarmOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(params.AlarmName)
+	f.alarms[name] = cwtypes.MetricAlarm{
+		AlarmName:          params.AlarmName,
+		AlarmArn:           aws.String(fmt.Sprintf("arn:aws:cloudwatch:fake:%s:alarm:%s", fakeAccountID, name)),
+		Namespace:          params.Namespace,
+		MetricName:         params.MetricName,
+		Statistic:          params.Statistic,
+		Period:             params.Period,
+		EvaluationPeriods:  params.EvaluationPeriods,
+		Threshold:          params.Threshold,
+		ComparisonOperator: params.ComparisonOperator,
+		TreatMissingData:   params.TreatMissingData,
+		AlarmActions:       params.AlarmActions,
+		Dimensions:         params.Dimensions,
+	}
+	return &cloudwatch.PutMetricAlarmOutput{}, nil
+}
+
+func (f *FakeCloudWatch) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &cloudwatch.DescribeAlarmsOutput{}
+	for _, name := range params.AlarmNames {
+		if alarm, ok := f.alarms[name]; ok {
+			out.MetricAlarms = append(out.MetricAlarms, alarm)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeCloudWatch) DeleteAlarms(ctx context.Context, params *cloudwatch.DeleteAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteAlarmsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, name := range params.AlarmNames {
+		if _, ok := f.alarms[name]; !ok {
+			return nil, &cwtypes.ResourceNotFound{Message: aws.String(fmt.Sprintf("alarm %s does not exist", name))}
+		}
+	}
+	for _, name := range params.AlarmNames {
+		delete(f.alarms, name)
+	}
+	return &cloudwatch.DeleteAlarmsOutput{}, nil
+}
@@ -0,0 +1,634 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeClientProvider serves in-memory fakes of the AWS services, so workflows
+// and resources can be exercised without an AWS account.
+type FakeClientProvider struct {
+	s3         *FakeS3
+	sqs        *FakeSQS
+	sns        *FakeSNS
+	cloudWatch *FakeCloudWatch
+}
+
+// NewFakeClientProvider returns a provider whose clients share one empty
+// in-memory backend for all regions.
+func NewFakeClientProvider() *FakeClientProvider {
+	return &FakeClientProvider{
+		s3:         NewFakeS3(),
+		sqs:        NewFakeSQS(),
+		sns:        NewFakeSNS(),
+		cloudWatch: NewFakeCloudWatch(),
+	}
+}
+
+func (p *FakeClientProvider) S3(ctx context.Context, region string) (S3API, error) {
+	return p.s3, nil
+}
+
+func (p *FakeClientProvider) SQS(ctx context.Context, region string) (SQSAPI, error) {
+	return p.sqs, nil
+}
+
+func (p *FakeClientProvider) SNS(ctx context.Context, region string) (SNSAPI, error) {
+	return p.sns, nil
+}
+
+func (p *FakeClientProvider) CloudWatch(ctx context.Context, region string) (CloudWatchAPI, error) {
+	return p.cloudWatch, nil
+}
+
+// FakeS3 returns the S3 backend of the provider
+func (p *FakeClientProvider) FakeS3() *FakeS3 {
+	return p.s3
+}
+
+// FakeS3 is an in-memory implementation of S3API. It supports buckets, objects
+// and multipart uploads, errors are returned as the S3 error types.
+type FakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]*fakeBucket
+	uploads map[string]*fakeUpload
+	nextID  int
+}
+
+type fakeBucket struct {
+	region     string
+	objects    map[string]*fakeObject
+	policy     string
+	lifecycle  []types.LifecycleRule
+	versioning types.BucketVersioningStatus
+	encryption *types.ServerSideEncryptionConfiguration
+	tags       []types.Tag
+}
+
+type fakeObject struct {
+	body         []byte
+	etag         string
+	lastModified time.Time
+	contentType  string
+	metadata     map[string]string
+	storageClass types.StorageClass
+}
+
+type fakeUpload struct {
+	bucket string
+	key    string
+	parts  map[int32][]byte
+	object *fakeObject
+}
+
+func NewFakeS3() *FakeS3 {
+	return &FakeS3{
+		buckets: make(map[string]*fakeBucket),
+		uploads: make(map[string]*fakeUpload),
+	}
+}
+
+func (f *FakeS3) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(params.Bucket)
+	if name == "" {
+		return nil, fmt.Errorf("bucket name is required")
+	}
+	if _, ok := f.buckets[name]; ok {
+		return nil, &types.BucketAlreadyOwnedByYou{Message: aws.String(fmt.Sprintf("bucket %s already exists", name))}
+	}
+
+	region := "us-east-1"
+	if params.CreateBucketConfiguration != nil && params.CreateBucketConfiguration.LocationConstraint != "" {
+		region = string(params.CreateBucketConfiguration.LocationConstraint)
+	}
+	f.buckets[name] = &fakeBucket{
+		region:  region,
+		objects: make(map[string]*fakeObject),
+	}
+	return &s3.CreateBucketOutput{Location: aws.String("/" + name)}, nil
+}
+
+func (f *FakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var body []byte
+	if params.Body != nil {
+		var err error
+		if body, err = io.ReadAll(params.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(body)
+	if params.ServerSideEncryption == types.ServerSideEncryptionAwsKms {
+		// the ETag of an SSE-KMS object is not the MD5 of its content
+		sum = md5.Sum(append([]byte(aws.ToString(params.SSEKMSKeyId)), body...))
+	}
+	object := &fakeObject{
+		body:         body,
+		etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		lastModified: time.Now().UTC(),
+		contentType:  aws.ToString(params.ContentType),
+		metadata:     params.Metadata,
+		storageClass: params.StorageClass,
+	}
+	bucket.objects[aws.ToString(params.Key)] = object
+	return &s3.PutObjectOutput{ETag: aws.String(object.etag)}, nil
+}
+
+func (f *FakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := bucket.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String(fmt.Sprintf("key %s does not exist", aws.ToString(params.Key)))}
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(object.body)),
+		ContentLength: aws.Int64(int64(len(object.body))),
+		ContentType:   aws.String(object.contentType),
+		ETag:          aws.String(object.etag),
+		LastModified:  aws.Time(object.lastModified),
+		Metadata:      object.metadata,
+		StorageClass:  object.storageClass,
+	}, nil
+}
+
+func (f *FakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := bucket.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NotFound{Message: aws.String(fmt.Sprintf("key %s does not exist", aws.ToString(params.Key)))}
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(object.body))),
+		ContentType:   aws.String(object.contentType),
+		ETag:          aws.String(object.etag),
+		LastModified:  aws.Time(object.lastModified),
+		Metadata:      object.metadata,
+		StorageClass:  object.storageClass,
+	}, nil
+}
+
+func (f *FakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := aws.ToString(params.Prefix)
+	// the continuation token is the last key of the previous page
+	after := aws.ToString(params.StartAfter)
+	if token := aws.ToString(params.ContinuationToken); token != "" {
+		after = token
+	}
+	maxKeys := int(aws.ToInt32(params.MaxKeys))
+	if maxKeys <= 0 || maxKeys > 1000 {
+		maxKeys = 1000
+	}
+
+	keys := make([]string, 0)
+	for key := range bucket.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{
+		Name:        params.Bucket,
+		Prefix:      params.Prefix,
+		MaxKeys:     aws.Int32(int32(maxKeys)),
+		IsTruncated: aws.Bool(len(keys) > maxKeys),
+	}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		object := bucket.objects[key]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(object.body))),
+			ETag:         aws.String(object.etag),
+			LastModified: aws.Time(object.lastModified),
+			StorageClass: types.ObjectStorageClass(object.storageClass),
+		})
+	}
+	out.KeyCount = aws.Int32(int32(len(out.Contents)))
+	return out, nil
+}
+
+func (f *FakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	delete(bucket.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *FakeS3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	out := &s3.DeleteObjectsOutput{}
+	if params.Delete == nil {
+		return out, nil
+	}
+	for _, identifier := range params.Delete.Objects {
+		delete(bucket.objects, aws.ToString(identifier.Key))
+		if !aws.ToBool(params.Delete.Quiet) {
+			out.Deleted = append(out.Deleted, types.DeletedObject{Key: identifier.Key})
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	f.nextID++
+	uploadID := strconv.Itoa(f.nextID)
+	f.uploads[uploadID] = &fakeUpload{
+		bucket: aws.ToString(params.Bucket),
+		key:    aws.ToString(params.Key),
+		parts:  make(map[int32][]byte),
+		object: &fakeObject{
+			contentType:  aws.ToString(params.ContentType),
+			metadata:     params.Metadata,
+			storageClass: params.StorageClass,
+		},
+	}
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   params.Bucket,
+		Key:      params.Key,
+		UploadId: aws.String(uploadID),
+	}, nil
+}
+
+func (f *FakeS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	var body []byte
+	if params.Body != nil {
+		var err error
+		if body, err = io.ReadAll(params.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	upload, err := f.upload(params.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.parts[aws.ToInt32(params.PartNumber)] = body
+	sum := md5.Sum(body)
+	return &s3.UploadPartOutput{ETag: aws.String(`"` + hex.EncodeToString(sum[:]) + `"`)}, nil
+}
+
+func (f *FakeS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	uploadID := aws.ToString(params.UploadId)
+	upload, err := f.upload(params.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := f.bucket(aws.String(upload.bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(upload.parts))
+	if params.MultipartUpload != nil {
+		for _, part := range params.MultipartUpload.Parts {
+			numbers = append(numbers, int(aws.ToInt32(part.PartNumber)))
+		}
+	}
+	sort.Ints(numbers)
+	body := &bytes.Buffer{}
+	for _, n := range numbers {
+		part, ok := upload.parts[int32(n)]
+		if !ok {
+			return nil, fmt.Errorf("part %d of upload %s was not uploaded", n, uploadID)
+		}
+		body.Write(part)
+	}
+
+	sum := md5.Sum(body.Bytes())
+	object := upload.object
+	object.body = body.Bytes()
+	object.etag = fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(numbers))
+	object.lastModified = time.Now().UTC()
+	bucket.objects[upload.key] = object
+	delete(f.uploads, uploadID)
+
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:   aws.String(upload.bucket),
+		Key:      aws.String(upload.key),
+		ETag:     aws.String(object.etag),
+		Location: aws.String("/" + upload.bucket + "/" + upload.key),
+	}, nil
+}
+
+func (f *FakeS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.upload(params.UploadId); err != nil {
+		return nil, err
+	}
+	delete(f.uploads, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *FakeS3) PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.policy = aws.ToString(params.Policy)
+	return &s3.PutBucketPolicyOutput{}, nil
+}
+
+func (f *FakeS3) PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.lifecycle = nil
+	if params.LifecycleConfiguration != nil {
+		bucket.lifecycle = params.LifecycleConfiguration.Rules
+	}
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (f *FakeS3) PutBucketVersioning(ctx context.Context, params *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if params.VersioningConfiguration != nil {
+		bucket.versioning = params.VersioningConfiguration.Status
+	}
+	return &s3.PutBucketVersioningOutput{}, nil
+}
+
+func (f *FakeS3) PutBucketEncryption(ctx context.Context, params *s3.PutBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.encryption = params.ServerSideEncryptionConfiguration
+	return &s3.PutBucketEncryptionOutput{}, nil
+}
+
+func (f *FakeS3) PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.tags = nil
+	if params.Tagging != nil {
+		bucket.tags = params.Tagging.TagSet
+	}
+	return &s3.PutBucketTaggingOutput{}, nil
+}
+
+func (f *FakeS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, ok := f.buckets[aws.ToString(params.Bucket)]
+	if !ok {
+		return nil, &types.NotFound{Message: aws.String(fmt.Sprintf("bucket %s does not exist", aws.ToString(params.Bucket)))}
+	}
+	return &s3.HeadBucketOutput{BucketRegion: aws.String(bucket.region)}, nil
+}
+
+func (f *FakeS3) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.policy == "" {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchBucketPolicy", Message: "the bucket policy does not exist"}
+	}
+	return &s3.GetBucketPolicyOutput{Policy: aws.String(bucket.policy)}, nil
+}
+
+func (f *FakeS3) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(bucket.lifecycle) == 0 {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchLifecycleConfiguration", Message: "the lifecycle configuration does not exist"}
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: bucket.lifecycle}, nil
+}
+
+func (f *FakeS3) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetBucketVersioningOutput{Status: bucket.versioning}, nil
+}
+
+func (f *FakeS3) GetBucketEncryption(ctx context.Context, params *s3.GetBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.encryption == nil {
+		return nil, &smithy.GenericAPIError{Code: "ServerSideEncryptionConfigurationNotFoundError", Message: "the server side encryption configuration was not found"}
+	}
+	return &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: bucket.encryption}, nil
+}
+
+func (f *FakeS3) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(bucket.tags) == 0 {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchTagSet", Message: "the tag set does not exist"}
+	}
+	return &s3.GetBucketTaggingOutput{TagSet: bucket.tags}, nil
+}
+
+// ListObjectVersions lists the objects as their only version, the fake does not
+// keep previous versions.
+func (f *FakeS3) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := aws.ToString(params.Prefix)
+	after := aws.ToString(params.KeyMarker)
+	maxKeys := int(aws.ToInt32(params.MaxKeys))
+	if maxKeys <= 0 || maxKeys > 1000 {
+		maxKeys = 1000
+	}
+	keys := make([]string, 0)
+	for key := range bucket.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectVersionsOutput{
+		Name:        params.Bucket,
+		Prefix:      params.Prefix,
+		IsTruncated: aws.Bool(len(keys) > maxKeys),
+	}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		out.NextKeyMarker = aws.String(keys[len(keys)-1])
+		out.NextVersionIdMarker = aws.String("null")
+	}
+	for _, key := range keys {
+		object := bucket.objects[key]
+		out.Versions = append(out.Versions, types.ObjectVersion{
+			Key:          aws.String(key),
+			VersionId:    aws.String("null"),
+			IsLatest:     aws.Bool(true),
+			Size:         aws.Int64(int64(len(object.body))),
+			ETag:         aws.String(object.etag),
+			LastModified: aws.Time(object.lastModified),
+		})
+	}
+	return out, nil
+}
+
+func (f *FakeS3) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(bucket.objects) > 0 {
+		return nil, &smithy.GenericAPIError{Code: "BucketNotEmpty", Message: "the bucket you tried to delete is not empty"}
+	}
+	delete(f.buckets, aws.ToString(params.Bucket))
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+// Object returns the content of an object, for assertions in tests
+func (f *FakeS3) Object(bucket string, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, ok := f.buckets[bucket]
+	if !ok {
+		return nil, false
+	}
+	object, ok := b.objects[key]
+	if !ok {
+		return nil, false
+	}
+	return object.body, true
+}
+
+func (f *FakeS3) bucket(name *string) (*fakeBucket, error) {
+	bucket, ok := f.buckets[aws.ToString(name)]
+	if !ok {
+		return nil, &types.NoSuchBucket{Message: aws.String(fmt.Sprintf("bucket %s does not exist", aws.ToString(name)))}
+	}
+	return bucket, nil
+}
+
+func (f *FakeS3) upload(id *string) (*fakeUpload, error) {
+	upload, ok := f.uploads[aws.ToString(id)]
+	if !ok {
+		return nil, &types.NoSuchUpload{Message: aws.String(fmt.Sprintf("upload %s does not exist", aws.ToString(id)))}
+	}
+	return upload, nil
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SymlinkInclude lists the symbolic links to files, which are read as their
+	// target, and ignores the links to directories and the broken links
+	SymlinkInclude = "include"
+	// SymlinkSkip ignores symbolic links
+	SymlinkSkip = "skip"
+	// SymlinkFollow lists the targets of symbolic links and walks linked directories
+	SymlinkFollow = "follow"
+)
+
+// FileEntry describes a file listed by ComponentReadFile
+type FileEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// fileFilter selects the files listed by ComponentReadFile.
+//
+// Patterns without a slash are matched against the name of every file and
+// directory, e.g. "node_modules" or "*.tmp". Patterns with a slash are matched
+// against the path relative to the walked directory and may use "**" to match
+// any number of directories, e.g. "logs/**/*.gz". An excluded directory is not
+// walked at all, include patterns only apply to files.
+type fileFilter struct {
+	include  []string
+	exclude  []string
+	maxDepth int
+	symlinks string
+	// modifiedAfter and modifiedBefore are either RFC 3339 timestamps or
+	// durations relative to the start of the walk
+	modifiedAfter  string
+	modifiedBefore string
+	minSize        int64
+	maxSize        int64
+}
+
+func parseFileFilter(options map[string]string) (fileFilter, error) {
+	filter := fileFilter{
+		include:        splitPatterns(options["include"]),
+		exclude:        splitPatterns(options["exclude"]),
+		symlinks:       options["symlinks"],
+		modifiedAfter:  options["modifiedAfter"],
+		modifiedBefore: options["modifiedBefore"],
+		maxSize:        -1,
+	}
+
+	for _, pattern := range append(append([]string{}, filter.include...), filter.exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fileFilter{}, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	switch filter.symlinks {
+	case "":
+		filter.symlinks = SymlinkInclude
+	case SymlinkInclude, SymlinkSkip, SymlinkFollow:
+	default:
+		return fileFilter{}, fmt.Errorf("unsupported symlink handling %q", filter.symlinks)
+	}
+
+	var err error
+	if filter.maxDepth, err = parseNonNegative("maxDepth", options["maxDepth"], 0); err != nil {
+		return fileFilter{}, err
+	}
+	var size int
+	if size, err = parseNonNegative("minSize", options["minSize"], 0); err != nil {
+		return fileFilter{}, err
+	}
+	filter.minSize = int64(size)
+	if size, err = parseNonNegative("maxSize", options["maxSize"], -1); err != nil {
+		return fileFilter{}, err
+	}
+	filter.maxSize = int64(size)
+
+	now := time.Now()
+	if _, err := parseTimeBound(filter.modifiedAfter, now); err != nil {
+		return fileFilter{}, err
+	}
+	if _, err := parseTimeBound(filter.modifiedBefore, now); err != nil {
+		return fileFilter{}, err
+	}
+	return filter, nil
+}
+
+// walkFiles lists the files under root which pass the filter.
+func (f fileFilter) walkFiles(ctx context.Context, root string) ([]FileEntry, error) {
+	now := time.Now()
+	after, _ := parseTimeBound(f.modifiedAfter, now)
+	before, _ := parseTimeBound(f.modifiedBefore, now)
+	w := &fileWalker{
+		ctx:     ctx,
+		filter:  f,
+		after:   after,
+		before:  before,
+		entries: make([]FileEntry, 0),
+		visited: make(map[string]bool),
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("error when stating %s: %v", root, err)
+	}
+	if !info.IsDir() {
+		w.add(root, path.Base(filepath.ToSlash(root)), info)
+		return w.entries, nil
+	}
+
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		w.visited[real] = true
+	}
+	if err := w.walk(root, "", 1); err != nil {
+		return nil, err
+	}
+	return w.entries, nil
+}
+
+type fileWalker struct {
+	ctx     context.Context
+	filter  fileFilter
+	after   time.Time
+	before  time.Time
+	entries []FileEntry
+	// visited holds the real paths of the walked directories, so that
+	// followed symbolic links cannot cause a loop
+	visited map[string]bool
+}
+
+func (w *fileWalker) walk(dir string, rel string, depth int) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error when reading directory %s: %v", dir, err)
+	}
+	for _, d := range dirEntries {
+		filePath := filepath.Join(dir, d.Name())
+		relPath := path.Join(rel, d.Name())
+		if w.filter.excluded(relPath) {
+			continue
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("error when stating %s: %v", filePath, err)
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			switch w.filter.symlinks {
+			case SymlinkSkip:
+				continue
+			case SymlinkInclude:
+				if info, err = os.Stat(filePath); err != nil || info.IsDir() {
+					continue
+				}
+			case SymlinkFollow:
+				if info, err = os.Stat(filePath); err != nil {
+					return fmt.Errorf("error when following symlink %s: %v", filePath, err)
+				}
+			}
+		}
+
+		if !info.IsDir() {
+			w.add(filePath, relPath, info)
+			continue
+		}
+		if w.filter.maxDepth > 0 && depth >= w.filter.maxDepth {
+			continue
+		}
+		real, err := filepath.EvalSymlinks(filePath)
+		if err != nil {
+			return fmt.Errorf("error when resolving %s: %v", filePath, err)
+		}
+		if w.visited[real] {
+			continue
+		}
+		w.visited[real] = true
+		if err := w.walk(filePath, relPath, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *fileWalker) add(filePath string, relPath string, info fs.FileInfo) {
+	f := w.filter
+	if len(f.include) > 0 && !matchAnyPattern(f.include, relPath) {
+		return
+	}
+	if info.Size() < f.minSize || (f.maxSize >= 0 && info.Size() > f.maxSize) {
+		return
+	}
+	if !w.after.IsZero() && !info.ModTime().After(w.after) {
+		return
+	}
+	if !w.before.IsZero() && !info.ModTime().Before(w.before) {
+		return
+	}
+	w.entries = append(w.entries, FileEntry{
+		path:    filePath,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		mode:    info.Mode(),
+	})
+}
+
+func (f fileFilter) excluded(relPath string) bool {
+	return matchAnyPattern(f.exclude, relPath)
+}
+
+func matchAnyPattern(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(relPath)); ok {
+				return true
+			}
+			continue
+		}
+		if matchPathPattern(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(relPath, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathPattern matches path segments against pattern segments, where a
+// "**" segment matches any number of path segments.
+func matchPathPattern(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPathPattern(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchPathPattern(pattern[1:], segments[1:])
+}
+
+func splitPatterns(s string) []string {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(s, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func parseNonNegative(name string, s string, defaultValue int) (int, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, s, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return n, nil
+}
+
+// parseTimeBound parses an RFC 3339 timestamp, or a duration which is
+// subtracted from now. An empty string is the zero time.
+func parseTimeBound(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected an RFC 3339 timestamp or a duration", s)
+	}
+	return now.Add(-d), nil
+}
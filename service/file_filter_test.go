@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFileFilters(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.log":                 "log",
+		"big.bin":                 "0123456789",
+		"src/main.go":             "package main",
+		"src/node_modules/x/y.js": "js",
+		".git/HEAD":               "ref",
+		"logs/2024/10/app.log.gz": "gz",
+		"logs/2024/10/deep/a.txt": "a",
+	} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "src"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "app.log"), filepath.Join(dir, "latest.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the link to a directory is ignored, the link to a file is listed
+	c := &ComponentReadFile{}
+	out, err := c.do(context.Background(), ReadFileInput{directory: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.files) != 8 {
+		t.Fatalf("Expected 8 files without filters, got %v", out.files)
+	}
+	for _, entry := range out.entries {
+		if entry.path == filepath.Join(dir, "link") {
+			t.Fatalf("Expected the link to a directory to be ignored, got %v", out.files)
+		}
+		if entry.path == filepath.Join(dir, "latest.log") && (entry.size != 3 || !entry.mode.IsRegular()) {
+			t.Fatalf("Expected the link to a file to be listed as its target, got %+v", entry)
+		}
+	}
+
+	cases := []struct {
+		options map[string]string
+		want    []string
+	}{
+		{map[string]string{"exclude": "node_modules,.git", "symlinks": "skip"}, []string{"app.log", "big.bin", "logs/2024/10/app.log.gz", "logs/2024/10/deep/a.txt", "src/main.go"}},
+		{map[string]string{"include": "logs/**/*.gz"}, []string{"logs/2024/10/app.log.gz"}},
+		{map[string]string{"maxDepth": "1", "symlinks": "skip"}, []string{"app.log", "big.bin"}},
+		{map[string]string{"minSize": "5", "symlinks": "skip"}, []string{"big.bin", "src/main.go"}},
+		{map[string]string{"symlinks": "follow", "include": "link/**"}, []string{"link/main.go", "link/node_modules/x/y.js"}},
+		{map[string]string{"modifiedBefore": "1h", "symlinks": "skip"}, []string{}},
+	}
+	for _, tc := range cases {
+		filter, err := parseFileFilter(tc.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &ComponentReadFile{filter: filter}
+		out, err := c.do(context.Background(), ReadFileInput{directory: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.entries) != len(tc.want) {
+			t.Fatalf("%v: expected %v, got %v", tc.options, tc.want, out.files)
+		}
+		for i, entry := range out.entries {
+			if entry.path != filepath.Join(dir, tc.want[i]) {
+				t.Fatalf("%v: expected %v, got %v", tc.options, tc.want, out.files)
+			}
+			if entry.size == 0 || entry.modTime.IsZero() || !entry.mode.IsRegular() {
+				t.Fatalf("%v: expected file metadata, got %+v", tc.options, entry)
+			}
+		}
+	}
+}
+
+func TestReadFileWalkError(t *testing.T) {
+	c := &ComponentReadFile{}
+	if _, err := c.do(context.Background(), ReadFileInput{directory: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatalf("Expected walking a missing directory to fail")
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// objectOptions configures the keys and attributes of uploaded objects.
+type objectOptions struct {
+	prefix string
+	// baseDir is stripped from the file paths to build RelPath
+	baseDir      string
+	keyTemplate  *template.Template
+	storageClass types.StorageClass
+	sseKMSKeyID  string
+	tags         map[string]string
+	metadata     map[string]string
+}
+
+// objectKeyData is the data available to the key template.
+type objectKeyData struct {
+	Date    string
+	Year    string
+	Month   string
+	Day     string
+	RunID   string
+	RelPath string
+	Base    string
+}
+
+// parseObjectOptions validates the object step options. Without a key template
+// the object key is the relative path of the file.
+func parseObjectOptions(options map[string]string) (objectOptions, error) {
+	opts := objectOptions{
+		prefix:       strings.Trim(options["prefix"], "/"),
+		baseDir:      options["baseDir"],
+		storageClass: types.StorageClass(options["storageClass"]),
+		sseKMSKeyID:  options["sseKmsKeyId"],
+	}
+
+	if keyTemplate := options["keyTemplate"]; keyTemplate != "" {
+		t, err := template.New("key").Option("missingkey=error").Parse(keyTemplate)
+		if err != nil {
+			return objectOptions{}, fmt.Errorf("invalid key template %q: %v", keyTemplate, err)
+		}
+		opts.keyTemplate = t
+	}
+
+	if opts.storageClass != "" {
+		valid := false
+		for _, sc := range opts.storageClass.Values() {
+			if sc == opts.storageClass {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return objectOptions{}, fmt.Errorf("unsupported storage class %q", opts.storageClass)
+		}
+	}
+
+	var err error
+	if opts.tags, err = parseKeyValues(options["tags"]); err != nil {
+		return objectOptions{}, fmt.Errorf("invalid tags: %v", err)
+	}
+	if opts.metadata, err = parseKeyValues(options["metadata"]); err != nil {
+		return objectOptions{}, fmt.Errorf("invalid metadata: %v", err)
+	}
+	return opts, nil
+}
+
+// key builds the object key of a file uploaded by the run at the given time.
+func (o objectOptions) key(file string, runID string, now time.Time) (string, error) {
+	relPath := file
+	if o.baseDir != "" {
+		rel, err := filepath.Rel(o.baseDir, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("file %s is not under base directory %s", file, o.baseDir)
+		}
+		relPath = rel
+	}
+	// absolute paths would start the keys with an empty folder
+	relPath = strings.TrimLeft(filepath.ToSlash(relPath), "/")
+
+	key := relPath
+	if o.keyTemplate != nil {
+		now = now.UTC()
+		data := objectKeyData{
+			Date:    now.Format("2006-01-02"),
+			Year:    now.Format("2006"),
+			Month:   now.Format("01"),
+			Day:     now.Format("02"),
+			RunID:   runID,
+			RelPath: relPath,
+			Base:    path.Base(relPath),
+		}
+		b := &strings.Builder{}
+		if err := o.keyTemplate.Execute(b, data); err != nil {
+			return "", fmt.Errorf("failed to render key of file %s: %v", file, err)
+		}
+		key = b.String()
+	}
+
+	key = strings.TrimLeft(key, "/")
+	if o.prefix != "" {
+		key = o.prefix + "/" + key
+	}
+	if key == "" {
+		return "", fmt.Errorf("empty object key for file %s", file)
+	}
+	return key, nil
+}
+
+// apply sets the object attributes on a PutObject request.
+func (o objectOptions) apply(input *s3.PutObjectInput) {
+	if o.storageClass != "" {
+		input.StorageClass = o.storageClass
+	}
+	if o.sseKMSKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(o.sseKMSKeyID)
+	}
+	if len(o.tags) > 0 {
+		tags := url.Values{}
+		for k, v := range o.tags {
+			tags.Set(k, v)
+		}
+		input.Tagging = aws.String(tags.Encode())
+	}
+	if len(o.metadata) > 0 {
+		input.Metadata = o.metadata
+	}
+}
+
+// detectContentType guesses the content type of a file from its extension and
+// falls back to sniffing the first bytes. The file offset is reset afterwards.
+func detectContentType(file *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(file.Name())); contentType != "" {
+		return contentType, nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// parseKeyValues parses a comma separated list of key=value pairs.
+func parseKeyValues(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	values := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		values[k] = strings.TrimSpace(v)
+	}
+	return values, nil
+}
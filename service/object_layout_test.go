@@ -0,0 +1,66 @@
+package service
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"testing"
+	"time"
+)
+
+func TestObjectKey(t *testing.T) {
+	now := time.Date(2024, 10, 3, 23, 0, 0, 0, time.UTC)
+
+	opts, err := parseObjectOptions(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := opts.key("/var/data/x", "run-1", now); key != "var/data/x" {
+		t.Fatalf("Expected the file path without its leading slash as default key, got %s", key)
+	}
+
+	opts, err = parseObjectOptions(map[string]string{
+		"prefix":      "/backups/",
+		"baseDir":     "/var/data",
+		"keyTemplate": "{{.Date}}/{{.RunID}}/{{.RelPath}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := opts.key("/var/data/logs/app.log", "run-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "backups/2024-10-03/run-1/logs/app.log" {
+		t.Fatalf("Expected key backups/2024-10-03/run-1/logs/app.log, got %s", key)
+	}
+
+	if _, err := opts.key("/etc/passwd", "run-1", now); err == nil {
+		t.Fatalf("Expected a file outside the base directory to fail")
+	}
+}
+
+func TestObjectOptionsApply(t *testing.T) {
+	if _, err := parseObjectOptions(map[string]string{"storageClass": "COLD"}); err == nil {
+		t.Fatalf("Expected an unknown storage class to fail")
+	}
+
+	opts, err := parseObjectOptions(map[string]string{
+		"storageClass": "STANDARD_IA",
+		"sseKmsKeyId":  "alias/backup",
+		"tags":         "team=ops, retention=90d",
+		"metadata":     "source=nightly",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := &s3.PutObjectInput{}
+	opts.apply(input)
+	if input.StorageClass != "STANDARD_IA" || input.ServerSideEncryption != "aws:kms" || *input.SSEKMSKeyId != "alias/backup" {
+		t.Fatalf("Unexpected object attributes %+v", input)
+	}
+	if *input.Tagging != "retention=90d&team=ops" {
+		t.Fatalf("Expected tagging retention=90d&team=ops, got %s", *input.Tagging)
+	}
+	if input.Metadata["source"] != "nightly" {
+		t.Fatalf("Expected metadata source=nightly, got %v", input.Metadata)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"strconv"
+	"strings"
+)
+
+type alarmInput struct {
+	region string
+	params *cloudwatch.PutMetricAlarmInput
+}
+
+func newAlarmInput(r Resource) (alarmInput, error) {
+	period, _ := propertyIntValue(r, "Period")
+	evaluationPeriods, _ := propertyIntValue(r, "EvaluationPeriods")
+	threshold, _ := strconv.ParseFloat(r.Properties["Threshold"], 64)
+	if period < 10 || (period > 30 && period%60 != 0) {
+		return alarmInput{}, fmt.Errorf("property Period must be 10, 20, 30 or a multiple of 60")
+	}
+	if evaluationPeriods < 1 {
+		return alarmInput{}, fmt.Errorf("property EvaluationPeriods must be positive")
+	}
+
+	params := &cloudwatch.PutMetricAlarmInput{
+		AlarmName:          aws.String(r.Properties["AlarmName"]),
+		Namespace:          aws.String(r.Properties["Namespace"]),
+		MetricName:         aws.String(r.Properties["MetricName"]),
+		Statistic:          cwtypes.Statistic(r.Properties["Statistic"]),
+		Period:             aws.Int32(int32(period)),
+		EvaluationPeriods:  aws.Int32(int32(evaluationPeriods)),
+		Threshold:          aws.Float64(threshold),
+		ComparisonOperator: cwtypes.ComparisonOperator(r.Properties["ComparisonOperator"]),
+		AlarmActions:       splitPatterns(r.Properties["AlarmActions"]),
+	}
+	if treatMissingData := r.Properties["TreatMissingData"]; treatMissingData != "" {
+		params.TreatMissingData = aws.String(treatMissingData)
+	}
+
+	dimensions, err := parseKeyValues(r.Properties["Dimensions"])
+	if err != nil {
+		return alarmInput{}, fmt.Errorf("invalid property Dimensions: %v", err)
+	}
+	for _, k := range sortedKeys(dimensions) {
+		params.Dimensions = append(params.Dimensions, cwtypes.Dimension{Name: aws.String(k), Value: aws.String(dimensions[k])})
+	}
+
+	return alarmInput{
+		region: r.Properties["Region"],
+		params: params,
+	}, nil
+}
+
+// createAlarm creates cloudwatch metric alarm resources
+func (rm *ResourceManager) createAlarm(ctx context.Context, input alarmInput) (*ResourceMetadata, error) {
+	client, err := rm.clients().CloudWatch(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloudwatch client: %v", err)
+	}
+	if _, err := client.PutMetricAlarm(ctx, input.params); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(input.params.AlarmName)
+	out, err := client.DescribeAlarms(ctx, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: []string{name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe alarm %s: %v", name, err)
+	}
+	metadata := &ResourceMetadata{
+		ID:   name,
+		Type: Alarm,
+		Name: name,
+	}
+	if len(out.MetricAlarms) > 0 {
+		metadata.ARN = aws.ToString(out.MetricAlarms[0].AlarmArn)
+	}
+	return metadata, nil
+}
+
+// properties renders the configuration of the alarm which can be updated
+func (input alarmInput) properties() map[string]string {
+	params := input.params
+	dimensions := make(map[string]string, len(params.Dimensions))
+	for _, d := range params.Dimensions {
+		dimensions[aws.ToString(d.Name)] = aws.ToString(d.Value)
+	}
+	return map[string]string{
+		"Namespace":          aws.ToString(params.Namespace),
+		"MetricName":         aws.ToString(params.MetricName),
+		"Statistic":          string(params.Statistic),
+		"Period":             strconv.Itoa(int(aws.ToInt32(params.Period))),
+		"EvaluationPeriods":  strconv.Itoa(int(aws.ToInt32(params.EvaluationPeriods))),
+		"Threshold":          strconv.FormatFloat(aws.ToFloat64(params.Threshold), 'f', -1, 64),
+		"ComparisonOperator": string(params.ComparisonOperator),
+		"TreatMissingData":   aws.ToString(params.TreatMissingData),
+		"AlarmActions":       strings.Join(params.AlarmActions, ","),
+		"Dimensions":         formatKeyValues(dimensions),
+	}
+}
+
+// describeAlarm reads the configuration of an existing alarm, it returns nil
+// when the alarm does not exist.
+func (rm *ResourceManager) describeAlarm(ctx context.Context, input alarmInput) (*observedResource, error) {
+	client, err := rm.clients().CloudWatch(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloudwatch client: %v", err)
+	}
+	name := aws.ToString(input.params.AlarmName)
+	out, err := client.DescribeAlarms(ctx, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: []string{name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe alarm %s: %v", name, err)
+	}
+	if len(out.MetricAlarms) == 0 {
+		return nil, nil
+	}
+
+	alarm := out.MetricAlarms[0]
+	current := alarmInput{params: &cloudwatch.PutMetricAlarmInput{
+		AlarmName:          alarm.AlarmName,
+		Namespace:          alarm.Namespace,
+		MetricName:         alarm.MetricName,
+		Statistic:          alarm.Statistic,
+		Period:             alarm.Period,
+		EvaluationPeriods:  alarm.EvaluationPeriods,
+		Threshold:          alarm.Threshold,
+		ComparisonOperator: alarm.ComparisonOperator,
+		TreatMissingData:   alarm.TreatMissingData,
+		AlarmActions:       alarm.AlarmActions,
+		Dimensions:         alarm.Dimensions,
+	}}
+	return &observedResource{
+		metadata: &ResourceMetadata{
+			ID:   name,
+			Type: Alarm,
+			Name: name,
+			ARN:  aws.ToString(alarm.AlarmArn),
+		},
+		drift: diffProperties(current.properties(), input.properties()),
+	}, nil
+}
+
+// deleteAlarm deletes an existing alarm
+func (rm *ResourceManager) deleteAlarm(ctx context.Context, input alarmInput) error {
+	client, err := rm.clients().CloudWatch(ctx, input.region)
+	if err != nil {
+		return fmt.Errorf("failed to create cloudwatch client: %v", err)
+	}
+	name := aws.ToString(input.params.AlarmName)
+	if _, err := client.DeleteAlarms(ctx, &cloudwatch.DeleteAlarmsInput{AlarmNames: []string{name}}); err != nil {
+		return fmt.Errorf("failed to delete alarm %s: %v", name, err)
+	}
+	return nil
+}
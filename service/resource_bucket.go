@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type bucketInput struct {
+	bucket         string
+	region         string
+	policy         string
+	lifecycleRules []bucketLifecycleRule
+	versioning     string
+	encryption     string
+	kmsKeyID       string
+	tags           map[string]string
+}
+
+// bucketLifecycleRule is an entry of the LifecycleRules property, e.g.
+// [{"id": "expire-logs", "prefix": "logs/", "expirationDays": 30}]
+type bucketLifecycleRule struct {
+	ID     string `json:"id"`
+	Prefix string `json:"prefix"`
+	// Disabled keeps the rule without applying it
+	Disabled                           bool   `json:"disabled"`
+	ExpirationDays                     int32  `json:"expirationDays"`
+	TransitionDays                     int32  `json:"transitionDays"`
+	TransitionStorageClass             string `json:"transitionStorageClass"`
+	NoncurrentVersionExpirationDays    int32  `json:"noncurrentVersionExpirationDays"`
+	AbortIncompleteMultipartUploadDays int32  `json:"abortIncompleteMultipartUploadDays"`
+}
+
+func newBucketInput(r Resource) (bucketInput, error) {
+	input := bucketInput{
+		bucket:     r.Properties["BucketName"],
+		region:     r.Properties["Region"],
+		policy:     r.Properties["Policy"],
+		versioning: r.Properties["Versioning"],
+		encryption: r.Properties["Encryption"],
+		kmsKeyID:   r.Properties["KMSKeyId"],
+	}
+
+	var err error
+	if input.tags, err = parseKeyValues(r.Properties["Tags"]); err != nil {
+		return bucketInput{}, fmt.Errorf("invalid property Tags: %v", err)
+	}
+	if input.kmsKeyID != "" && input.encryption != string(types.ServerSideEncryptionAwsKms) {
+		return bucketInput{}, fmt.Errorf("property KMSKeyId requires Encryption aws:kms")
+	}
+
+	if rules := r.Properties["LifecycleRules"]; rules != "" {
+		if err := json.Unmarshal([]byte(rules), &input.lifecycleRules); err != nil {
+			return bucketInput{}, fmt.Errorf("invalid property LifecycleRules: %v", err)
+		}
+		for _, rule := range input.lifecycleRules {
+			if err := rule.validate(); err != nil {
+				return bucketInput{}, fmt.Errorf("invalid lifecycle rule %q: %v", rule.ID, err)
+			}
+		}
+	}
+	return input, nil
+}
+
+func (r bucketLifecycleRule) validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	if r.ExpirationDays == 0 && r.TransitionDays == 0 && r.NoncurrentVersionExpirationDays == 0 && r.AbortIncompleteMultipartUploadDays == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	if r.ExpirationDays < 0 || r.TransitionDays < 0 || r.NoncurrentVersionExpirationDays < 0 || r.AbortIncompleteMultipartUploadDays < 0 {
+		return fmt.Errorf("days must not be negative")
+	}
+	if (r.TransitionDays > 0) != (r.TransitionStorageClass != "") {
+		return fmt.Errorf("transitionDays and transitionStorageClass must be set together")
+	}
+	if r.TransitionStorageClass != "" {
+		for _, sc := range types.TransitionStorageClass("").Values() {
+			if string(sc) == r.TransitionStorageClass {
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported transition storage class %q", r.TransitionStorageClass)
+	}
+	return nil
+}
+
+func (r bucketLifecycleRule) toS3() types.LifecycleRule {
+	rule := types.LifecycleRule{
+		ID:     aws.String(r.ID),
+		Filter: &types.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)},
+		Status: types.ExpirationStatusEnabled,
+	}
+	if r.Disabled {
+		rule.Status = types.ExpirationStatusDisabled
+	}
+	if r.ExpirationDays > 0 {
+		rule.Expiration = &types.LifecycleExpiration{Days: aws.Int32(r.ExpirationDays)}
+	}
+	if r.TransitionDays > 0 {
+		rule.Transitions = []types.Transition{{
+			Days:         aws.Int32(r.TransitionDays),
+			StorageClass: types.TransitionStorageClass(r.TransitionStorageClass),
+		}}
+	}
+	if r.NoncurrentVersionExpirationDays > 0 {
+		rule.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{
+			NoncurrentDays: aws.Int32(r.NoncurrentVersionExpirationDays),
+		}
+	}
+	if r.AbortIncompleteMultipartUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(r.AbortIncompleteMultipartUploadDays),
+		}
+	}
+	return rule
+}
+
+func lifecycleRuleFromS3(rule types.LifecycleRule) bucketLifecycleRule {
+	r := bucketLifecycleRule{
+		ID:       aws.ToString(rule.ID),
+		Disabled: rule.Status == types.ExpirationStatusDisabled,
+	}
+	if rule.Filter != nil {
+		r.Prefix = aws.ToString(rule.Filter.Prefix)
+	}
+	if rule.Expiration != nil {
+		r.ExpirationDays = aws.ToInt32(rule.Expiration.Days)
+	}
+	if len(rule.Transitions) > 0 {
+		r.TransitionDays = aws.ToInt32(rule.Transitions[0].Days)
+		r.TransitionStorageClass = string(rule.Transitions[0].StorageClass)
+	}
+	if rule.NoncurrentVersionExpiration != nil {
+		r.NoncurrentVersionExpirationDays = aws.ToInt32(rule.NoncurrentVersionExpiration.NoncurrentDays)
+	}
+	if rule.AbortIncompleteMultipartUpload != nil {
+		r.AbortIncompleteMultipartUploadDays = aws.ToInt32(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+	}
+	return r
+}
+
+// properties renders the configuration of the bucket which can be updated
+func (input bucketInput) properties() map[string]string {
+	properties := map[string]string{
+		"Versioning": input.versioning,
+		"Encryption": input.encryption,
+		"KMSKeyId":   input.kmsKeyID,
+		"Tags":       formatKeyValues(input.tags),
+		"Policy":     normalizeJSON(input.policy),
+	}
+	if len(input.lifecycleRules) > 0 {
+		rules, _ := json.Marshal(input.lifecycleRules)
+		properties["LifecycleRules"] = string(rules)
+	}
+	return properties
+}
+
+// describeBucket reads the configuration of an existing bucket, it returns nil
+// when the bucket does not exist.
+func (rm *ResourceManager) describeBucket(ctx context.Context, input bucketInput) (*observedResource, error) {
+	client, err := rm.clients().S3(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %v", err)
+	}
+	head, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(input.bucket)})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) || apiErrorCode(err) == "NoSuchBucket" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to describe bucket %s: %v", input.bucket, err)
+	}
+	if region := aws.ToString(head.BucketRegion); input.region != "" && region != "" && region != input.region {
+		return nil, fmt.Errorf("bucket %s exists in region %s", input.bucket, region)
+	}
+
+	current := bucketInput{bucket: input.bucket}
+	bucket := aws.String(input.bucket)
+	versioning, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: bucket})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get versioning of bucket %s: %v", input.bucket, err)
+	}
+	current.versioning = string(versioning.Status)
+
+	encryption, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket})
+	if err != nil && apiErrorCode(err) != "ServerSideEncryptionConfigurationNotFoundError" {
+		return nil, fmt.Errorf("failed to get encryption of bucket %s: %v", input.bucket, err)
+	}
+	if err == nil && encryption.ServerSideEncryptionConfiguration != nil && len(encryption.ServerSideEncryptionConfiguration.Rules) > 0 {
+		if byDefault := encryption.ServerSideEncryptionConfiguration.Rules[0].ApplyServerSideEncryptionByDefault; byDefault != nil {
+			current.encryption = string(byDefault.SSEAlgorithm)
+			current.kmsKeyID = aws.ToString(byDefault.KMSMasterKeyID)
+		}
+	}
+
+	tagging, err := client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: bucket})
+	if err != nil && apiErrorCode(err) != "NoSuchTagSet" {
+		return nil, fmt.Errorf("failed to get tags of bucket %s: %v", input.bucket, err)
+	}
+	if err == nil {
+		current.tags = make(map[string]string, len(tagging.TagSet))
+		for _, tag := range tagging.TagSet {
+			current.tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	policy, err := client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: bucket})
+	if err != nil && apiErrorCode(err) != "NoSuchBucketPolicy" {
+		return nil, fmt.Errorf("failed to get policy of bucket %s: %v", input.bucket, err)
+	}
+	if err == nil {
+		current.policy = aws.ToString(policy.Policy)
+	}
+
+	lifecycle, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	if err != nil && apiErrorCode(err) != "NoSuchLifecycleConfiguration" {
+		return nil, fmt.Errorf("failed to get lifecycle rules of bucket %s: %v", input.bucket, err)
+	}
+	if err == nil {
+		for _, rule := range lifecycle.Rules {
+			current.lifecycleRules = append(current.lifecycleRules, lifecycleRuleFromS3(rule))
+		}
+	}
+
+	return &observedResource{
+		metadata: bucketMetadata(input.bucket),
+		drift:    diffProperties(current.properties(), input.properties()),
+	}, nil
+}
+
+// createBucket creates s3 bucket resources
+func (rm *ResourceManager) createBucket(ctx context.Context, input bucketInput) (*ResourceMetadata, error) {
+	client, err := rm.clients().S3(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %v", err)
+	}
+	createInput := &s3.CreateBucketInput{
+		Bucket: aws.String(input.bucket),
+	}
+	// us-east-1 is the default location and must not be set as a constraint
+	if input.region != "" && input.region != "us-east-1" {
+		createInput.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(input.region),
+		}
+	}
+	_, err = client.CreateBucket(ctx, createInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := configureBucket(ctx, client, input); err != nil {
+		return nil, err
+	}
+	return bucketMetadata(input.bucket), nil
+}
+
+func bucketMetadata(bucket string) *ResourceMetadata {
+	return &ResourceMetadata{
+		ID:   bucket,
+		Type: Bucket,
+		Name: bucket,
+		ARN:  "arn:aws:s3:::" + bucket,
+	}
+}
+
+// configureBucket applies the bucket sub-properties
+func configureBucket(ctx context.Context, client S3API, input bucketInput) error {
+	if input.versioning != "" {
+		_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+			Bucket: aws.String(input.bucket),
+			VersioningConfiguration: &types.VersioningConfiguration{
+				Status: types.BucketVersioningStatus(input.versioning),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set versioning of bucket %s: %v", input.bucket, err)
+		}
+	}
+
+	if input.encryption != "" {
+		rule := types.ServerSideEncryptionRule{
+			ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+				SSEAlgorithm: types.ServerSideEncryption(input.encryption),
+			},
+		}
+		if input.kmsKeyID != "" {
+			rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID = aws.String(input.kmsKeyID)
+		}
+		_, err := client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+			Bucket: aws.String(input.bucket),
+			ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+				Rules: []types.ServerSideEncryptionRule{rule},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set encryption of bucket %s: %v", input.bucket, err)
+		}
+	}
+
+	if len(input.lifecycleRules) > 0 {
+		rules := make([]types.LifecycleRule, 0, len(input.lifecycleRules))
+		for _, rule := range input.lifecycleRules {
+			rules = append(rules, rule.toS3())
+		}
+		_, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 aws.String(input.bucket),
+			LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set lifecycle rules of bucket %s: %v", input.bucket, err)
+		}
+	}
+
+	if input.policy != "" {
+		_, err := client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+			Bucket: aws.String(input.bucket),
+			Policy: aws.String(input.policy),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set policy of bucket %s: %v", input.bucket, err)
+		}
+	}
+
+	if len(input.tags) > 0 {
+		_, err := client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+			Bucket:  aws.String(input.bucket),
+			Tagging: &types.Tagging{TagSet: s3Tags(input.tags)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set tags of bucket %s: %v", input.bucket, err)
+		}
+	}
+	return nil
+}
+
+func s3Tags(tags map[string]string) []types.Tag {
+	tagSet := make([]types.Tag, 0, len(tags))
+	for _, k := range sortedKeys(tags) {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return tagSet
+}
+
+// deleteBucket deletes a bucket, with emptyFirst all its objects and object
+// versions are deleted first, as S3 only deletes empty buckets.
+func (rm *ResourceManager) deleteBucket(ctx context.Context, input bucketInput, emptyFirst bool) error {
+	client, err := rm.clients().S3(ctx, input.region)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client: %v", err)
+	}
+	if emptyFirst {
+		if err := emptyBucket(ctx, client, input.bucket); err != nil {
+			return err
+		}
+	}
+	if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(input.bucket)}); err != nil {
+		if apiErrorCode(err) == "BucketNotEmpty" {
+			return fmt.Errorf("bucket %s is not empty, it can be emptied first", input.bucket)
+		}
+		return fmt.Errorf("failed to delete bucket %s: %v", input.bucket, err)
+	}
+	return nil
+}
+
+// emptyBucket deletes all the object versions and delete markers of a bucket,
+// which also covers the objects of unversioned buckets.
+func emptyBucket(ctx context.Context, client S3API, bucket string) error {
+	paginator := s3.NewListObjectVersionsPaginator(client, &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("error when listing object versions of bucket %s: %v", bucket, err)
+		}
+		identifiers := make([]types.ObjectIdentifier, 0, len(page.Versions)+len(page.DeleteMarkers))
+		for _, version := range page.Versions {
+			identifiers = append(identifiers, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range page.DeleteMarkers {
+			identifiers = append(identifiers, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+		if err := deleteObjectIdentifiers(ctx, client, bucket, identifiers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
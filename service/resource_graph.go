@@ -0,0 +1,160 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// OnFailureStop leaves the resources provisioned before a failure
+	OnFailureStop = "stop"
+	// OnFailureRollback deletes the resources created before a failure
+	OnFailureRollback = "rollback"
+
+	defaultProvisioningConcurrency = 4
+
+	// knownAfterApply replaces the references to resources which a dry run
+	// plans to create
+	knownAfterApply = "(known after apply)"
+)
+
+// resourceDependencies returns the IDs of the resources a resource depends on,
+// listed in DependsOn or referenced by its properties.
+func resourceDependencies(r Resource) []string {
+	seen := make(map[string]bool)
+	dependencies := make([]string, 0)
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			dependencies = append(dependencies, id)
+		}
+	}
+	for _, id := range r.DependsOn {
+		add(id)
+	}
+	for _, name := range sortedKeys(r.Properties) {
+		for _, m := range resourceReference.FindAllStringSubmatch(r.Properties[name], -1) {
+			add(m[1])
+		}
+	}
+	return dependencies
+}
+
+// provisioningLevels groups the indexes of the resources so that the resources
+// of a level only depend on resources of the previous levels. The resources of
+// a level can be provisioned in parallel, in the order of their declaration.
+func provisioningLevels(resources []Resource) ([][]int, error) {
+	index := make(map[string]int, len(resources))
+	for i, r := range resources {
+		index[r.ID] = i
+	}
+
+	remaining := make([]int, len(resources))
+	dependents := make([][]int, len(resources))
+	for i, r := range resources {
+		for _, id := range resourceDependencies(r) {
+			j, ok := index[id]
+			if !ok {
+				return nil, fmt.Errorf("resource %s depends on unknown resource %s", r.ID, id)
+			}
+			if j == i {
+				return nil, fmt.Errorf("resource %s depends on itself", r.ID)
+			}
+			remaining[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	levels := make([][]int, 0)
+	level := make([]int, 0)
+	for i := range resources {
+		if remaining[i] == 0 {
+			level = append(level, i)
+		}
+	}
+	placed := 0
+	for len(level) > 0 {
+		levels = append(levels, level)
+		placed += len(level)
+		next := make([]int, 0)
+		for _, i := range level {
+			for _, j := range dependents[i] {
+				if remaining[j]--; remaining[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		sort.Ints(next)
+		level = next
+	}
+
+	if placed < len(resources) {
+		cycle := make([]string, 0)
+		for i, r := range resources {
+			if remaining[i] > 0 {
+				cycle = append(cycle, r.ID)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle between resources %s", strings.Join(cycle, ", "))
+	}
+	return levels, nil
+}
+
+// resolveResource returns a copy of the resource whose property references
+// are replaced by the outputs of the referenced resources.
+func resolveResource(r Resource, lookup func(resourceID string, output string) (string, error)) (Resource, error) {
+	properties := make(map[string]string, len(r.Properties))
+	for name, value := range r.Properties {
+		resolved, err := resolveReferences(value, lookup)
+		if err != nil {
+			return Resource{}, fmt.Errorf("error when resolving property %s of resource %s: %v", name, r.ID, err)
+		}
+		properties[name] = resolved
+	}
+	r.Properties = properties
+	return r, nil
+}
+
+// hasReferences tells whether a property value references another resource,
+// such values are only validated once resolved.
+func hasReferences(value string) bool {
+	return resourceReference.MatchString(value)
+}
+
+// projectLookup resolves references by resource ID, output returns the output
+// of the resource at the given index.
+func projectLookup(resources []Resource, output func(i int, name string) (string, error)) func(resourceID string, name string) (string, error) {
+	index := make(map[string]int, len(resources))
+	for i, r := range resources {
+		index[r.ID] = i
+	}
+	return func(resourceID string, name string) (string, error) {
+		i, ok := index[resourceID]
+		if !ok {
+			return "", fmt.Errorf("unknown resource %s", resourceID)
+		}
+		return output(i, name)
+	}
+}
+
+// forEachResource calls fn for the given resource indexes using at most
+// concurrency goroutines. Unlike forEachConcurrently, a failure does not cancel
+// the other calls, so that no resource is left half provisioned.
+func forEachResource(concurrency int, indexes []int, fn func(i int) error) []error {
+	errs := make([]error, len(indexes))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for k, i := range indexes {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(k int, i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[k] = fn(i)
+		}(k, i)
+	}
+	wg.Wait()
+	return errs
+}
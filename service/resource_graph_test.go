@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProvisioningLevels(t *testing.T) {
+	resources := []Resource{
+		{ID: "alarm", Properties: map[string]string{"Dimensions": "QueueName=${resources.queue.name}"}},
+		{ID: "queue", DependsOn: []string{"topic"}},
+		{ID: "topic"},
+		{ID: "bucket"},
+	}
+	levels, err := provisioningLevels(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int{{2, 3}, {1}, {0}}; !reflect.DeepEqual(levels, want) {
+		t.Fatalf("Expected levels %v, got %v", want, levels)
+	}
+
+	resources[2].DependsOn = []string{"alarm"}
+	if _, err := provisioningLevels(resources); err == nil || !strings.Contains(err.Error(), "dependency cycle between resources alarm, queue, topic") {
+		t.Fatalf("Expected a dependency cycle, got %v", err)
+	}
+
+	resources[2].DependsOn = []string{"database"}
+	if _, err := provisioningLevels(resources); err == nil || !strings.Contains(err.Error(), "unknown resource database") {
+		t.Fatalf("Expected an unknown dependency, got %v", err)
+	}
+}
+
+func TestCreateProjectResourcesReferences(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{ID: "backupBucket", Type: "S3:Bucket", Properties: map[string]string{
+				"BucketName": "backup",
+				"Policy":     `{"Statement": [{"Effect": "Deny", "Resource": "${resources.logBucket.arn}/*"}]}`,
+			}},
+			{ID: "logBucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "logs"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Plan) != 2 || out.Plan[0].ResourceID != "logBucket" || out.Plan[1].ResourceID != "backupBucket" {
+		t.Fatalf("Expected logBucket to be planned first, got %+v", out.Plan)
+	}
+
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	if policy := clients.s3.buckets["backup"].policy; !strings.Contains(policy, `"arn:aws:s3:::logs/*"`) {
+		t.Fatalf("Expected policy to reference the log bucket, got %s", policy)
+	}
+}
+
+func TestCreateProjectResourcesRollback(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{ID: "logBucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "logs"}},
+			{ID: "backupBucket", Type: "S3:Bucket", DependsOn: []string{"logBucket"}, Properties: map[string]string{"BucketName": "backup", "Region": "us-west-2"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second bucket exists in another region, so its provisioning fails
+	_, err = clients.FakeS3().CreateBucket(context.Background(), &s3.CreateBucketInput{
+		Bucket:                    aws.String("backup"),
+		CreateBucketConfiguration: &types.CreateBucketConfiguration{LocationConstraint: types.BucketLocationConstraintEuWest1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001", OnFailure: OnFailureRollback})
+	if err == nil || !strings.Contains(err.Error(), "backupBucket") {
+		t.Fatalf("Expected provisioning of backupBucket to fail, got %v", err)
+	}
+	if _, ok := clients.s3.buckets["logs"]; ok {
+		t.Fatalf("Expected bucket logs to be rolled back")
+	}
+	projects, _ := rm.ListProjects(context.Background())
+	if states := projects[0].States; states[0].Status != ResourceStatusDeleted || states[1].Status != ResourceStatusFailed {
+		t.Fatalf("Expected states deleted and failed, got %+v", states)
+	}
+}
@@ -2,57 +2,478 @@ package service
 
 import (
 	"context"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/aws/aws-sdk-go/aws"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
+// ErrProjectExists is returned when creating a project whose ID is taken
+var ErrProjectExists = errors.New("project already exists")
+
 type ResourceManager struct {
+	// mu guards projects and provisioning. The states of a project are
+	// replaced rather than changed in place, so that the copies returned to the
+	// callers do not change under them.
+	mu       sync.Mutex
 	projects map[string]Project
+	// provisioning holds the projects whose resources are being created or
+	// destroyed, the channel is closed when done
+	provisioning map[string]chan struct{}
+	// Clients builds the AWS clients, the AWS SDK defaults are used when nil
+	Clients ClientProvider
 }
 
-// CreateProject stores resources metadata into database
+// CreateProject stores resources metadata into database. An existing project
+// is not replaced, ErrProjectExists is returned, UpdateProject changes it.
 func (rm *ResourceManager) CreateProject(ctx context.Context, input *CreateProjectInput) error {
+	return rm.storeProject(input)
+}
+
+// storeProject adds a project, the resources start pending
+func (rm *ResourceManager) storeProject(input *CreateProjectInput) error {
+	project, err := newProject(input)
+	if err != nil {
+		return err
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 	if rm.projects == nil {
 		rm.projects = make(map[string]Project)
 	}
+	if _, ok := rm.projects[input.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrProjectExists, input.ID)
+	}
+
+	rm.projects[input.ID] = project
+
+	return nil
+}
+
+// UpdateProject replaces the definition of a project. The states of the
+// resources which keep their ID and type are kept, the new resources are
+// pending. A provisioned resource can only be removed from the definition when
+// it is retained, otherwise it has to be destroyed first.
+func (rm *ResourceManager) UpdateProject(ctx context.Context, input *CreateProjectInput) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	current, ok := rm.projects[input.ID]
+	if !ok {
+		return fmt.Errorf("project %s not found", input.ID)
+	}
+	project, err := newProject(input)
+	if err != nil {
+		return err
+	}
+
+	states := make(map[string]ResourceState, len(current.States))
+	for _, state := range current.States {
+		states[state.ResourceID] = state
+	}
+	for i, r := range project.Resources {
+		if state, ok := states[r.ID]; ok && state.Type == r.Type {
+			project.States[i] = state
+		}
+	}
+	if err := checkRemovedResources(current, project.Resources); err != nil {
+		return err
+	}
+	project.Status = current.Status
+
+	rm.projects[input.ID] = project
+	return nil
+}
+
+// DeleteProject removes a project, which must not have provisioned resources
+// other than retained ones
+func (rm *ResourceManager) DeleteProject(ctx context.Context, projectID string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	project, ok := rm.projects[projectID]
+	if !ok {
+		return fmt.Errorf("project %s not found", projectID)
+	}
+	if err := checkRemovedResources(project, nil); err != nil {
+		return err
+	}
+	delete(rm.projects, projectID)
+	return nil
+}
 
-	rm.projects[input.ID] = Project{
+// checkRemovedResources fails when a provisioned resource of a project is not
+// part of resources anymore, unless it is retained. Resources which change
+// their type are removed as well.
+func checkRemovedResources(project Project, resources []Resource) error {
+	types := make(map[string]string, len(resources))
+	for _, r := range resources {
+		types[r.ID] = r.Type
+	}
+	for i, r := range project.Resources {
+		if types[r.ID] == r.Type || r.DeletionPolicy == DeletionPolicyRetain {
+			continue
+		}
+		if project.States[i].Status == ResourceStatusProvisioned {
+			return fmt.Errorf("resource %s of project %s is provisioned, destroy it before removing it", r.ID, project.ID)
+		}
+	}
+	return nil
+}
+
+// newProject validates a project definition, the resources start pending
+func newProject(input *CreateProjectInput) (Project, error) {
+	ids := make(map[string]bool, len(input.Resources))
+	states := make([]ResourceState, 0, len(input.Resources))
+	for i := range input.Resources {
+		r := &input.Resources[i]
+		if err := validateResource(*r); err != nil {
+			return Project{}, fmt.Errorf("invalid resource %d: %v", i, err)
+		}
+		// resources are referenced by ID, which defaults to the resource name
+		if r.ID == "" {
+			r.ID = resourceName(*r)
+		}
+		if ids[r.ID] {
+			return Project{}, fmt.Errorf("duplicate resource id %q", r.ID)
+		}
+		ids[r.ID] = true
+		states = append(states, ResourceState{
+			ResourceID: r.ID,
+			Type:       r.Type,
+			Status:     ResourceStatusPending,
+			UpdatedAt:  time.Now().UTC(),
+		})
+	}
+
+	if _, err := provisioningLevels(input.Resources); err != nil {
+		return Project{}, err
+	}
+
+	return Project{
 		Name:      input.Name,
 		ID:        input.ID,
 		Status:    StatusActive,
 		Resources: input.Resources,
+		States:    states,
+	}, nil
+}
+
+// CreateProjectResources is called to initialize resources when workflow is triggerred.
+// It is idempotent: missing resources are created, resources whose configuration
+// drifted from the definition are updated and the others are left untouched.
+// With DryRun the plan is returned without applying it. The state of every
+// applied resource is recorded on the project.
+//
+// Resources are provisioned after the resources they depend on, independent
+// resources in parallel. When a resource fails, the resources being provisioned
+// are completed and no other resource is started. With OnFailureRollback the
+// resources created by the call are then deleted.
+//
+// The calls on the same project, and DestroyProjectResources, run one at a
+// time, the later ones wait for the earlier ones to end.
+func (rm *ResourceManager) CreateProjectResources(ctx context.Context, input *CreateProjectResourcesInput) (CreateProjectResourcesOutput, error) {
+	out := CreateProjectResourcesOutput{
+		ProjectID: input.ProjectID,
+		DryRun:    input.DryRun,
+		Plan:      make([]ResourceChange, 0),
+	}
+	release, err := rm.lockProvisioning(ctx, input.ProjectID)
+	if err != nil {
+		return out, err
+	}
+	defer release()
+	project, ok := rm.project(input.ProjectID)
+	if !ok {
+		return out, fmt.Errorf("project %s not found", input.ProjectID)
+	}
+	switch input.OnFailure {
+	case "", OnFailureStop, OnFailureRollback:
+	default:
+		return out, fmt.Errorf("unsupported failure handling %q", input.OnFailure)
+	}
+	levels, err := provisioningLevels(project.Resources)
+	if err != nil {
+		return out, err
+	}
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultProvisioningConcurrency
 	}
 
-	return nil
-}
+	// states and changes of the resources handled by this call, the references
+	// of a resource are resolved from the states of its dependencies
+	n := len(project.Resources)
+	states := make([]ResourceState, n)
+	changes := make([]*ResourceChange, n)
+	resolved := make([]Resource, n)
+	lookup := projectLookup(project.Resources, func(i int, output string) (string, error) {
+		if input.DryRun && changes[i] != nil && changes[i].Action == PlanActionCreate {
+			return knownAfterApply, nil
+		}
+		return states[i].output(output)
+	})
 
-// CreateProjectResources is called to initialize resources when workflow is triggerred
-func (rm *ResourceManager) CreateProjectResources(ctx context.Context, input *CreateProjectResourcesInput) error {
-	resources := rm.projects[input.ProjectID].Resources
-	for _, r := range resources {
-		switch r.Type {
-		case "S3:Bucket":
-			_, err := rm.createBucket(ctx, bucketInput{
-				bucket: r.Properties["BucketName"],
-				region: r.Properties["Region"],
-			})
+	handled := make([]int, 0, n)
+	var failures []error
+	for _, level := range levels {
+		errs := forEachResource(concurrency, level, func(i int) error {
+			r, err := resolveResource(project.Resources[i], lookup)
+			if err == nil {
+				err = validateResource(r)
+			}
+			var change ResourceChange
+			var observed *observedResource
+			if err == nil {
+				change, observed, err = rm.planResource(ctx, r)
+			}
+			if err == nil {
+				changes[i] = &change
+			}
+			resolved[i] = r
+
+			if input.DryRun {
+				if observed != nil {
+					states[i] = newResourceState(r, observed.metadata, nil)
+				}
+				if err != nil {
+					return fmt.Errorf("error when planning resource %s: %v", project.Resources[i].ID, err)
+				}
+				return nil
+			}
+			var metadata *ResourceMetadata
+			if err == nil {
+				metadata, err = rm.applyChange(ctx, r, change, observed)
+			}
+			states[i] = newResourceState(project.Resources[i], metadata, err)
+			if err != nil && ctx.Err() != nil {
+				states[i].Status = ResourceStatusInterrupted
+			}
+			if err != nil {
+				return fmt.Errorf("error when provisioning resource %s: %v", project.Resources[i].ID, err)
+			}
+			return nil
+		})
+		handled = append(handled, level...)
+		for _, err := range errs {
 			if err != nil {
-				return err
+				failures = append(failures, err)
 			}
-		default:
+		}
+		if len(failures) > 0 {
+			break
 		}
 	}
-	return nil
+
+	for _, i := range handled {
+		if changes[i] != nil {
+			out.Plan = append(out.Plan, *changes[i])
+		}
+	}
+	if input.DryRun {
+		return out, errors.Join(failures...)
+	}
+
+	// an interrupted provisioning is not rolled back, the states of the
+	// resources handled so far are kept so that it can be resumed
+	if len(failures) > 0 && input.OnFailure == OnFailureRollback && ctx.Err() == nil {
+		for k := len(handled) - 1; k >= 0; k-- {
+			i := handled[k]
+			r := project.Resources[i]
+			if changes[i] == nil || changes[i].Action != PlanActionCreate || states[i].Status != ResourceStatusProvisioned || r.DeletionPolicy == DeletionPolicyRetain {
+				continue
+			}
+			if err := rm.deleteResource(ctx, resolved[i], false); err != nil {
+				failures = append(failures, fmt.Errorf("error when rolling back resource %s: %v", r.ID, err))
+				continue
+			}
+			states[i].Status = ResourceStatusDeleted
+			states[i].UpdatedAt = time.Now().UTC()
+		}
+	}
+
+	handledStates := make([]ResourceState, 0, len(handled))
+	for _, i := range handled {
+		handledStates = append(handledStates, states[i])
+	}
+	rm.updateStates(input.ProjectID, handledStates...)
+	return out, errors.Join(failures...)
+}
+
+// DestroyProjectResources deletes the project resources in the reverse order
+// of their dependencies. Resources with the Retain deletion policy are kept and
+// reported as retained, resources which do not exist are skipped.
+func (rm *ResourceManager) DestroyProjectResources(ctx context.Context, input *DestroyProjectResourcesInput) (DestroyProjectResourcesOutput, error) {
+	out := DestroyProjectResourcesOutput{
+		ProjectID: input.ProjectID,
+		DryRun:    input.DryRun,
+		Plan:      make([]ResourceChange, 0),
+	}
+	release, err := rm.lockProvisioning(ctx, input.ProjectID)
+	if err != nil {
+		return out, err
+	}
+	defer release()
+	project, ok := rm.project(input.ProjectID)
+	if !ok {
+		return out, fmt.Errorf("project %s not found", input.ProjectID)
+	}
+	levels, err := provisioningLevels(project.Resources)
+	if err != nil {
+		return out, err
+	}
+	order := make([]int, 0, len(project.Resources))
+	for _, level := range levels {
+		order = append(order, level...)
+	}
+	lookup := projectLookup(project.Resources, func(i int, output string) (string, error) {
+		return project.States[i].output(output)
+	})
+
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+		r := project.Resources[i]
+		change := ResourceChange{
+			ResourceID: r.ID,
+			Type:       r.Type,
+			Action:     PlanActionRetain,
+		}
+		if r.DeletionPolicy != DeletionPolicyRetain {
+			change.Action = PlanActionNone
+			// a resource whose dependencies were never provisioned was not
+			// provisioned either
+			if r, err = resolveResource(r, lookup); err == nil {
+				_, observed, err := rm.planResource(ctx, r)
+				if err != nil {
+					return out, fmt.Errorf("error when planning resource %s: %v", r.ID, err)
+				}
+				if observed != nil {
+					change.Action = PlanActionDelete
+				}
+			}
+		}
+		out.Plan = append(out.Plan, change)
+		if input.DryRun || change.Action != PlanActionDelete {
+			continue
+		}
+
+		err := rm.deleteResource(ctx, r, input.EmptyFirst)
+		state := ResourceState{
+			ResourceID: r.ID,
+			Type:       r.Type,
+			Status:     ResourceStatusDeleted,
+			UpdatedAt:  time.Now().UTC(),
+		}
+		if err != nil {
+			state = project.States[i]
+			state.Error = err.Error()
+			state.UpdatedAt = time.Now().UTC()
+		}
+		project.States[i] = state
+		rm.updateStates(input.ProjectID, state)
+		if err != nil {
+			return out, fmt.Errorf("error when deleting resource %s: %v", r.ID, err)
+		}
+	}
+	return out, nil
+}
+
+type DestroyProjectResourcesInput struct {
+	ProjectID string `json:"projectId"`
+	// EmptyFirst deletes the objects and object versions of the buckets
+	// before the buckets, otherwise deleting a non empty bucket fails
+	EmptyFirst bool `json:"emptyFirst"`
+	// DryRun returns the plan without deleting resources
+	DryRun bool `json:"dryRun"`
+}
+
+type DestroyProjectResourcesOutput struct {
+	ProjectID string           `json:"projectId"`
+	DryRun    bool             `json:"dryRun"`
+	Plan      []ResourceChange `json:"plan"`
+}
+
+func (rm *ResourceManager) deleteResource(ctx context.Context, r Resource, emptyFirst bool) error {
+	switch r.Type {
+	case "S3:Bucket":
+		input, err := newBucketInput(r)
+		if err != nil {
+			return err
+		}
+		return rm.deleteBucket(ctx, input, emptyFirst)
+	case "SQS:Queue":
+		input, err := newQueueInput(r)
+		if err != nil {
+			return err
+		}
+		return rm.deleteQueue(ctx, input)
+	case "SNS:Topic":
+		input, err := newTopicInput(r)
+		if err != nil {
+			return err
+		}
+		return rm.deleteTopic(ctx, input)
+	case "CloudWatch:Alarm":
+		input, err := newAlarmInput(r)
+		if err != nil {
+			return err
+		}
+		return rm.deleteAlarm(ctx, input)
+	default:
+		return fmt.Errorf("unsupported resource type %q", r.Type)
+	}
+}
+
+func (rm *ResourceManager) createResource(ctx context.Context, r Resource) (*ResourceMetadata, error) {
+	switch r.Type {
+	case "S3:Bucket":
+		input, err := newBucketInput(r)
+		if err != nil {
+			return nil, err
+		}
+		return rm.createBucket(ctx, input)
+	case "SQS:Queue":
+		input, err := newQueueInput(r)
+		if err != nil {
+			return nil, err
+		}
+		return rm.createQueue(ctx, input)
+	case "SNS:Topic":
+		input, err := newTopicInput(r)
+		if err != nil {
+			return nil, err
+		}
+		return rm.createTopic(ctx, input)
+	case "CloudWatch:Alarm":
+		input, err := newAlarmInput(r)
+		if err != nil {
+			return nil, err
+		}
+		return rm.createAlarm(ctx, input)
+	default:
+		return nil, fmt.Errorf("unsupported resource type %q", r.Type)
+	}
 }
 
 type CreateProjectResourcesInput struct {
 	ProjectID string `json:"ProjectId"`
+	// DryRun returns the plan without creating or updating resources
+	DryRun bool `json:"dryRun"`
+	// Concurrency is the number of resources provisioned at the same time
+	Concurrency int `json:"concurrency"`
+	// OnFailure is OnFailureStop, the default, or OnFailureRollback
+	OnFailure string `json:"onFailure"`
+}
+
+type CreateProjectResourcesOutput struct {
+	ProjectID string           `json:"projectId"`
+	DryRun    bool             `json:"dryRun"`
+	Plan      []ResourceChange `json:"plan"`
 }
 
 // ListProjects lists all projects info
 func (rm *ResourceManager) ListProjects(ctx context.Context) ([]Project, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 	projects := make([]Project, 0)
 	for _, p := range rm.projects {
 		projects = append(projects, p)
@@ -60,33 +481,110 @@ func (rm *ResourceManager) ListProjects(ctx context.Context) ([]Project, error)
 	return projects, nil
 }
 
-// createBucket creates s3 bucket resources
-func (rm *ResourceManager) createBucket(ctx context.Context, input bucketInput) (*ResourceMetadata, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(input.region))
-	if err != nil {
-		return nil, err
-	}
-	client := s3.NewFromConfig(cfg)
-	_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{
-		Bucket: aws.String(input.bucket),
-		CreateBucketConfiguration: &types.CreateBucketConfiguration{
-			LocationConstraint: types.BucketLocationConstraint(input.region),
-		},
-	})
-	if err != nil {
-		return nil, err
+// GetProject returns a project by ID
+func (rm *ResourceManager) GetProject(ctx context.Context, projectID string) (Project, error) {
+	project, ok := rm.project(projectID)
+	if !ok {
+		return Project{}, fmt.Errorf("project %s not found", projectID)
 	}
-	return &ResourceMetadata{
-		Type: Bucket,
-		Name: input.bucket,
-	}, nil
+	return project, nil
+}
+
+// ResourceOutput returns an output (id, arn or name) of a provisioned
+// project resource
+func (rm *ResourceManager) ResourceOutput(ctx context.Context, projectID string, resourceID string, output string) (string, error) {
+	project, ok := rm.project(projectID)
+	if !ok {
+		return "", fmt.Errorf("project %s not found", projectID)
+	}
+	for _, state := range project.States {
+		if state.ResourceID == resourceID {
+			return state.output(output)
+		}
+	}
+	return "", fmt.Errorf("resource %s not found in project %s", resourceID, projectID)
+}
+
+// project returns a copy of a project whose states can be changed by the
+// caller
+func (rm *ResourceManager) project(projectID string) (Project, bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	project, ok := rm.projects[projectID]
+	if ok {
+		project.States = append([]ResourceState(nil), project.States...)
+	}
+	return project, ok
+}
+
+// lockProvisioning waits until no resource of a project is being provisioned,
+// or ctx is done, and marks the project as being provisioned until release is
+// called
+func (rm *ResourceManager) lockProvisioning(ctx context.Context, projectID string) (release func(), err error) {
+	for {
+		rm.mu.Lock()
+		busy, ok := rm.provisioning[projectID]
+		if !ok {
+			if rm.provisioning == nil {
+				rm.provisioning = make(map[string]chan struct{})
+			}
+			done := make(chan struct{})
+			rm.provisioning[projectID] = done
+			rm.mu.Unlock()
+			return func() {
+				rm.mu.Lock()
+				delete(rm.provisioning, projectID)
+				rm.mu.Unlock()
+				close(done)
+			}, nil
+		}
+		rm.mu.Unlock()
+
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error when waiting for the provisioning of project %s: %v", projectID, ctx.Err())
+		}
+	}
+}
+
+// updateStates records resource states on the current definition of a
+// project, which may have been updated while the resources were provisioned.
+// The states of the resources which were removed since, or changed their
+// type, are dropped.
+func (rm *ResourceManager) updateStates(projectID string, states ...ResourceState) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	project, ok := rm.projects[projectID]
+	if !ok {
+		return
+	}
+	updated := append([]ResourceState(nil), project.States...)
+	for _, state := range states {
+		for i := range updated {
+			if updated[i].ResourceID == state.ResourceID && updated[i].Type == state.Type {
+				updated[i] = state
+			}
+		}
+	}
+	project.States = updated
+	rm.projects[projectID] = project
+}
+
+func (rm *ResourceManager) clients() ClientProvider {
+	if rm.Clients == nil {
+		return defaultClientProvider
+	}
+	return rm.Clients
 }
 
 type Project struct {
 	Name      string     `json:"name"`
 	ID        string     `json:"id"`
-	Status    Status     `json:"status""`
+	Status    Status     `json:"status"`
 	Resources []Resource `json:"resources"`
+	// States holds the provisioning state of the resources, in the same order
+	States []ResourceState `json:"resourceStates"`
 }
 
 type CreateProjectInput struct {
@@ -99,11 +597,11 @@ type Resource struct {
 	ID         string            `json:"id"`
 	Type       string            `json:"type"`
 	Properties map[string]string `json:"properties"`
-}
-
-type bucketInput struct {
-	bucket string
-	region string
+	// DependsOn lists the IDs of the resources which are provisioned before
+	// this one, in addition to the resources referenced by its properties
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// DeletionPolicy defaults to Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 type ResourceMetadata struct {
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubS3 records the created buckets, other calls are not implemented.
+type stubS3 struct {
+	S3API
+	region  string
+	buckets []*s3.CreateBucketInput
+}
+
+func (s *stubS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	return nil, &types.NotFound{}
+}
+
+func (s *stubS3) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	s.buckets = append(s.buckets, params)
+	return &s3.CreateBucketOutput{}, nil
+}
+
+type stubClientProvider struct {
+	s3 *stubS3
+}
+
+func (p *stubClientProvider) S3(ctx context.Context, region string) (S3API, error) {
+	p.s3.region = region
+	return p.s3, nil
+}
+
+func (p *stubClientProvider) SQS(ctx context.Context, region string) (SQSAPI, error) {
+	return nil, fmt.Errorf("sqs is not stubbed")
+}
+
+func (p *stubClientProvider) SNS(ctx context.Context, region string) (SNSAPI, error) {
+	return nil, fmt.Errorf("sns is not stubbed")
+}
+
+func (p *stubClientProvider) CloudWatch(ctx context.Context, region string) (CloudWatchAPI, error) {
+	return nil, fmt.Errorf("cloudwatch is not stubbed")
+}
+
+func TestCreateProjectResourcesWithClientProvider(t *testing.T) {
+	stub := &stubS3{}
+	rm := &ResourceManager{Clients: &stubClientProvider{s3: stub}}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "resource-bucket", "Region": "us-west-2"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.buckets) != 1 || aws.ToString(stub.buckets[0].Bucket) != "resource-bucket" {
+		t.Fatalf("Expected bucket resource-bucket to be created, got %v", stub.buckets)
+	}
+	if stub.region != "us-west-2" || stub.buckets[0].CreateBucketConfiguration.LocationConstraint != "us-west-2" {
+		t.Fatalf("Expected bucket to be created in us-west-2")
+	}
+}
+
+func TestCreateProjectResourcesState(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{ID: "logBucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "log-bucket"}},
+			{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "backup-bucket", "Region": "us-west-2"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, _ := rm.ListProjects(context.Background())
+	if states := projects[0].States; len(states) != 2 || states[0].Status != ResourceStatusPending || states[1].ResourceID != "backup-bucket" {
+		t.Fatalf("Expected pending states with default ids, got %+v", states)
+	}
+
+	// the second bucket exists in another region, so its provisioning fails
+	_, err = clients.FakeS3().CreateBucket(context.Background(), &s3.CreateBucketInput{
+		Bucket:                    aws.String("backup-bucket"),
+		CreateBucketConfiguration: &types.CreateBucketConfiguration{LocationConstraint: types.BucketLocationConstraintEuWest1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err == nil {
+		t.Fatalf("Expected provisioning of backup-bucket to fail")
+	}
+
+	projects, _ = rm.ListProjects(context.Background())
+	states := projects[0].States
+	if states[0].Status != ResourceStatusProvisioned || states[0].PhysicalID != "log-bucket" || states[0].ARN != "arn:aws:s3:::log-bucket" || states[0].UpdatedAt.IsZero() {
+		t.Fatalf("Expected log-bucket to be provisioned, got %+v", states[0])
+	}
+	if states[1].Status != ResourceStatusFailed || states[1].Error == "" {
+		t.Fatalf("Expected backup-bucket to fail, got %+v", states[1])
+	}
+
+	arn, err := rm.ResourceOutput(context.Background(), "project-001", "logBucket", ResourceOutputARN)
+	if err != nil || arn != "arn:aws:s3:::log-bucket" {
+		t.Fatalf("Expected arn of logBucket, got %q, %v", arn, err)
+	}
+	if _, err := rm.ResourceOutput(context.Background(), "project-001", "backup-bucket", ResourceOutputName); err == nil {
+		t.Fatalf("Expected outputs of a failed resource to be unavailable")
+	}
+}
+
+// updatingClientProvider updates the project once, while its resources are
+// provisioned
+type updatingClientProvider struct {
+	*stubClientProvider
+	update func()
+}
+
+func (p *updatingClientProvider) S3(ctx context.Context, region string) (S3API, error) {
+	if p.update != nil {
+		p.update()
+		p.update = nil
+	}
+	return p.stubClientProvider.S3(ctx, region)
+}
+
+func TestCreateProjectResourcesConcurrentUpdate(t *testing.T) {
+	ctx := context.Background()
+	clients := &updatingClientProvider{stubClientProvider: &stubClientProvider{s3: &stubS3{}}}
+	rm := &ResourceManager{Clients: clients}
+	bucket := Resource{ID: "bucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "bucket"}}
+	if err := rm.CreateProject(ctx, &CreateProjectInput{ID: "project-001", Resources: []Resource{bucket}}); err != nil {
+		t.Fatal(err)
+	}
+	clients.update = func() {
+		err := rm.UpdateProject(ctx, &CreateProjectInput{
+			ID:        "project-001",
+			Resources: []Resource{bucket, {ID: "queue", Type: "SQS:Queue", Properties: map[string]string{"QueueName": "queue"}}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := rm.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the update is kept, and the state of the bucket recorded on it
+	project, err := rm.GetProject(ctx, "project-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(project.Resources) != 2 || len(project.States) != 2 {
+		t.Fatalf("Expected the updated project, got %+v", project)
+	}
+	if project.States[0].Status != ResourceStatusProvisioned || project.States[1].Status != ResourceStatusPending {
+		t.Fatalf("Expected the bucket provisioned and the queue pending, got %+v", project.States)
+	}
+}
+
+func TestCreateProjectDuplicateResourceID(t *testing.T) {
+	rm := &ResourceManager{}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "bucket"}},
+			{ID: "bucket", Type: "SQS:Queue", Properties: map[string]string{"QueueName": "queue"}},
+		},
+	})
+	if err == nil {
+		t.Fatalf("Expected duplicate resource id to be rejected")
+	}
+}
+
+func TestCreateProjectExisting(t *testing.T) {
+	ctx := context.Background()
+	rm := &ResourceManager{Clients: &stubClientProvider{s3: &stubS3{}}}
+	bucket := Resource{ID: "bucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "bucket"}}
+	if err := rm.CreateProject(ctx, &CreateProjectInput{ID: "project-001", Resources: []Resource{bucket}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rm.CreateProject(ctx, &CreateProjectInput{ID: "project-001", Resources: []Resource{bucket}})
+	if !errors.Is(err, ErrProjectExists) {
+		t.Fatalf("Expected the existing project to be rejected, got %v", err)
+	}
+	project, err := rm.GetProject(ctx, "project-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.States[0].Status != ResourceStatusProvisioned {
+		t.Fatalf("Expected the state of the bucket to be kept, got %+v", project.States[0])
+	}
+}
+
+func TestCreateProjectResourcesOneAtATime(t *testing.T) {
+	ctx := context.Background()
+	clients := &updatingClientProvider{stubClientProvider: &stubClientProvider{s3: &stubS3{}}}
+	rm := &ResourceManager{Clients: clients}
+	bucket := Resource{ID: "bucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "bucket"}}
+	for _, id := range []string{"project-001", "project-002"} {
+		if err := rm.CreateProject(ctx, &CreateProjectInput{ID: id, Resources: []Resource{bucket}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// while project-001 is provisioned, the other calls on it wait
+	clients.update = func() {
+		clients.update = nil
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := rm.CreateProjectResources(waitCtx, &CreateProjectResourcesInput{ProjectID: "project-001"}); err == nil || !strings.Contains(err.Error(), "waiting for the provisioning") {
+			t.Errorf("Expected the concurrent provisioning to wait, got %v", err)
+		}
+		if _, err := rm.DestroyProjectResources(waitCtx, &DestroyProjectResourcesInput{ProjectID: "project-001", DryRun: true}); err == nil {
+			t.Errorf("Expected the concurrent destroy to wait")
+		}
+		if _, err := rm.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: "project-002", DryRun: true}); err != nil {
+			t.Errorf("Expected another project not to wait, got %v", err)
+		}
+	}
+	if _, err := rm.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: "project-001", DryRun: true}); err != nil {
+		t.Fatalf("Expected the provisioning to be released, got %v", err)
+	}
+}
+
+func TestCreateProjectResourcesIdempotent(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	resources := []Resource{
+		{ID: "backupBucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "backup", "Versioning": "Enabled", "Tags": "team=data"}},
+		{ID: "events", Type: "SQS:Queue", Properties: map[string]string{"QueueName": "events", "VisibilityTimeout": "60"}},
+	}
+	if err := rm.CreateProject(context.Background(), &CreateProjectInput{ID: "project-001", Resources: resources}); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := func(out CreateProjectResourcesOutput) []PlanAction {
+		actions := make([]PlanAction, 0, len(out.Plan))
+		for _, change := range out.Plan {
+			actions = append(actions, change.Action)
+		}
+		return actions
+	}
+	provision := func(dryRun bool) CreateProjectResourcesOutput {
+		t.Helper()
+		out, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001", DryRun: dryRun})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// a dry run plans the creation without creating anything
+	if out := provision(true); !reflect.DeepEqual(actions(out), []PlanAction{PlanActionCreate, PlanActionCreate}) {
+		t.Fatalf("Expected resources to be created, got %+v", out.Plan)
+	}
+	if len(clients.s3.buckets) != 0 {
+		t.Fatalf("Expected dry run not to create buckets")
+	}
+
+	provision(false)
+	if out := provision(false); !reflect.DeepEqual(actions(out), []PlanAction{PlanActionNone, PlanActionNone}) {
+		t.Fatalf("Expected no changes on the second run, got %+v", out.Plan)
+	}
+
+	// drift made outside of the project is reported, then reverted
+	clients.s3.buckets["backup"].versioning = types.BucketVersioningStatusSuspended
+	for _, attributes := range clients.sqs.queues {
+		attributes["VisibilityTimeout"] = "30"
+	}
+	out := provision(true)
+	want := []ResourceChange{
+		{ResourceID: "backupBucket", Type: "S3:Bucket", Action: PlanActionUpdate, Drift: []PropertyDrift{{Property: "Versioning", Current: "Suspended", Desired: "Enabled"}}},
+		{ResourceID: "events", Type: "SQS:Queue", Action: PlanActionUpdate, Drift: []PropertyDrift{{Property: "VisibilityTimeout", Current: "30", Desired: "60"}}},
+	}
+	if !reflect.DeepEqual(out.Plan, want) {
+		t.Fatalf("Expected plan %+v, got %+v", want, out.Plan)
+	}
+	provision(false)
+	if out := provision(true); !reflect.DeepEqual(actions(out), []PlanAction{PlanActionNone, PlanActionNone}) {
+		t.Fatalf("Expected drift to be reverted, got %+v", out.Plan)
+	}
+	projects, _ := rm.ListProjects(context.Background())
+	for _, state := range projects[0].States {
+		if state.Status != ResourceStatusProvisioned {
+			t.Fatalf("Expected %s to be provisioned, got %s", state.ResourceID, state.Status)
+		}
+	}
+}
+
+func TestDestroyProjectResources(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{ID: "archive", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "archive"}, DeletionPolicy: DeletionPolicyRetain},
+			{ID: "scratch", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "scratch"}},
+			{ID: "events", Type: "SQS:Queue", Properties: map[string]string{"QueueName": "events"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = clients.FakeS3().PutObject(context.Background(), &s3.PutObjectInput{Bucket: aws.String("scratch"), Key: aws.String("a.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the queue is deleted before the bucket fails for not being empty
+	if _, err := rm.DestroyProjectResources(context.Background(), &DestroyProjectResourcesInput{ProjectID: "project-001"}); err == nil {
+		t.Fatalf("Expected deletion of a non empty bucket to fail")
+	}
+	if len(clients.sqs.queues) != 0 {
+		t.Fatalf("Expected queue to be deleted first")
+	}
+
+	out, err := rm.DestroyProjectResources(context.Background(), &DestroyProjectResourcesInput{ProjectID: "project-001", EmptyFirst: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResourceChange{
+		{ResourceID: "events", Type: "SQS:Queue", Action: PlanActionNone},
+		{ResourceID: "scratch", Type: "S3:Bucket", Action: PlanActionDelete},
+		{ResourceID: "archive", Type: "S3:Bucket", Action: PlanActionRetain},
+	}
+	if !reflect.DeepEqual(out.Plan, want) {
+		t.Fatalf("Expected plan %+v, got %+v", want, out.Plan)
+	}
+	if _, ok := clients.s3.buckets["scratch"]; ok {
+		t.Fatalf("Expected bucket scratch to be deleted")
+	}
+	if _, ok := clients.s3.buckets["archive"]; !ok {
+		t.Fatalf("Expected retained bucket archive to be kept")
+	}
+
+	projects, _ := rm.ListProjects(context.Background())
+	statuses := make([]ResourceStatus, 0)
+	for _, state := range projects[0].States {
+		statuses = append(statuses, state.Status)
+	}
+	if !reflect.DeepEqual(statuses, []ResourceStatus{ResourceStatusProvisioned, ResourceStatusDeleted, ResourceStatusDeleted}) {
+		t.Fatalf("Expected retained bucket to stay provisioned, got %v", statuses)
+	}
+}
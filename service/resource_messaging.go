@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"strconv"
+	"strings"
+)
+
+type queueInput struct {
+	queue      string
+	region     string
+	attributes map[string]string
+	tags       map[string]string
+}
+
+func newQueueInput(r Resource) (queueInput, error) {
+	input := queueInput{
+		queue:      r.Properties["QueueName"],
+		region:     r.Properties["Region"],
+		attributes: make(map[string]string),
+	}
+
+	fifo := propertyBoolValue(r, "FifoQueue")
+	if fifo != strings.HasSuffix(input.queue, ".fifo") {
+		return queueInput{}, fmt.Errorf("the name of a FIFO queue, and only of a FIFO queue, must end with .fifo")
+	}
+	if fifo {
+		input.attributes[string(sqstypes.QueueAttributeNameFifoQueue)] = "true"
+	}
+
+	limits := []struct {
+		name     string
+		min, max int
+	}{
+		{"DelaySeconds", 0, 900},
+		{"MessageRetentionPeriod", 60, 1209600},
+		{"VisibilityTimeout", 0, 43200},
+	}
+	for _, limit := range limits {
+		n, ok := propertyIntValue(r, limit.name)
+		if !ok {
+			continue
+		}
+		if n < limit.min || n > limit.max {
+			return queueInput{}, fmt.Errorf("property %s must be between %d and %d", limit.name, limit.min, limit.max)
+		}
+		input.attributes[limit.name] = strconv.Itoa(n)
+	}
+	if policy := r.Properties["Policy"]; policy != "" {
+		input.attributes[string(sqstypes.QueueAttributeNamePolicy)] = policy
+	}
+
+	var err error
+	if input.tags, err = parseKeyValues(r.Properties["Tags"]); err != nil {
+		return queueInput{}, fmt.Errorf("invalid property Tags: %v", err)
+	}
+	return input, nil
+}
+
+// createQueue creates sqs queue resources
+func (rm *ResourceManager) createQueue(ctx context.Context, input queueInput) (*ResourceMetadata, error) {
+	client, err := rm.clients().SQS(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqs client: %v", err)
+	}
+	out, err := client.CreateQueue(ctx, &sqs.CreateQueueInput{
+		QueueName:  aws.String(input.queue),
+		Attributes: input.attributes,
+		Tags:       input.tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       out.QueueUrl,
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get arn of queue %s: %v", input.queue, err)
+	}
+	return &ResourceMetadata{
+		ID:   aws.ToString(out.QueueUrl),
+		Type: Queue,
+		Name: input.queue,
+		ARN:  attributes.Attributes[string(sqstypes.QueueAttributeNameQueueArn)],
+	}, nil
+}
+
+type topicInput struct {
+	topic      string
+	region     string
+	attributes map[string]string
+	tags       map[string]string
+}
+
+func newTopicInput(r Resource) (topicInput, error) {
+	input := topicInput{
+		topic:      r.Properties["TopicName"],
+		region:     r.Properties["Region"],
+		attributes: make(map[string]string),
+	}
+
+	fifo := propertyBoolValue(r, "FifoTopic")
+	if fifo != strings.HasSuffix(input.topic, ".fifo") {
+		return topicInput{}, fmt.Errorf("the name of a FIFO topic, and only of a FIFO topic, must end with .fifo")
+	}
+	if fifo {
+		input.attributes["FifoTopic"] = "true"
+	}
+	if displayName := r.Properties["DisplayName"]; displayName != "" {
+		input.attributes["DisplayName"] = displayName
+	}
+
+	var err error
+	if input.tags, err = parseKeyValues(r.Properties["Tags"]); err != nil {
+		return topicInput{}, fmt.Errorf("invalid property Tags: %v", err)
+	}
+	return input, nil
+}
+
+// createTopic creates sns topic resources
+func (rm *ResourceManager) createTopic(ctx context.Context, input topicInput) (*ResourceMetadata, error) {
+	client, err := rm.clients().SNS(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sns client: %v", err)
+	}
+	createInput := &sns.CreateTopicInput{
+		Name:       aws.String(input.topic),
+		Attributes: input.attributes,
+	}
+	for _, k := range sortedKeys(input.tags) {
+		createInput.Tags = append(createInput.Tags, snstypes.Tag{Key: aws.String(k), Value: aws.String(input.tags[k])})
+	}
+	out, err := client.CreateTopic(ctx, createInput)
+	if err != nil {
+		return nil, err
+	}
+	return &ResourceMetadata{
+		ID:   aws.ToString(out.TopicArn),
+		Type: Topic,
+		Name: input.topic,
+		ARN:  aws.ToString(out.TopicArn),
+	}, nil
+}
+
+// properties renders the attributes of the queue which can be updated
+func (input queueInput) properties() map[string]string {
+	return map[string]string{
+		"DelaySeconds":           input.attributes["DelaySeconds"],
+		"MessageRetentionPeriod": input.attributes["MessageRetentionPeriod"],
+		"VisibilityTimeout":      input.attributes["VisibilityTimeout"],
+		"Policy":                 normalizeJSON(input.attributes[string(sqstypes.QueueAttributeNamePolicy)]),
+	}
+}
+
+// describeQueue reads the attributes of an existing queue, it returns nil when
+// the queue does not exist. Tags are only set when the queue is created.
+func (rm *ResourceManager) describeQueue(ctx context.Context, input queueInput) (*observedResource, error) {
+	client, err := rm.clients().SQS(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqs client: %v", err)
+	}
+	url, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(input.queue)})
+	if err != nil {
+		var notExist *sqstypes.QueueDoesNotExist
+		if errors.As(err, &notExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to describe queue %s: %v", input.queue, err)
+	}
+	attributes, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       url.QueueUrl,
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameAll},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attributes of queue %s: %v", input.queue, err)
+	}
+
+	current := queueInput{queue: input.queue, attributes: attributes.Attributes}
+	return &observedResource{
+		metadata: &ResourceMetadata{
+			ID:   aws.ToString(url.QueueUrl),
+			Type: Queue,
+			Name: input.queue,
+			ARN:  attributes.Attributes[string(sqstypes.QueueAttributeNameQueueArn)],
+		},
+		drift: diffProperties(current.properties(), input.properties()),
+	}, nil
+}
+
+// updateQueue sets the attributes of an existing queue
+func (rm *ResourceManager) updateQueue(ctx context.Context, input queueInput) error {
+	client, err := rm.clients().SQS(ctx, input.region)
+	if err != nil {
+		return fmt.Errorf("failed to create sqs client: %v", err)
+	}
+	url, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(input.queue)})
+	if err != nil {
+		return fmt.Errorf("failed to describe queue %s: %v", input.queue, err)
+	}
+
+	attributes := make(map[string]string, len(input.attributes))
+	for k, v := range input.attributes {
+		// the queue type cannot be changed
+		if k != string(sqstypes.QueueAttributeNameFifoQueue) {
+			attributes[k] = v
+		}
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+	_, err = client.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   url.QueueUrl,
+		Attributes: attributes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set attributes of queue %s: %v", input.queue, err)
+	}
+	return nil
+}
+
+// properties renders the attributes of the topic which can be updated
+func (input topicInput) properties() map[string]string {
+	return map[string]string{
+		"DisplayName": input.attributes["DisplayName"],
+	}
+}
+
+// describeTopic reads the attributes of an existing topic, it returns nil when
+// the topic does not exist. Tags are only set when the topic is created.
+func (rm *ResourceManager) describeTopic(ctx context.Context, input topicInput) (*observedResource, error) {
+	client, err := rm.clients().SNS(ctx, input.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sns client: %v", err)
+	}
+	arn, err := findTopic(ctx, client, input.topic)
+	if err != nil || arn == "" {
+		return nil, err
+	}
+	attributes, err := client.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(arn)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attributes of topic %s: %v", input.topic, err)
+	}
+
+	current := topicInput{topic: input.topic, attributes: attributes.Attributes}
+	return &observedResource{
+		metadata: &ResourceMetadata{
+			ID:   arn,
+			Type: Topic,
+			Name: input.topic,
+			ARN:  arn,
+		},
+		drift: diffProperties(current.properties(), input.properties()),
+	}, nil
+}
+
+// updateTopic sets the display name of an existing topic
+func (rm *ResourceManager) updateTopic(ctx context.Context, input topicInput) error {
+	client, err := rm.clients().SNS(ctx, input.region)
+	if err != nil {
+		return fmt.Errorf("failed to create sns client: %v", err)
+	}
+	arn, err := findTopic(ctx, client, input.topic)
+	if err != nil {
+		return err
+	}
+	if arn == "" {
+		return fmt.Errorf("topic %s does not exist", input.topic)
+	}
+	_, err = client.SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+		TopicArn:       aws.String(arn),
+		AttributeName:  aws.String("DisplayName"),
+		AttributeValue: aws.String(input.attributes["DisplayName"]),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set attributes of topic %s: %v", input.topic, err)
+	}
+	return nil
+}
+
+// findTopic returns the ARN of a topic by name, or an empty string when the
+// topic does not exist.
+func findTopic(ctx context.Context, client SNSAPI, name string) (string, error) {
+	paginator := sns.NewListTopicsPaginator(client, &sns.ListTopicsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list topics: %v", err)
+		}
+		for _, topic := range page.Topics {
+			if arn := aws.ToString(topic.TopicArn); strings.HasSuffix(arn, ":"+name) {
+				return arn, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// deleteQueue deletes an existing queue
+func (rm *ResourceManager) deleteQueue(ctx context.Context, input queueInput) error {
+	client, err := rm.clients().SQS(ctx, input.region)
+	if err != nil {
+		return fmt.Errorf("failed to create sqs client: %v", err)
+	}
+	url, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(input.queue)})
+	if err != nil {
+		return fmt.Errorf("failed to describe queue %s: %v", input.queue, err)
+	}
+	if _, err := client.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: url.QueueUrl}); err != nil {
+		return fmt.Errorf("failed to delete queue %s: %v", input.queue, err)
+	}
+	return nil
+}
+
+// deleteTopic deletes an existing topic and its subscriptions
+func (rm *ResourceManager) deleteTopic(ctx context.Context, input topicInput) error {
+	client, err := rm.clients().SNS(ctx, input.region)
+	if err != nil {
+		return fmt.Errorf("failed to create sns client: %v", err)
+	}
+	arn, err := findTopic(ctx, client, input.topic)
+	if err != nil || arn == "" {
+		return err
+	}
+	if _, err := client.DeleteTopic(ctx, &sns.DeleteTopicInput{TopicArn: aws.String(arn)}); err != nil {
+		return fmt.Errorf("failed to delete topic %s: %v", input.topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go"
+	"strings"
+)
+
+// PlanAction is the change planned for a project resource
+type PlanAction string
+
+const (
+	PlanActionCreate PlanAction = "create"
+	PlanActionUpdate PlanAction = "update"
+	PlanActionNone   PlanAction = "none"
+	PlanActionDelete PlanAction = "delete"
+	// PlanActionRetain is planned instead of a deletion for retained resources
+	PlanActionRetain PlanAction = "retain"
+)
+
+// ResourceChange is the planned change of a project resource. Drift lists the
+// properties whose current value differs from the project definition, only
+// the properties set in the definition are compared.
+type ResourceChange struct {
+	ResourceID string          `json:"resourceId"`
+	Type       string          `json:"type"`
+	Action     PlanAction      `json:"action"`
+	Drift      []PropertyDrift `json:"drift,omitempty"`
+}
+
+type PropertyDrift struct {
+	Property string `json:"property"`
+	Current  string `json:"current"`
+	Desired  string `json:"desired"`
+}
+
+// observedResource is a resource which already exists in the cloud
+type observedResource struct {
+	metadata *ResourceMetadata
+	drift    []PropertyDrift
+}
+
+// planResource describes the current state of a resource and compares it with
+// the definition.
+func (rm *ResourceManager) planResource(ctx context.Context, r Resource) (ResourceChange, *observedResource, error) {
+	var observed *observedResource
+	var err error
+	switch r.Type {
+	case "S3:Bucket":
+		var input bucketInput
+		if input, err = newBucketInput(r); err == nil {
+			observed, err = rm.describeBucket(ctx, input)
+		}
+	case "SQS:Queue":
+		var input queueInput
+		if input, err = newQueueInput(r); err == nil {
+			observed, err = rm.describeQueue(ctx, input)
+		}
+	case "SNS:Topic":
+		var input topicInput
+		if input, err = newTopicInput(r); err == nil {
+			observed, err = rm.describeTopic(ctx, input)
+		}
+	case "CloudWatch:Alarm":
+		var input alarmInput
+		if input, err = newAlarmInput(r); err == nil {
+			observed, err = rm.describeAlarm(ctx, input)
+		}
+	default:
+		err = fmt.Errorf("unsupported resource type %q", r.Type)
+	}
+	if err != nil {
+		return ResourceChange{}, nil, err
+	}
+
+	change := ResourceChange{
+		ResourceID: r.ID,
+		Type:       r.Type,
+		Action:     PlanActionCreate,
+	}
+	if observed != nil {
+		change.Action = PlanActionNone
+		change.Drift = observed.drift
+		if len(observed.drift) > 0 {
+			change.Action = PlanActionUpdate
+		}
+	}
+	return change, observed, nil
+}
+
+// applyChange creates or updates a resource as planned
+func (rm *ResourceManager) applyChange(ctx context.Context, r Resource, change ResourceChange, observed *observedResource) (*ResourceMetadata, error) {
+	switch change.Action {
+	case PlanActionCreate:
+		return rm.createResource(ctx, r)
+	case PlanActionUpdate:
+		if err := rm.updateResource(ctx, r); err != nil {
+			return nil, err
+		}
+		return observed.metadata, nil
+	default:
+		return observed.metadata, nil
+	}
+}
+
+func (rm *ResourceManager) updateResource(ctx context.Context, r Resource) error {
+	switch r.Type {
+	case "S3:Bucket":
+		input, err := newBucketInput(r)
+		if err != nil {
+			return err
+		}
+		client, err := rm.clients().S3(ctx, input.region)
+		if err != nil {
+			return fmt.Errorf("failed to create s3 client: %v", err)
+		}
+		return configureBucket(ctx, client, input)
+	case "SQS:Queue":
+		input, err := newQueueInput(r)
+		if err != nil {
+			return err
+		}
+		return rm.updateQueue(ctx, input)
+	case "SNS:Topic":
+		input, err := newTopicInput(r)
+		if err != nil {
+			return err
+		}
+		return rm.updateTopic(ctx, input)
+	case "CloudWatch:Alarm":
+		input, err := newAlarmInput(r)
+		if err != nil {
+			return err
+		}
+		// PutMetricAlarm replaces the existing alarm
+		_, err = rm.createAlarm(ctx, input)
+		return err
+	default:
+		return fmt.Errorf("unsupported resource type %q", r.Type)
+	}
+}
+
+// diffProperties compares the properties set in desired with current. Both
+// must be rendered in the same canonical form.
+func diffProperties(current map[string]string, desired map[string]string) []PropertyDrift {
+	drift := make([]PropertyDrift, 0)
+	for _, name := range sortedKeys(desired) {
+		if desired[name] == "" || current[name] == desired[name] {
+			continue
+		}
+		drift = append(drift, PropertyDrift{
+			Property: name,
+			Current:  current[name],
+			Desired:  desired[name],
+		})
+	}
+	return drift
+}
+
+// normalizeJSON compacts a JSON document and sorts its keys, so that documents
+// returned by AWS compare equal to the definition.
+func normalizeJSON(s string) string {
+	if s == "" {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return s
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// formatKeyValues is the reverse of parseKeyValues, with sorted keys
+func formatKeyValues(values map[string]string) string {
+	pairs := make([]string, 0, len(values))
+	for _, k := range sortedKeys(values) {
+		pairs = append(pairs, k+"="+values[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// apiErrorCode returns the code of an AWS API error, or an empty string for
+// other errors.
+func apiErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
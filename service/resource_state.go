@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ResourceState is the provisioning state of a project resource
+type ResourceState struct {
+	ResourceID string         `json:"resourceId"`
+	Type       string         `json:"type"`
+	Status     ResourceStatus `json:"status"`
+	// PhysicalID is the identifier of the resource in the cloud, e.g. the
+	// bucket name or the queue URL
+	PhysicalID string    `json:"physicalId,omitempty"`
+	ARN        string    `json:"arn,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Resource outputs which can be referenced by workflows
+const (
+	ResourceOutputID   = "id"
+	ResourceOutputARN  = "arn"
+	ResourceOutputName = "name"
+)
+
+// resourceReference matches references to resource outputs such as
+// ${resources.logBucket.name}
+var resourceReference = regexp.MustCompile(`\$\{resources\.([A-Za-z0-9_-]+)\.([A-Za-z]+)\}`)
+
+// output returns the value of an output of a provisioned resource
+func (s ResourceState) output(name string) (string, error) {
+	if s.Status != ResourceStatusProvisioned {
+		return "", fmt.Errorf("resource %s is %s", s.ResourceID, s.Status)
+	}
+	switch name {
+	case ResourceOutputID:
+		return s.PhysicalID, nil
+	case ResourceOutputARN:
+		return s.ARN, nil
+	case ResourceOutputName:
+		return s.Name, nil
+	default:
+		return "", fmt.Errorf("unknown output %q of resource %s", name, s.ResourceID)
+	}
+}
+
+// resolveReferences replaces the references to resource outputs in s
+func resolveReferences(s string, lookup func(resourceID string, output string) (string, error)) (string, error) {
+	var err error
+	resolved := resourceReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		m := resourceReference.FindStringSubmatch(ref)
+		var value string
+		value, err = lookup(m[1], m[2])
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// newResourceState builds the state of a resource from the result of its
+// provisioning
+func newResourceState(r Resource, metadata *ResourceMetadata, err error) ResourceState {
+	state := ResourceState{
+		ResourceID: r.ID,
+		Type:       r.Type,
+		Status:     ResourceStatusProvisioned,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if err != nil {
+		state.Status = ResourceStatusFailed
+		state.Error = err.Error()
+		return state
+	}
+	state.PhysicalID = metadata.ID
+	state.ARN = metadata.ARN
+	state.Name = metadata.Name
+	return state
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type propertyKind string
+
+const (
+	propertyString propertyKind = "string"
+	propertyInt    propertyKind = "int"
+	propertyFloat  propertyKind = "float"
+	propertyBool   propertyKind = "bool"
+	propertyJSON   propertyKind = "json"
+	// propertyList is a comma separated list of values
+	propertyList propertyKind = "list"
+	// propertyKeyValues is a comma separated list of key=value pairs
+	propertyKeyValues propertyKind = "keyValues"
+)
+
+type propertySpec struct {
+	name     string
+	kind     propertyKind
+	required bool
+	// values restricts a string property to a set of values
+	values []string
+}
+
+type resourceSpec struct {
+	resourceType ResourceType
+	properties   []propertySpec
+}
+
+// resourceSpecs describes the properties of the supported resource types
+var resourceSpecs = map[string]resourceSpec{
+	"S3:Bucket": {
+		resourceType: Bucket,
+		properties: []propertySpec{
+			{name: "BucketName", kind: propertyString, required: true},
+			{name: "Region", kind: propertyString},
+			{name: "Policy", kind: propertyJSON},
+			{name: "LifecycleRules", kind: propertyJSON},
+			{name: "Versioning", kind: propertyString, values: []string{"Enabled", "Suspended"}},
+			{name: "Encryption", kind: propertyString, values: []string{"AES256", "aws:kms"}},
+			{name: "KMSKeyId", kind: propertyString},
+			{name: "Tags", kind: propertyKeyValues},
+		},
+	},
+	"SQS:Queue": {
+		resourceType: Queue,
+		properties: []propertySpec{
+			{name: "QueueName", kind: propertyString, required: true},
+			{name: "Region", kind: propertyString},
+			{name: "FifoQueue", kind: propertyBool},
+			{name: "DelaySeconds", kind: propertyInt},
+			{name: "MessageRetentionPeriod", kind: propertyInt},
+			{name: "VisibilityTimeout", kind: propertyInt},
+			{name: "Policy", kind: propertyJSON},
+			{name: "Tags", kind: propertyKeyValues},
+		},
+	},
+	"SNS:Topic": {
+		resourceType: Topic,
+		properties: []propertySpec{
+			{name: "TopicName", kind: propertyString, required: true},
+			{name: "Region", kind: propertyString},
+			{name: "DisplayName", kind: propertyString},
+			{name: "FifoTopic", kind: propertyBool},
+			{name: "Tags", kind: propertyKeyValues},
+		},
+	},
+	"CloudWatch:Alarm": {
+		resourceType: Alarm,
+		properties: []propertySpec{
+			{name: "AlarmName", kind: propertyString, required: true},
+			{name: "Region", kind: propertyString},
+			{name: "Namespace", kind: propertyString, required: true},
+			{name: "MetricName", kind: propertyString, required: true},
+			{name: "Statistic", kind: propertyString, required: true, values: []string{"Average", "Sum", "Minimum", "Maximum", "SampleCount"}},
+			{name: "Period", kind: propertyInt, required: true},
+			{name: "EvaluationPeriods", kind: propertyInt, required: true},
+			{name: "Threshold", kind: propertyFloat, required: true},
+			{name: "ComparisonOperator", kind: propertyString, required: true, values: []string{
+				"GreaterThanOrEqualToThreshold", "GreaterThanThreshold", "LessThanThreshold", "LessThanOrEqualToThreshold",
+			}},
+			{name: "TreatMissingData", kind: propertyString, values: []string{"breaching", "notBreaching", "ignore", "missing"}},
+			{name: "AlarmActions", kind: propertyList},
+			{name: "Dimensions", kind: propertyKeyValues},
+		},
+	},
+}
+
+// validateResource checks the type of a resource and its properties
+func validateResource(r Resource) error {
+	spec, ok := resourceSpecs[r.Type]
+	if !ok {
+		return fmt.Errorf("unsupported resource type %q", r.Type)
+	}
+	switch r.DeletionPolicy {
+	case "", DeletionPolicyDelete, DeletionPolicyRetain:
+	default:
+		return fmt.Errorf("unsupported deletion policy %q", r.DeletionPolicy)
+	}
+
+	known := make(map[string]bool, len(spec.properties))
+	resolved := true
+	for _, p := range spec.properties {
+		known[p.name] = true
+		value, ok := r.Properties[p.name]
+		if !ok || value == "" {
+			if p.required {
+				return fmt.Errorf("property %s of %s is required", p.name, r.Type)
+			}
+			continue
+		}
+		if hasReferences(value) {
+			resolved = false
+			continue
+		}
+		if err := p.validate(value); err != nil {
+			return fmt.Errorf("invalid property %s of %s: %v", p.name, r.Type, err)
+		}
+	}
+
+	unknown := make([]string, 0)
+	for name := range r.Properties {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown properties of %s: %s", r.Type, strings.Join(unknown, ", "))
+	}
+
+	// checks across properties, which run again once references are resolved
+	if !resolved {
+		return nil
+	}
+	var err error
+	switch r.Type {
+	case "S3:Bucket":
+		_, err = newBucketInput(r)
+	case "SQS:Queue":
+		_, err = newQueueInput(r)
+	case "SNS:Topic":
+		_, err = newTopicInput(r)
+	case "CloudWatch:Alarm":
+		_, err = newAlarmInput(r)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", r.Type, err)
+	}
+	return nil
+}
+
+func (p propertySpec) validate(value string) error {
+	switch p.kind {
+	case propertyInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+	case propertyFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("expected a number, got %q", value)
+		}
+	case propertyBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("expected a boolean, got %q", value)
+		}
+	case propertyJSON:
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("expected a JSON document")
+		}
+	case propertyKeyValues:
+		if _, err := parseKeyValues(value); err != nil {
+			return err
+		}
+	}
+
+	if len(p.values) > 0 {
+		for _, v := range p.values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %s, got %q", strings.Join(p.values, ", "), value)
+	}
+	return nil
+}
+
+// resourceName returns the name of the resource in the cloud, which is given by
+// a different property for each resource type.
+func resourceName(r Resource) string {
+	switch r.Type {
+	case "S3:Bucket":
+		return r.Properties["BucketName"]
+	case "SQS:Queue":
+		return r.Properties["QueueName"]
+	case "SNS:Topic":
+		return r.Properties["TopicName"]
+	case "CloudWatch:Alarm":
+		return r.Properties["AlarmName"]
+	default:
+		return ""
+	}
+}
+
+func propertyIntValue(r Resource, name string) (int, bool) {
+	value, ok := r.Properties[name]
+	if !ok || value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+func propertyBoolValue(r Resource, name string) bool {
+	b, _ := strconv.ParseBool(r.Properties[name])
+	return b
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
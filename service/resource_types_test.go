@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"strings"
+	"testing"
+)
+
+func TestValidateResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource Resource
+		err      string
+	}{
+		{
+			name:     "bucket",
+			resource: Resource{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "b", "Versioning": "Enabled", "Tags": "team=data"}},
+		},
+		{
+			name:     "unsupported type",
+			resource: Resource{Type: "EC2:Instance"},
+			err:      "unsupported resource type",
+		},
+		{
+			name:     "missing required property",
+			resource: Resource{Type: "SQS:Queue", Properties: map[string]string{}},
+			err:      "property QueueName of SQS:Queue is required",
+		},
+		{
+			name:     "unknown property",
+			resource: Resource{Type: "SNS:Topic", Properties: map[string]string{"TopicName": "t", "Color": "red"}},
+			err:      "unknown properties of SNS:Topic: Color",
+		},
+		{
+			name:     "invalid enum",
+			resource: Resource{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "b", "Versioning": "On"}},
+			err:      "expected one of Enabled, Suspended",
+		},
+		{
+			name:     "invalid integer",
+			resource: Resource{Type: "SQS:Queue", Properties: map[string]string{"QueueName": "q", "DelaySeconds": "soon"}},
+			err:      "expected an integer",
+		},
+		{
+			name:     "invalid policy",
+			resource: Resource{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "b", "Policy": "{"}},
+			err:      "expected a JSON document",
+		},
+		{
+			name:     "kms key without kms encryption",
+			resource: Resource{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "b", "Encryption": "AES256", "KMSKeyId": "key"}},
+			err:      "KMSKeyId requires Encryption aws:kms",
+		},
+		{
+			name: "lifecycle rule without action",
+			resource: Resource{Type: "S3:Bucket", Properties: map[string]string{
+				"BucketName": "b", "LifecycleRules": `[{"id": "logs", "prefix": "logs/"}]`,
+			}},
+			err: "at least one action is required",
+		},
+		{
+			name:     "fifo queue name",
+			resource: Resource{Type: "SQS:Queue", Properties: map[string]string{"QueueName": "q", "FifoQueue": "true"}},
+			err:      ".fifo",
+		},
+		{
+			name:     "queue delay out of range",
+			resource: Resource{Type: "SQS:Queue", Properties: map[string]string{"QueueName": "q", "DelaySeconds": "901"}},
+			err:      "DelaySeconds",
+		},
+		{
+			name: "alarm period",
+			resource: Resource{Type: "CloudWatch:Alarm", Properties: map[string]string{
+				"AlarmName": "a", "Namespace": "AWS/SQS", "MetricName": "ApproximateNumberOfMessagesVisible", "Statistic": "Sum",
+				"Period": "45", "EvaluationPeriods": "1", "Threshold": "10", "ComparisonOperator": "GreaterThanThreshold",
+			}},
+			err: "Period",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResource(tt.resource)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Expected resource to be valid, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCreateProjectResourcesTypes(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID: "project-001",
+		Resources: []Resource{
+			{Type: "S3:Bucket", Properties: map[string]string{
+				"BucketName":     "backup-bucket",
+				"Versioning":     "Enabled",
+				"Encryption":     "AES256",
+				"LifecycleRules": `[{"id": "expire", "prefix": "logs/", "expirationDays": 30}]`,
+				"Tags":           "team=data",
+			}},
+			{Type: "SQS:Queue", Properties: map[string]string{"QueueName": "backup-events", "VisibilityTimeout": "60"}},
+			{Type: "SNS:Topic", Properties: map[string]string{"TopicName": "backup-alerts", "DisplayName": "Backups"}},
+			{Type: "CloudWatch:Alarm", Properties: map[string]string{
+				"AlarmName": "backup-backlog", "Namespace": "AWS/SQS", "MetricName": "ApproximateNumberOfMessagesVisible",
+				"Statistic": "Sum", "Period": "300", "EvaluationPeriods": "2", "Threshold": "100",
+				"ComparisonOperator": "GreaterThanThreshold", "Dimensions": "QueueName=backup-events",
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := clients.s3.buckets["backup-bucket"]
+	if bucket == nil {
+		t.Fatalf("Expected bucket backup-bucket to be created")
+	}
+	if bucket.versioning != types.BucketVersioningStatusEnabled || bucket.encryption == nil || len(bucket.lifecycle) != 1 || len(bucket.tags) != 1 {
+		t.Fatalf("Expected bucket sub-properties to be applied, got %+v", bucket)
+	}
+	if len(clients.sqs.queues) != 1 {
+		t.Fatalf("Expected 1 queue, got %d", len(clients.sqs.queues))
+	}
+	for _, attributes := range clients.sqs.queues {
+		if attributes["VisibilityTimeout"] != "60" {
+			t.Fatalf("Expected VisibilityTimeout 60, got %v", attributes)
+		}
+	}
+	if len(clients.sns.topics) != 1 {
+		t.Fatalf("Expected 1 topic, got %d", len(clients.sns.topics))
+	}
+	if _, ok := clients.cloudWatch.alarms["backup-backlog"]; !ok {
+		t.Fatalf("Expected alarm backup-backlog to be created")
+	}
+
+	// the described resources compare equal to their definitions
+	out, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range out.Plan {
+		if change.Action != PlanActionNone {
+			t.Fatalf("Expected no changes for %s, got %+v", change.ResourceID, change)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler starts the runs of the active scheduled triggers. The config of a
+// scheduled trigger has a cron expression of five fields evaluated in UTC, e.g.
+// {"cron": "0 2 * * *"}, and its input is a JSON object of run inputs, if any.
+type Scheduler struct {
+	Workflows *WorkflowManager
+	Engine    *WorkflowEngine
+	// Interval is the period at which the triggers are checked
+	Interval time.Duration
+	// MaxConcurrentRuns bounds the runs started by the scheduler which are in
+	// progress, the triggers due beyond it are skipped until the next time
+	MaxConcurrentRuns int
+	// Report is called when a due trigger is not run, it may be nil
+	Report func(triggerID string, err error)
+	// Context is the context of the runs started, which are canceled with it,
+	// context.Background when nil
+	Context context.Context
+
+	mu sync.Mutex
+	// runs are the IDs of the runs started by the scheduler, in progress when
+	// last checked
+	runs []string
+	// now returns the current time, time.Now when nil
+	now func() time.Time
+}
+
+// Run checks the triggers every Interval until ctx is done, the runs started
+// go on with Context
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	last := s.clock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := s.clock()
+		s.check(last, now)
+		last = now
+	}
+}
+
+// check starts the runs of the triggers due in (from, to]
+func (s *Scheduler) check(from, to time.Time) {
+	output, err := s.Workflows.ListWorkflowTriggers()
+	if err != nil {
+		s.report("", err)
+		return
+	}
+	triggers := output.Triggers
+	sort.Slice(triggers, func(i, j int) bool { return triggers[i].ID < triggers[j].ID })
+	for _, t := range triggers {
+		if t.Type != string(WorkflowTriggerTypeScheduled) || t.Status == StatusDeactive {
+			continue
+		}
+		expr, _ := t.Config["cron"].(string)
+		schedule, err := parseCron(expr)
+		if err != nil {
+			s.report(t.ID, err)
+			continue
+		}
+		if !schedule.due(from, to) {
+			continue
+		}
+		if n := s.inProgress(); n >= s.MaxConcurrentRuns {
+			s.report(t.ID, fmt.Errorf("run skipped, %d runs of the scheduler are in progress", n))
+			continue
+		}
+		var inputs map[string]interface{}
+		if t.Input != "" {
+			if err := json.Unmarshal([]byte(t.Input), &inputs); err != nil {
+				s.report(t.ID, fmt.Errorf("error when reading the trigger input: %v", err))
+				continue
+			}
+		}
+		run, err := s.Engine.StartWorkflow(s.runContext(), RunWorkflowInput{ID: t.WorkflowID, Input: inputs})
+		if err != nil {
+			s.report(t.ID, err)
+			continue
+		}
+		s.mu.Lock()
+		s.runs = append(s.runs, run.ID)
+		s.mu.Unlock()
+	}
+}
+
+// inProgress returns the number of runs of the scheduler which are running,
+// and forgets the ended ones
+func (s *Scheduler) inProgress() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	running := s.runs[:0]
+	for _, id := range s.runs {
+		if run, err := s.Engine.GetRun(id); err == nil && run.Status == RunStatusRunning {
+			running = append(running, id)
+		}
+	}
+	s.runs = running
+	return len(running)
+}
+
+func (s *Scheduler) report(triggerID string, err error) {
+	if s.Report != nil {
+		s.Report(triggerID, err)
+	}
+}
+
+func (s *Scheduler) runContext() context.Context {
+	if s.Context != nil {
+		return s.Context
+	}
+	return context.Background()
+}
+
+func (s *Scheduler) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// cronSchedule is a cron expression, each field is the set of its values
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// anyDay is true when the day of month or the day of week is *, a day then
+	// matches both fields rather than either of them
+	anyDay bool
+}
+
+// cronFields are the bounds of the fields of a cron expression
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// parseCron parses a cron expression of five fields: minute, hour, day of
+// month, month and day of week. A field is *, a value, a range a-b or a list of
+// them separated by commas, * and ranges may have a step, e.g. */15.
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return cronSchedule{}, fmt.Errorf("invalid cron expression %q: expected %d fields", expr, len(cronFields))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("invalid cron expression %q: %s: %v", expr, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	return cronSchedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		anyDay: strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		values, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			values, step = part[:i], n
+		}
+		from, to := min, max
+		if values != "*" {
+			bounds := strings.SplitN(values, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				return nil, fmt.Errorf("a step needs * or a range in %q", part)
+			}
+			if from < min || to > max || from > to {
+				return nil, fmt.Errorf("%q is out of %d-%d", values, min, max)
+			}
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches tells whether the minute of t is part of the schedule, in UTC
+func (c cronSchedule) matches(t time.Time) bool {
+	t = t.UTC()
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// due tells whether a minute of the schedule starts in (from, to]
+func (c cronSchedule) due(from, to time.Time) bool {
+	for t := from.Truncate(time.Minute).Add(time.Minute); !t.After(to); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	cases := []struct {
+		expr    string
+		time    string
+		matches bool
+	}{
+		{"0 2 * * *", "2024-05-06T02:00:00Z", true},
+		{"0 2 * * *", "2024-05-06T02:01:00Z", false},
+		{"*/15 * * * *", "2024-05-06T10:45:00Z", true},
+		{"*/15 * * * *", "2024-05-06T10:46:00Z", false},
+		{"0 9-17/4 * * 1-5", "2024-05-06T13:00:00Z", true},
+		{"0 9-17/4 * * 1-5", "2024-05-05T13:00:00Z", false},
+		{"30 6 1,15 * *", "2024-05-15T06:30:00Z", true},
+		// the day of month or the day of week match when both are restricted
+		{"0 0 1 * 0", "2024-05-05T00:00:00Z", true},
+		{"0 0 1 * 0", "2024-05-06T00:00:00Z", false},
+	}
+	for _, c := range cases {
+		schedule, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("Expected %q to be parsed, got %v", c.expr, err)
+		}
+		if got := schedule.matches(at(c.time)); got != c.matches {
+			t.Fatalf("Expected %q to match %s: %v, got %v", c.expr, c.time, c.matches, got)
+		}
+	}
+
+	for _, expr := range []string{"", "0 2 * *", "60 * * * *", "* * 0 * *", "5/2 * * * *", "a * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Fatalf("Expected %q to be rejected", expr)
+		}
+	}
+
+	schedule, _ := parseCron("0 2 * * *")
+	if !schedule.due(at("2024-05-06T01:59:30Z"), at("2024-05-06T02:00:30Z")) {
+		t.Fatalf("Expected the schedule to be due")
+	}
+	if schedule.due(at("2024-05-06T02:00:00Z"), at("2024-05-06T02:01:00Z")) {
+		t.Fatalf("Expected the schedule not to be due twice")
+	}
+}
+
+func TestSchedulerCheck(t *testing.T) {
+	fake := newFakeBucket(t, "backup")
+	clients := blockingClients{FakeClientProvider: fake, s3: blockingS3{FakeS3: fake.FakeS3(), started: make(chan struct{})}}
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wm := &WorkflowManager{Clients: clients}
+	_, err := wm.CreateWorkflow(ctx, &CreateWorkflowInput{
+		ID:     "workflow-001",
+		Inputs: WorkflowInputs{{Name: "dir"}},
+		Components: []ComponentInfo{
+			{ID: "read", Type: "ReadFile", Next: "upload", Inputs: []Variable{{Name: "directory", DefaultValue: "${input.dir}"}}},
+			{ID: "upload", Type: "S3:PutObject", Inputs: []Variable{{Name: "bucket", DefaultValue: "backup"}, {Name: "baseDir", DefaultValue: dir}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	triggers := []CreateWorkflowTriggerInput{
+		{ID: "trigger-001", Type: "scheduled", Config: map[string]interface{}{"cron": "0 2 * * *"}, WorkflowID: "workflow-001", Input: `{"dir": "` + dir + `"}`, Status: StatusActive},
+		{ID: "trigger-002", Type: "scheduled", Config: map[string]interface{}{"cron": "0 2 * * *"}, WorkflowID: "workflow-001", Input: `{"dir": "` + dir + `"}`, Status: StatusActive},
+		{ID: "trigger-003", Type: "scheduled", Config: map[string]interface{}{"cron": "0 2 * * *"}, WorkflowID: "workflow-001", Status: StatusDeactive},
+		{ID: "trigger-004", Type: "scheduled", Config: map[string]interface{}{"cron": "0 3 * * *"}, WorkflowID: "workflow-001"},
+		{ID: "trigger-005", Type: "scheduled", Config: map[string]interface{}{"cron": "0 2 *"}, WorkflowID: "workflow-001"},
+		{ID: "trigger-006", Type: "event", Config: map[string]interface{}{"cron": "0 2 * * *"}, WorkflowID: "workflow-001"},
+	}
+	for i := range triggers {
+		if err := wm.CreateWorkflowTrigger(ctx, &triggers[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := &WorkflowEngine{Workflows: wm}
+	reported := make(map[string]error)
+	now := time.Date(2024, 5, 6, 2, 0, 30, 0, time.UTC)
+	s := &Scheduler{
+		Workflows:         wm,
+		Engine:            engine,
+		Interval:          time.Minute,
+		MaxConcurrentRuns: 1,
+		Report:            func(triggerID string, err error) { reported[triggerID] = err },
+		Context:           ctx,
+		now:               func() time.Time { return now },
+	}
+	s.check(now.Add(-time.Minute), now)
+	<-clients.s3.started
+
+	if len(s.runs) != 1 {
+		t.Fatalf("Expected 1 run to be started, got %v", s.runs)
+	}
+	run, err := engine.GetRun(s.runs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.WorkflowID != "workflow-001" || run.Status != RunStatusRunning {
+		t.Fatalf("Expected a running run of workflow-001, got %+v", run)
+	}
+	if err := reported["trigger-002"]; err == nil || !strings.Contains(err.Error(), "skipped") {
+		t.Fatalf("Expected trigger-002 to be skipped beyond the max runs, got %v", err)
+	}
+	if err := reported["trigger-005"]; err == nil || !strings.Contains(err.Error(), "invalid cron expression") {
+		t.Fatalf("Expected the invalid cron expression to be reported, got %v", err)
+	}
+	if len(reported) != 2 {
+		t.Fatalf("Expected only trigger-002 and trigger-005 to be reported, got %v", reported)
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.inProgress() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := s.inProgress(); n != 0 {
+		t.Fatalf("Expected the canceled run to end, got %d runs in progress", n)
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolveSecret returns the value a secret reference points to. A reference is
+// either "env:NAME" for an environment variable or "file:PATH" for a file whose
+// trimmed content is the secret.
+func ResolveSecret(ref string) (string, error) {
+	kind, name, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference %q", ref)
+	}
+
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return value, nil
+	case "file":
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file %s: %v", name, err)
+		}
+		value := strings.TrimSpace(string(content))
+		if value == "" {
+			return "", fmt.Errorf("secret file %s is empty", name)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported secret reference kind %q", kind)
+	}
+}
@@ -0,0 +1,492 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// SyncCompareSizeMtime treats a file as unchanged when its size and
+	// modification time match
+	SyncCompareSizeMtime = "size-mtime"
+	// SyncCompareHash treats a file as unchanged when its content hash matches
+	SyncCompareHash = "hash"
+
+	// SyncSourceManifest compares against the manifest saved by the previous run
+	SyncSourceManifest = "manifest"
+	// SyncSourceBucket compares against the objects listed in the bucket
+	SyncSourceBucket = "bucket"
+
+	defaultSyncManifestKey = ".golden-sync-manifest.json"
+	// syncChecksumMetadata is the object metadata holding the SHA-256 of the
+	// content, S3 returns the metadata keys in lower case
+	syncChecksumMetadata   = "golden-sha256"
+	deleteObjectsBatchSize = 1000
+)
+
+// s3 sync component, uploads only the files that changed since the previous
+// run and optionally deletes the objects of removed files. Keys must be stable
+// between runs, so key templates should not use the date or run placeholders.
+type ComponentSyncObjects struct {
+	id      string
+	next    string
+	clients ClientProvider
+	// bucket and region are used when the input does not name a bucket
+	bucket  string
+	region  string
+	upload  uploadOptions
+	workers int
+	object  objectOptions
+	compare string
+	source  string
+	// manifestKey is the object key of the manifest, relative to the prefix
+	manifestKey string
+	delete      bool
+}
+
+type SyncObjectsInput struct {
+	bucket string
+	region string
+	files  []string
+	runID  string
+}
+
+type SyncObjectsOutput struct {
+	added   int
+	changed int
+	skipped int
+	deleted int
+	objects []UploadedObject
+}
+
+// syncManifest records the uploaded files by object key.
+type syncManifest struct {
+	Objects map[string]syncManifestEntry `json:"objects"`
+}
+
+type syncManifestEntry struct {
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SHA256  string    `json:"sha256,omitempty"`
+}
+
+// remoteObject is the state of an object in the bucket.
+type remoteObject struct {
+	size         int64
+	lastModified time.Time
+	// sha256 is read from the metadata of the object when comparing hashes
+	sha256 string
+}
+
+// localFile is the state of a file to sync.
+type localFile struct {
+	file    string
+	key     string
+	size    int64
+	modTime time.Time
+	sha256  string
+}
+
+func newComponentSyncObjects(ci ComponentInfo, clients ClientProvider) (*ComponentSyncObjects, error) {
+	options := componentOptions(ci)
+	upload, err := parseUploadOptions(options["partSizeMB"], options["concurrency"])
+	if err != nil {
+		return nil, err
+	}
+	workers, err := parseWorkers(options["workers"])
+	if err != nil {
+		return nil, err
+	}
+	object, err := parseObjectOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ComponentSyncObjects{
+		id:          ci.ID,
+		next:        ci.Next,
+		clients:     clients,
+		bucket:      options["bucket"],
+		region:      options["region"],
+		upload:      upload,
+		workers:     workers,
+		object:      object,
+		compare:     options["compare"],
+		source:      options["source"],
+		manifestKey: options["manifestKey"],
+		delete:      options["delete"] == "true",
+	}
+	if c.compare == "" {
+		c.compare = SyncCompareSizeMtime
+	}
+	if c.compare != SyncCompareSizeMtime && c.compare != SyncCompareHash {
+		return nil, fmt.Errorf("unsupported sync comparison %q", c.compare)
+	}
+	if c.source == "" {
+		c.source = SyncSourceManifest
+	}
+	if c.source != SyncSourceManifest && c.source != SyncSourceBucket {
+		return nil, fmt.Errorf("unsupported sync source %q", c.source)
+	}
+	if c.manifestKey == "" {
+		c.manifestKey = defaultSyncManifestKey
+	}
+	// the bucket listing covers the prefix, without one every object of the
+	// bucket which is not a local file would be deleted
+	if c.delete && c.source == SyncSourceBucket && c.object.prefix == "" {
+		return nil, fmt.Errorf("deleting objects with the bucket source needs a prefix")
+	}
+	return c, nil
+}
+
+func (c *ComponentSyncObjects) ID() string { return c.id }
+
+func (c *ComponentSyncObjects) Do(ctx context.Context, input interface{}) (output interface{}, err error) {
+	in, ok := input.(SyncObjectsInput)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast SyncObjectsInput")
+	}
+	return c.do(ctx, in)
+}
+
+func (c *ComponentSyncObjects) do(ctx context.Context, input SyncObjectsInput) (output SyncObjectsOutput, err error) {
+	if input.bucket == "" {
+		input.bucket, input.region = c.bucket, c.region
+	}
+	client, err := c.clients.S3(ctx, input.region)
+	if err != nil {
+		return SyncObjectsOutput{}, fmt.Errorf("failed to create s3 client: %v", err)
+	}
+
+	manifestKey := c.manifestKey
+	if c.object.prefix != "" {
+		manifestKey = c.object.prefix + "/" + manifestKey
+	}
+
+	now := time.Now()
+	locals := make([]localFile, 0, len(input.files))
+	for _, file := range input.files {
+		key, err := c.object.key(file, input.runID, now)
+		if err != nil {
+			return SyncObjectsOutput{}, err
+		}
+		local, err := c.statLocalFile(file, key)
+		if err != nil {
+			return SyncObjectsOutput{}, err
+		}
+		locals = append(locals, local)
+	}
+
+	var manifest syncManifest
+	var remote map[string]remoteObject
+	if c.source == SyncSourceManifest {
+		manifest, err = loadSyncManifest(ctx, client, input.bucket, manifestKey)
+	} else {
+		remote, err = listRemoteObjects(ctx, client, input.bucket, c.object.prefix)
+		delete(remote, manifestKey)
+		if err == nil && c.compare == SyncCompareHash {
+			err = c.readRemoteChecksums(ctx, client, input.bucket, locals, remote)
+		}
+	}
+	if err != nil {
+		return SyncObjectsOutput{}, err
+	}
+
+	pending := make([]localFile, 0)
+	seen := make(map[string]bool, len(locals))
+	for _, local := range locals {
+		seen[local.key] = true
+		var exists, unchanged bool
+		if c.source == SyncSourceManifest {
+			entry, ok := manifest.Objects[local.key]
+			exists, unchanged = ok, ok && c.matchManifest(local, entry)
+		} else {
+			object, ok := remote[local.key]
+			exists, unchanged = ok, ok && c.matchRemote(local, object)
+		}
+
+		switch {
+		case unchanged:
+			output.skipped++
+		case exists:
+			output.changed++
+			pending = append(pending, local)
+		default:
+			output.added++
+			pending = append(pending, local)
+		}
+	}
+
+	uploader := c.upload.newUploader(client)
+	objects := make([]UploadedObject, len(pending))
+	err = forEachConcurrently(ctx, c.workers, len(pending), func(ctx context.Context, i int) error {
+		var err error
+		objects[i], err = putFile(ctx, uploader, c.objectOptions(pending[i]), input.bucket, pending[i].file, pending[i].key)
+		return err
+	})
+	if err != nil {
+		return SyncObjectsOutput{}, err
+	}
+	output.objects = objects
+
+	if c.delete {
+		removed := make([]string, 0)
+		if c.source == SyncSourceManifest {
+			for key := range manifest.Objects {
+				if !seen[key] {
+					removed = append(removed, key)
+				}
+			}
+		} else {
+			for key := range remote {
+				if !seen[key] {
+					removed = append(removed, key)
+				}
+			}
+		}
+		if err := deleteObjects(ctx, client, input.bucket, removed); err != nil {
+			return SyncObjectsOutput{}, err
+		}
+		output.deleted = len(removed)
+	}
+
+	if c.source == SyncSourceManifest {
+		next := syncManifest{Objects: make(map[string]syncManifestEntry, len(locals))}
+		for _, local := range locals {
+			next.Objects[local.key] = syncManifestEntry{
+				File:    local.file,
+				Size:    local.size,
+				ModTime: local.modTime,
+				SHA256:  local.sha256,
+			}
+		}
+		// keep the entries of removed files that were not deleted, so that a
+		// later run with delete enabled still cleans them up
+		if !c.delete {
+			for key, entry := range manifest.Objects {
+				if !seen[key] {
+					next.Objects[key] = entry
+				}
+			}
+		}
+		if err := saveSyncManifest(ctx, client, input.bucket, manifestKey, next); err != nil {
+			return SyncObjectsOutput{}, err
+		}
+	}
+	return output, nil
+}
+
+// statLocalFile collects the state needed by the configured comparison.
+func (c *ComponentSyncObjects) statLocalFile(file string, key string) (localFile, error) {
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		return localFile{}, fmt.Errorf("error when stating file %s: %v", file, err)
+	}
+	local := localFile{
+		file:    file,
+		key:     key,
+		size:    fileInfo.Size(),
+		modTime: fileInfo.ModTime().UTC(),
+	}
+	if c.compare != SyncCompareHash {
+		return local, nil
+	}
+
+	body, err := os.Open(file)
+	if err != nil {
+		return localFile{}, fmt.Errorf("error when opening file %s: %v", file, err)
+	}
+	defer body.Close()
+
+	sha256Hash := sha256.New()
+	if _, err := io.Copy(sha256Hash, body); err != nil {
+		return localFile{}, fmt.Errorf("error when hashing file %s: %v", file, err)
+	}
+	local.sha256 = hexSum(sha256Hash)
+	return local, nil
+}
+
+// objectOptions returns the options of the object of a file, with its checksum
+// in the metadata when comparing hashes.
+func (c *ComponentSyncObjects) objectOptions(local localFile) objectOptions {
+	object := c.object
+	if c.compare != SyncCompareHash {
+		return object
+	}
+	object.metadata = make(map[string]string, len(c.object.metadata)+1)
+	for k, v := range c.object.metadata {
+		object.metadata[k] = v
+	}
+	object.metadata[syncChecksumMetadata] = local.sha256
+	return object
+}
+
+// readRemoteChecksums reads the checksums stored in the metadata of the objects
+// which have the size of their file. The ETag is not used since it is not the
+// MD5 of the content for multipart and SSE-KMS objects.
+func (c *ComponentSyncObjects) readRemoteChecksums(ctx context.Context, client S3API, bucket string, locals []localFile, remote map[string]remoteObject) error {
+	candidates := make([]string, 0, len(locals))
+	for _, local := range locals {
+		if object, ok := remote[local.key]; ok && object.size == local.size {
+			candidates = append(candidates, local.key)
+		}
+	}
+	checksums := make([]string, len(candidates))
+	err := forEachConcurrently(ctx, c.workers, len(candidates), func(ctx context.Context, i int) error {
+		out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(candidates[i]),
+		})
+		if err != nil {
+			return fmt.Errorf("error when getting object %s: %v", candidates[i], err)
+		}
+		checksums[i] = out.Metadata[syncChecksumMetadata]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, key := range candidates {
+		object := remote[key]
+		object.sha256 = checksums[i]
+		remote[key] = object
+	}
+	return nil
+}
+
+func (c *ComponentSyncObjects) matchManifest(local localFile, entry syncManifestEntry) bool {
+	if entry.Size != local.size {
+		return false
+	}
+	if c.compare == SyncCompareHash {
+		return entry.SHA256 != "" && entry.SHA256 == local.sha256
+	}
+	return entry.ModTime.Equal(local.modTime)
+}
+
+// matchRemote compares a file with an object in the bucket, by the checksum in
+// its metadata when comparing hashes. Objects uploaded without it are
+// considered changed.
+func (c *ComponentSyncObjects) matchRemote(local localFile, object remoteObject) bool {
+	if object.size != local.size {
+		return false
+	}
+	if c.compare == SyncCompareHash {
+		return object.sha256 != "" && object.sha256 == local.sha256
+	}
+	return !object.lastModified.Before(local.modTime)
+}
+
+func loadSyncManifest(ctx context.Context, client S3API, bucket string, key string) (syncManifest, error) {
+	manifest := syncManifest{Objects: make(map[string]syncManifestEntry)}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			// first run
+			return manifest, nil
+		}
+		return syncManifest{}, fmt.Errorf("error when getting sync manifest %s: %v", key, err)
+	}
+	defer out.Body.Close()
+
+	if err := json.NewDecoder(out.Body).Decode(&manifest); err != nil {
+		return syncManifest{}, fmt.Errorf("error when decoding sync manifest %s: %v", key, err)
+	}
+	if manifest.Objects == nil {
+		manifest.Objects = make(map[string]syncManifestEntry)
+	}
+	return manifest, nil
+}
+
+func saveSyncManifest(ctx context.Context, client S3API, bucket string, key string, manifest syncManifest) error {
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("error when saving sync manifest %s: %v", key, err)
+	}
+	return nil
+}
+
+func listRemoteObjects(ctx context.Context, client S3API, bucket string, prefix string) (map[string]remoteObject, error) {
+	objects := make(map[string]remoteObject)
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix + "/")
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error when listing objects of bucket %s: %v", bucket, err)
+		}
+		for _, object := range page.Contents {
+			objects[aws.ToString(object.Key)] = remoteObject{
+				size:         aws.ToInt64(object.Size),
+				lastModified: aws.ToTime(object.LastModified),
+			}
+		}
+	}
+	return objects, nil
+}
+
+func deleteObjects(ctx context.Context, client S3API, bucket string, keys []string) error {
+	identifiers := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		identifiers = append(identifiers, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+	return deleteObjectIdentifiers(ctx, client, bucket, identifiers)
+}
+
+// deleteObjectIdentifiers deletes objects, or object versions, in batches
+func deleteObjectIdentifiers(ctx context.Context, client S3API, bucket string, identifiers []types.ObjectIdentifier) error {
+	for start := 0; start < len(identifiers); start += deleteObjectsBatchSize {
+		end := start + deleteObjectsBatchSize
+		if end > len(identifiers) {
+			end = len(identifiers)
+		}
+
+		out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: identifiers[start:end], Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("error when deleting objects of bucket %s: %v", bucket, err)
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("error when deleting object %s: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
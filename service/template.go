@@ -0,0 +1,807 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Template is a project definition imported from a CloudFormation style
+// template, see ParseTemplate.
+type Template struct {
+	Description string           `json:"description,omitempty"`
+	Resources   []Resource       `json:"resources"`
+	Outputs     []TemplateOutput `json:"outputs"`
+	// Unsupported lists the parts of the template which were skipped
+	Unsupported []TemplateIssue `json:"unsupported"`
+}
+
+// TemplateOutput is an entry of the Outputs section, its value may reference
+// resource outputs, e.g. ${resources.backupBucket.arn}
+type TemplateOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value"`
+}
+
+// TemplateIssue reports a resource type, property or function of a template
+// which is not supported
+type TemplateIssue struct {
+	ResourceID string `json:"resourceId,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Message    string `json:"message"`
+}
+
+type ImportTemplateInput struct {
+	// ProjectID defaults to the name
+	ProjectID string `json:"projectId"`
+	Name      string `json:"name"`
+	// Template is a YAML or JSON document
+	Template   string            `json:"template"`
+	Parameters map[string]string `json:"parameters"`
+	// DryRun converts the template without creating the project
+	DryRun bool `json:"dryRun"`
+}
+
+type ImportTemplateOutput struct {
+	Project     CreateProjectInput `json:"project"`
+	DryRun      bool               `json:"dryRun"`
+	Outputs     []TemplateOutput   `json:"outputs"`
+	Unsupported []TemplateIssue    `json:"unsupported"`
+}
+
+// ImportTemplate creates a project from a CloudFormation style template. An
+// existing project is updated, which keeps the states of its resources, see
+// UpdateProject.
+func (rm *ResourceManager) ImportTemplate(ctx context.Context, input *ImportTemplateInput) (ImportTemplateOutput, error) {
+	template, err := ParseTemplate([]byte(input.Template), input.Parameters)
+	if err != nil {
+		return ImportTemplateOutput{}, err
+	}
+	projectID := input.ProjectID
+	if projectID == "" {
+		projectID = input.Name
+	}
+	if projectID == "" {
+		return ImportTemplateOutput{}, fmt.Errorf("a project id or a name is required")
+	}
+	name := input.Name
+	if name == "" {
+		name = template.Description
+	}
+	out := ImportTemplateOutput{
+		Project: CreateProjectInput{
+			ID:        projectID,
+			Name:      name,
+			Resources: template.Resources,
+		},
+		DryRun:      input.DryRun,
+		Outputs:     template.Outputs,
+		Unsupported: template.Unsupported,
+	}
+	if input.DryRun {
+		return out, nil
+	}
+	if _, ok := rm.project(projectID); ok {
+		err = rm.UpdateProject(ctx, &out.Project)
+	} else {
+		err = rm.CreateProject(ctx, &out.Project)
+	}
+	if err != nil {
+		return ImportTemplateOutput{}, err
+	}
+	return out, nil
+}
+
+// templateProperty converts the value of a template property into resource
+// properties
+type templateProperty func(value interface{}) (map[string]string, error)
+
+type templateType struct {
+	resourceType string
+	properties   map[string]templateProperty
+}
+
+// templateTypes maps the supported CloudFormation types onto resource types.
+// The resource types themselves, e.g. S3:Bucket, are also accepted, with their
+// own property names.
+var templateTypes = map[string]templateType{
+	"AWS::S3::Bucket": {
+		resourceType: "S3:Bucket",
+		properties: map[string]templateProperty{
+			"BucketName":              scalarProperty("BucketName"),
+			"VersioningConfiguration": fieldProperty("Versioning", "Status"),
+			"BucketEncryption":        bucketEncryptionProperty,
+			"LifecycleConfiguration":  lifecycleProperty,
+			"Tags":                    keyValuesProperty("Tags", "Key", "Value"),
+		},
+	},
+	"AWS::SQS::Queue": {
+		resourceType: "SQS:Queue",
+		properties: map[string]templateProperty{
+			"QueueName":              scalarProperty("QueueName"),
+			"FifoQueue":              scalarProperty("FifoQueue"),
+			"DelaySeconds":           scalarProperty("DelaySeconds"),
+			"MessageRetentionPeriod": scalarProperty("MessageRetentionPeriod"),
+			"VisibilityTimeout":      scalarProperty("VisibilityTimeout"),
+			"Tags":                   keyValuesProperty("Tags", "Key", "Value"),
+		},
+	},
+	"AWS::SNS::Topic": {
+		resourceType: "SNS:Topic",
+		properties: map[string]templateProperty{
+			"TopicName":   scalarProperty("TopicName"),
+			"DisplayName": scalarProperty("DisplayName"),
+			"FifoTopic":   scalarProperty("FifoTopic"),
+			"Tags":        keyValuesProperty("Tags", "Key", "Value"),
+		},
+	},
+	"AWS::CloudWatch::Alarm": {
+		resourceType: "CloudWatch:Alarm",
+		properties: map[string]templateProperty{
+			"AlarmName":          scalarProperty("AlarmName"),
+			"Namespace":          scalarProperty("Namespace"),
+			"MetricName":         scalarProperty("MetricName"),
+			"Statistic":          scalarProperty("Statistic"),
+			"Period":             scalarProperty("Period"),
+			"EvaluationPeriods":  scalarProperty("EvaluationPeriods"),
+			"Threshold":          scalarProperty("Threshold"),
+			"ComparisonOperator": scalarProperty("ComparisonOperator"),
+			"TreatMissingData":   scalarProperty("TreatMissingData"),
+			"AlarmActions":       listProperty("AlarmActions"),
+			"Dimensions":         keyValuesProperty("Dimensions", "Name", "Value"),
+		},
+	},
+}
+
+// subVariable matches the variables of Fn::Sub, ${!Literal} escapes a variable
+var subVariable = regexp.MustCompile(`\$\{(!?)([A-Za-z0-9_.:-]+)\}`)
+
+// ParseTemplate reads a YAML or JSON template with Parameters, Resources and
+// Outputs sections. Parameter values are taken from parameters, then from
+// their defaults. Ref and Fn::GetAtt of resources become resource references,
+// Fn::Sub and Fn::Join are evaluated. Unsupported resource types, properties
+// and functions are skipped and reported.
+func ParseTemplate(data []byte, parameters map[string]string) (Template, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return Template{}, fmt.Errorf("error when parsing template: %v", err)
+	}
+	if len(document.Content) == 0 {
+		return Template{}, fmt.Errorf("template is empty")
+	}
+	root := document.Content[0]
+	value, err := templateValue(root)
+	if err != nil {
+		return Template{}, err
+	}
+	sections, ok := value.(map[string]interface{})
+	if !ok {
+		return Template{}, fmt.Errorf("template must be a mapping")
+	}
+
+	t := &templateParser{
+		template: Template{
+			Resources:   make([]Resource, 0),
+			Outputs:     make([]TemplateOutput, 0),
+			Unsupported: make([]TemplateIssue, 0),
+		},
+		resources: make(map[string]string),
+	}
+	t.template.Description, _ = sections["Description"].(string)
+	for _, name := range []string{"Conditions", "Mappings", "Transform", "Rules"} {
+		if _, ok := sections[name]; ok {
+			t.issue("", "", fmt.Sprintf("section %s is not supported", name))
+		}
+	}
+	if err := t.parseParameters(sections["Parameters"], parameters); err != nil {
+		return Template{}, err
+	}
+
+	resources, ok := sections["Resources"].(map[string]interface{})
+	if !ok || len(resources) == 0 {
+		return Template{}, fmt.Errorf("template has no resources")
+	}
+	// resources keep the order of the template
+	order := mappingKeys(root, "Resources")
+	for _, id := range order {
+		definition, _ := resources[id].(map[string]interface{})
+		t.resources[id], _ = definition["Type"].(string)
+	}
+	for _, id := range order {
+		if err := t.parseResource(id, resources[id]); err != nil {
+			return Template{}, err
+		}
+	}
+
+	outputs, _ := sections["Outputs"].(map[string]interface{})
+	for _, name := range mappingKeys(root, "Outputs") {
+		t.parseOutput(name, outputs[name])
+	}
+	return t.template, nil
+}
+
+type templateParser struct {
+	template   Template
+	parameters map[string]string
+	// resources maps the logical IDs to the template types
+	resources map[string]string
+}
+
+func (t *templateParser) issue(resourceID string, resourceType string, message string) {
+	t.template.Unsupported = append(t.template.Unsupported, TemplateIssue{
+		ResourceID: resourceID,
+		Type:       resourceType,
+		Message:    message,
+	})
+}
+
+func (t *templateParser) parseParameters(section interface{}, values map[string]string) error {
+	declared, _ := section.(map[string]interface{})
+	t.parameters = make(map[string]string, len(declared))
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	for _, name := range sortedMapKeys(declared) {
+		parameter, _ := declared[name].(map[string]interface{})
+		value, ok := values[name]
+		if !ok {
+			def, hasDefault := parameter["Default"]
+			if !hasDefault {
+				return fmt.Errorf("parameter %s requires a value", name)
+			}
+			if value, ok = scalarString(def); !ok {
+				return fmt.Errorf("default of parameter %s must be a scalar", name)
+			}
+		}
+		if allowed, ok := parameter["AllowedValues"].([]interface{}); ok {
+			found := false
+			for _, a := range allowed {
+				if s, _ := scalarString(a); s == value {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("value %q of parameter %s is not allowed", value, name)
+			}
+		}
+		t.parameters[name] = value
+	}
+	return nil
+}
+
+func (t *templateParser) parseResource(id string, value interface{}) error {
+	definition, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("resource %s must be a mapping", id)
+	}
+	typeName, _ := definition["Type"].(string)
+	if typeName == "" {
+		return fmt.Errorf("resource %s has no type", id)
+	}
+	if _, ok := definition["Condition"]; ok {
+		t.issue(id, typeName, "conditions are not supported, the resource is always created")
+	}
+
+	r := Resource{
+		ID:         id,
+		Properties: make(map[string]string),
+	}
+	properties, _ := definition["Properties"].(map[string]interface{})
+	if mapped, ok := templateTypes[typeName]; ok {
+		r.Type = mapped.resourceType
+		for _, name := range sortedMapKeys(properties) {
+			convert, ok := mapped.properties[name]
+			if !ok {
+				t.issue(id, typeName, fmt.Sprintf("property %s is not supported", name))
+				continue
+			}
+			value, err := t.evaluate(properties[name])
+			if err != nil {
+				t.issue(id, typeName, fmt.Sprintf("property %s: %v", name, err))
+				continue
+			}
+			converted, err := convert(value)
+			if err != nil {
+				t.issue(id, typeName, fmt.Sprintf("property %s: %v", name, err))
+				continue
+			}
+			for k, v := range converted {
+				r.Properties[k] = v
+			}
+		}
+	} else if _, ok := resourceSpecs[typeName]; ok {
+		r.Type = typeName
+		for _, name := range sortedMapKeys(properties) {
+			value, err := t.evaluate(properties[name])
+			if err == nil {
+				r.Properties[name], err = templateString(value)
+			}
+			if err != nil {
+				t.issue(id, typeName, fmt.Sprintf("property %s: %v", name, err))
+			}
+		}
+	} else {
+		t.issue(id, typeName, "resource type is not supported")
+		return nil
+	}
+
+	switch dependsOn := definition["DependsOn"].(type) {
+	case string:
+		r.DependsOn = []string{dependsOn}
+	case []interface{}:
+		for _, d := range dependsOn {
+			if s, ok := d.(string); ok {
+				r.DependsOn = append(r.DependsOn, s)
+			}
+		}
+	}
+	// dependencies on skipped resources cannot be kept
+	dependsOn := make([]string, 0, len(r.DependsOn))
+	for _, d := range r.DependsOn {
+		if t.supported(d) {
+			dependsOn = append(dependsOn, d)
+		}
+	}
+	if len(dependsOn) > 0 {
+		r.DependsOn = dependsOn
+	} else {
+		r.DependsOn = nil
+	}
+
+	switch policy, _ := definition["DeletionPolicy"].(string); policy {
+	case "":
+	case string(DeletionPolicyDelete), string(DeletionPolicyRetain):
+		r.DeletionPolicy = DeletionPolicy(policy)
+	default:
+		t.issue(id, typeName, fmt.Sprintf("deletion policy %s is not supported, the resource is deleted", policy))
+	}
+
+	t.template.Resources = append(t.template.Resources, r)
+	return nil
+}
+
+func (t *templateParser) parseOutput(name string, value interface{}) {
+	definition, _ := value.(map[string]interface{})
+	output := TemplateOutput{Name: name}
+	output.Description, _ = definition["Description"].(string)
+	evaluated, err := t.evaluate(definition["Value"])
+	if err == nil {
+		output.Value, err = templateString(evaluated)
+	}
+	if err != nil {
+		t.issue("", "", fmt.Sprintf("output %s: %v", name, err))
+		return
+	}
+	t.template.Outputs = append(t.template.Outputs, output)
+}
+
+// supported tells whether a logical ID is a resource imported by the template
+func (t *templateParser) supported(id string) bool {
+	typeName, ok := t.resources[id]
+	if !ok {
+		return false
+	}
+	if _, ok := templateTypes[typeName]; ok {
+		return true
+	}
+	_, ok = resourceSpecs[typeName]
+	return ok
+}
+
+// evaluate replaces the intrinsic functions of a value
+func (t *templateParser) evaluate(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for name, arg := range v {
+				if name == "Ref" || strings.HasPrefix(name, "Fn::") {
+					return t.function(name, arg)
+				}
+			}
+		}
+		evaluated := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			e, err := t.evaluate(item)
+			if err != nil {
+				return nil, err
+			}
+			evaluated[k] = e
+		}
+		return evaluated, nil
+	case []interface{}:
+		evaluated := make([]interface{}, len(v))
+		for i, item := range v {
+			e, err := t.evaluate(item)
+			if err != nil {
+				return nil, err
+			}
+			evaluated[i] = e
+		}
+		return evaluated, nil
+	default:
+		return value, nil
+	}
+}
+
+func (t *templateParser) function(name string, arg interface{}) (interface{}, error) {
+	switch name {
+	case "Ref":
+		target, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("Ref expects a name")
+		}
+		return t.ref(target)
+	case "Fn::GetAtt":
+		var target, attribute string
+		switch a := arg.(type) {
+		case string:
+			target, attribute, _ = strings.Cut(a, ".")
+		case []interface{}:
+			if len(a) == 2 {
+				target, _ = a[0].(string)
+				attribute, _ = a[1].(string)
+			}
+		}
+		if target == "" || attribute == "" {
+			return nil, fmt.Errorf("Fn::GetAtt expects a resource and an attribute")
+		}
+		return t.getAtt(target, attribute)
+	case "Fn::Sub":
+		return t.sub(arg)
+	case "Fn::Join":
+		a, ok := arg.([]interface{})
+		if !ok || len(a) != 2 {
+			return nil, fmt.Errorf("Fn::Join expects a delimiter and a list")
+		}
+		delimiter, _ := a[0].(string)
+		items, err := t.evaluate(a[1])
+		if err != nil {
+			return nil, err
+		}
+		list, ok := items.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Fn::Join expects a list")
+		}
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := scalarString(item)
+			if !ok {
+				return nil, fmt.Errorf("Fn::Join expects a list of scalars")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, delimiter), nil
+	default:
+		return nil, fmt.Errorf("function %s is not supported", name)
+	}
+}
+
+// ref returns the value of a parameter, or a reference to the identifier of a
+// resource, which is what Ref returns for the supported types.
+func (t *templateParser) ref(target string) (string, error) {
+	if value, ok := t.parameters[target]; ok {
+		return value, nil
+	}
+	if t.supported(target) {
+		return fmt.Sprintf("${resources.%s.%s}", target, ResourceOutputID), nil
+	}
+	if strings.HasPrefix(target, "AWS::") {
+		return "", fmt.Errorf("pseudo parameter %s is not supported", target)
+	}
+	return "", fmt.Errorf("unknown parameter or resource %s", target)
+}
+
+func (t *templateParser) getAtt(target string, attribute string) (string, error) {
+	if !t.supported(target) {
+		return "", fmt.Errorf("unknown resource %s", target)
+	}
+	switch attribute {
+	case "Arn", "TopicArn":
+		return fmt.Sprintf("${resources.%s.%s}", target, ResourceOutputARN), nil
+	case "QueueName", "TopicName":
+		return fmt.Sprintf("${resources.%s.%s}", target, ResourceOutputName), nil
+	case "QueueUrl":
+		return fmt.Sprintf("${resources.%s.%s}", target, ResourceOutputID), nil
+	default:
+		return "", fmt.Errorf("attribute %s of %s is not supported", attribute, target)
+	}
+}
+
+func (t *templateParser) sub(arg interface{}) (string, error) {
+	var format string
+	variables := make(map[string]string)
+	switch a := arg.(type) {
+	case string:
+		format = a
+	case []interface{}:
+		if len(a) != 2 {
+			return "", fmt.Errorf("Fn::Sub expects a string and a mapping")
+		}
+		format, _ = a[0].(string)
+		values, _ := a[1].(map[string]interface{})
+		for name, value := range values {
+			evaluated, err := t.evaluate(value)
+			if err != nil {
+				return "", err
+			}
+			s, ok := scalarString(evaluated)
+			if !ok {
+				return "", fmt.Errorf("variable %s of Fn::Sub must be a scalar", name)
+			}
+			variables[name] = s
+		}
+	default:
+		return "", fmt.Errorf("Fn::Sub expects a string")
+	}
+
+	var err error
+	result := subVariable.ReplaceAllStringFunc(format, func(match string) string {
+		m := subVariable.FindStringSubmatch(match)
+		if m[1] == "!" {
+			return "${" + m[2] + "}"
+		}
+		if value, ok := variables[m[2]]; ok {
+			return value
+		}
+		var value string
+		var e error
+		if target, attribute, ok := strings.Cut(m[2], "."); ok {
+			value, e = t.getAtt(target, attribute)
+		} else {
+			value, e = t.ref(m[2])
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+func scalarProperty(name string) templateProperty {
+	return func(value interface{}) (map[string]string, error) {
+		s, ok := scalarString(value)
+		if !ok {
+			return nil, fmt.Errorf("expected a scalar")
+		}
+		return map[string]string{name: s}, nil
+	}
+}
+
+// fieldProperty reads a field of a nested property, e.g. the Status of the
+// VersioningConfiguration
+func fieldProperty(name string, field string) templateProperty {
+	return func(value interface{}) (map[string]string, error) {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a mapping")
+		}
+		s, ok := scalarString(m[field])
+		if !ok {
+			return nil, fmt.Errorf("expected a scalar %s", field)
+		}
+		return map[string]string{name: s}, nil
+	}
+}
+
+func listProperty(name string) templateProperty {
+	return func(value interface{}) (map[string]string, error) {
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list")
+		}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := scalarString(item)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of scalars")
+			}
+			items = append(items, s)
+		}
+		return map[string]string{name: strings.Join(items, ",")}, nil
+	}
+}
+
+// keyValuesProperty converts a list of key and value mappings, e.g. the
+// Tags or the Dimensions, into key=value pairs
+func keyValuesProperty(name string, keyField string, valueField string) templateProperty {
+	return func(value interface{}) (map[string]string, error) {
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list")
+		}
+		values := make(map[string]string, len(list))
+		for _, item := range list {
+			m, _ := item.(map[string]interface{})
+			k, okKey := scalarString(m[keyField])
+			v, okValue := scalarString(m[valueField])
+			if !okKey || !okValue {
+				return nil, fmt.Errorf("expected %s and %s", keyField, valueField)
+			}
+			values[k] = v
+		}
+		return map[string]string{name: formatKeyValues(values)}, nil
+	}
+}
+
+func bucketEncryptionProperty(value interface{}) (map[string]string, error) {
+	m, _ := value.(map[string]interface{})
+	rules, _ := m["ServerSideEncryptionConfiguration"].([]interface{})
+	if len(rules) != 1 {
+		return nil, fmt.Errorf("expected one server side encryption rule")
+	}
+	rule, _ := rules[0].(map[string]interface{})
+	byDefault, _ := rule["ServerSideEncryptionByDefault"].(map[string]interface{})
+	algorithm, ok := scalarString(byDefault["SSEAlgorithm"])
+	if !ok {
+		return nil, fmt.Errorf("expected an SSEAlgorithm")
+	}
+	properties := map[string]string{"Encryption": algorithm}
+	if key, ok := scalarString(byDefault["KMSMasterKeyID"]); ok && key != "" {
+		properties["KMSKeyId"] = key
+	}
+	return properties, nil
+}
+
+func lifecycleProperty(value interface{}) (map[string]string, error) {
+	m, _ := value.(map[string]interface{})
+	rules, ok := m["Rules"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected Rules")
+	}
+	lifecycleRules := make([]bucketLifecycleRule, 0, len(rules))
+	for _, item := range rules {
+		rule, _ := item.(map[string]interface{})
+		r := bucketLifecycleRule{}
+		r.ID, _ = scalarString(rule["Id"])
+		r.Prefix, _ = scalarString(rule["Prefix"])
+		if status, _ := scalarString(rule["Status"]); status == "Disabled" {
+			r.Disabled = true
+		}
+		r.ExpirationDays = int32Field(rule, "ExpirationInDays")
+		if transitions, ok := rule["Transitions"].([]interface{}); ok && len(transitions) > 0 {
+			if len(transitions) > 1 {
+				return nil, fmt.Errorf("only one transition is supported per rule")
+			}
+			transition, _ := transitions[0].(map[string]interface{})
+			r.TransitionDays = int32Field(transition, "TransitionInDays")
+			r.TransitionStorageClass, _ = scalarString(transition["StorageClass"])
+		}
+		if expiration, ok := rule["NoncurrentVersionExpiration"].(map[string]interface{}); ok {
+			r.NoncurrentVersionExpirationDays = int32Field(expiration, "NoncurrentDays")
+		}
+		if abort, ok := rule["AbortIncompleteMultipartUpload"].(map[string]interface{}); ok {
+			r.AbortIncompleteMultipartUploadDays = int32Field(abort, "DaysAfterInitiation")
+		}
+		lifecycleRules = append(lifecycleRules, r)
+	}
+	b, err := json.Marshal(lifecycleRules)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"LifecycleRules": string(b)}, nil
+}
+
+func int32Field(m map[string]interface{}, name string) int32 {
+	s, _ := scalarString(m[name])
+	n, _ := strconv.Atoi(s)
+	return int32(n)
+}
+
+// templateString converts a property value, mappings and lists are encoded
+// as JSON documents, e.g. the LifecycleRules or a Policy
+func templateString(value interface{}) (string, error) {
+	if s, ok := scalarString(value); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error when encoding value: %v", err)
+	}
+	return string(b), nil
+}
+
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// templateValue decodes a YAML node, the short form of the intrinsic
+// functions, e.g. !Ref or !GetAtt, is converted into the long form.
+func templateValue(node *yaml.Node) (interface{}, error) {
+	var value interface{}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return templateValue(node.Content[0])
+	case yaml.AliasNode:
+		return templateValue(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := templateValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[node.Content[i].Value] = v
+		}
+		value = m
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := templateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		value = list
+	default:
+		if strings.HasPrefix(node.Tag, "!!") {
+			if err := node.Decode(&value); err != nil {
+				return nil, fmt.Errorf("error when decoding line %d: %v", node.Line, err)
+			}
+		} else {
+			value = node.Value
+		}
+	}
+
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		name := "Fn::" + node.Tag[1:]
+		if node.Tag == "!Ref" {
+			name = "Ref"
+		}
+		return map[string]interface{}{name: value}, nil
+	}
+	return value, nil
+}
+
+// mappingKeys returns the keys of a section of the template in their order
+func mappingKeys(root *yaml.Node, section string) []string {
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != section || root.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		keys := make([]string, 0)
+		for j := 0; j+1 < len(root.Content[i+1].Content); j += 2 {
+			keys = append(keys, root.Content[i+1].Content[j].Value)
+		}
+		return keys
+	}
+	return nil
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
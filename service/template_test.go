@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const backupTemplate = `
+AWSTemplateFormatVersion: "2010-09-09"
+Description: Backup resources
+Parameters:
+  Environment:
+    Type: String
+    AllowedValues: [dev, prod]
+  RetentionDays:
+    Type: Number
+    Default: 30
+Resources:
+  BackupBucket:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: Retain
+    Properties:
+      BucketName: !Sub "backup-${Environment}"
+      VersioningConfiguration:
+        Status: Enabled
+      BucketEncryption:
+        ServerSideEncryptionConfiguration:
+          - ServerSideEncryptionByDefault:
+              SSEAlgorithm: AES256
+      LifecycleConfiguration:
+        Rules:
+          - Id: expire
+            Status: Enabled
+            ExpirationInDays: !Ref RetentionDays
+      Tags:
+        - Key: env
+          Value: !Ref Environment
+      AccelerateConfiguration:
+        AccelerationStatus: Enabled
+  BackupQueue:
+    Type: AWS::SQS::Queue
+    DependsOn: [BackupBucket, BackupTable]
+    Properties:
+      QueueName: !Join ["-", [backup, !Ref Environment]]
+      VisibilityTimeout: 60
+  QueueAlarm:
+    Type: AWS::CloudWatch::Alarm
+    Properties:
+      AlarmName: backlog
+      Namespace: AWS/SQS
+      MetricName: ApproximateNumberOfMessagesVisible
+      Statistic: Maximum
+      Period: 300
+      EvaluationPeriods: 1
+      Threshold: 100
+      ComparisonOperator: GreaterThanThreshold
+      Dimensions:
+        - Name: QueueName
+          Value: !GetAtt BackupQueue.QueueName
+  BackupTable:
+    Type: AWS::DynamoDB::Table
+Outputs:
+  BucketArn:
+    Description: The backup bucket
+    Value: !GetAtt [BackupBucket, Arn]
+  QueueUrl:
+    Value: !Ref BackupQueue
+`
+
+func TestParseTemplate(t *testing.T) {
+	template, err := ParseTemplate([]byte(backupTemplate), map[string]string{"Environment": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Resource{
+		{
+			ID:   "BackupBucket",
+			Type: "S3:Bucket",
+			Properties: map[string]string{
+				"BucketName":     "backup-prod",
+				"Versioning":     "Enabled",
+				"Encryption":     "AES256",
+				"LifecycleRules": `[{"id":"expire","prefix":"","disabled":false,"expirationDays":30,"transitionDays":0,"transitionStorageClass":"","noncurrentVersionExpirationDays":0,"abortIncompleteMultipartUploadDays":0}]`,
+				"Tags":           "env=prod",
+			},
+			DeletionPolicy: DeletionPolicyRetain,
+		},
+		{
+			ID:         "BackupQueue",
+			Type:       "SQS:Queue",
+			Properties: map[string]string{"QueueName": "backup-prod", "VisibilityTimeout": "60"},
+			DependsOn:  []string{"BackupBucket"},
+		},
+		{
+			ID:   "QueueAlarm",
+			Type: "CloudWatch:Alarm",
+			Properties: map[string]string{
+				"AlarmName":          "backlog",
+				"Namespace":          "AWS/SQS",
+				"MetricName":         "ApproximateNumberOfMessagesVisible",
+				"Statistic":          "Maximum",
+				"Period":             "300",
+				"EvaluationPeriods":  "1",
+				"Threshold":          "100",
+				"ComparisonOperator": "GreaterThanThreshold",
+				"Dimensions":         "QueueName=${resources.BackupQueue.name}",
+			},
+		},
+	}
+	if !reflect.DeepEqual(template.Resources, want) {
+		t.Fatalf("Expected resources %+v, got %+v", want, template.Resources)
+	}
+
+	wantOutputs := []TemplateOutput{
+		{Name: "BucketArn", Description: "The backup bucket", Value: "${resources.BackupBucket.arn}"},
+		{Name: "QueueUrl", Value: "${resources.BackupQueue.id}"},
+	}
+	if !reflect.DeepEqual(template.Outputs, wantOutputs) {
+		t.Fatalf("Expected outputs %+v, got %+v", wantOutputs, template.Outputs)
+	}
+
+	wantUnsupported := []TemplateIssue{
+		{ResourceID: "BackupBucket", Type: "AWS::S3::Bucket", Message: "property AccelerateConfiguration is not supported"},
+		{ResourceID: "BackupTable", Type: "AWS::DynamoDB::Table", Message: "resource type is not supported"},
+	}
+	if !reflect.DeepEqual(template.Unsupported, wantUnsupported) {
+		t.Fatalf("Expected unsupported %+v, got %+v", wantUnsupported, template.Unsupported)
+	}
+}
+
+func TestParseTemplateParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		err        string
+	}{
+		{name: "missing", parameters: nil, err: "parameter Environment requires a value"},
+		{name: "not allowed", parameters: map[string]string{"Environment": "test"}, err: `value "test" of parameter Environment is not allowed`},
+		{name: "unknown", parameters: map[string]string{"Environment": "dev", "Owner": "ops"}, err: "unknown parameter Owner"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseTemplate([]byte(backupTemplate), test.parameters)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestImportTemplate(t *testing.T) {
+	rm := &ResourceManager{Clients: NewFakeClientProvider()}
+	// JSON templates and the resource types themselves are accepted as well
+	input := &ImportTemplateInput{
+		ProjectID: "project-001",
+		Template: `{
+			"Resources": {
+				"Logs": {"Type": "S3:Bucket", "Properties": {"BucketName": "logs", "Policy": {"Statement": []}}},
+				"Alerts": {"Type": "AWS::SNS::Topic", "Properties": {"TopicName": {"Fn::Sub": "${Logs}-alerts"}}}
+			}
+		}`,
+		DryRun: true,
+	}
+	out, err := rm.ImportTemplate(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Project.Resources) != 2 || out.Project.Resources[0].Properties["Policy"] != `{"Statement":[]}` {
+		t.Fatalf("Expected the policy to be encoded as JSON, got %+v", out.Project.Resources)
+	}
+	if topic := out.Project.Resources[1].Properties["TopicName"]; topic != "${resources.Logs.id}-alerts" {
+		t.Fatalf("Expected the topic name to reference the bucket, got %s", topic)
+	}
+	if _, err := rm.GetProject(context.Background(), "project-001"); err == nil {
+		t.Fatalf("Expected no project to be created on a dry run")
+	}
+
+	input.DryRun = false
+	if _, err := rm.ImportTemplate(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	name, err := rm.ResourceOutput(context.Background(), "project-001", "Alerts", ResourceOutputName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "logs-alerts" {
+		t.Fatalf("Expected topic logs-alerts, got %s", name)
+	}
+
+	// importing again updates the project and keeps the provisioned states,
+	// the provisioned resources cannot be removed
+	if _, err := rm.ImportTemplate(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := rm.ResourceOutput(context.Background(), "project-001", "Alerts", ResourceOutputName); err != nil || name != "logs-alerts" {
+		t.Fatalf("Expected the state of the topic to be kept, got %q, %v", name, err)
+	}
+	input.Template = `{"Resources": {"Logs": {"Type": "S3:Bucket", "Properties": {"BucketName": "logs"}}}}`
+	if _, err := rm.ImportTemplate(context.Background(), input); err == nil || !strings.Contains(err.Error(), "Alerts") {
+		t.Fatalf("Expected the provisioned topic not to be removed, got %v", err)
+	}
+
+	input.ProjectID = ""
+	if _, err := rm.ImportTemplate(context.Background(), input); err == nil {
+		t.Fatalf("Expected a template without a project id or a name to be rejected")
+	}
+}
@@ -4,6 +4,9 @@ type ResourceType string
 
 const (
 	Bucket ResourceType = "s3:bucket"
+	Queue  ResourceType = "sqs:queue"
+	Topic  ResourceType = "sns:topic"
+	Alarm  ResourceType = "cloudwatch:alarm"
 )
 
 type Status string
@@ -13,10 +16,34 @@ const (
 	StatusDeactive Status = "deactive"
 )
 
+// ResourceStatus is the provisioning status of a project resource
+type ResourceStatus string
+
+const (
+	ResourceStatusPending     ResourceStatus = "pending"
+	ResourceStatusProvisioned ResourceStatus = "provisioned"
+	ResourceStatusFailed      ResourceStatus = "failed"
+	ResourceStatusDeleted     ResourceStatus = "deleted"
+	// ResourceStatusInterrupted is the status of a resource whose
+	// provisioning was canceled, e.g. by a shutdown of the server
+	ResourceStatusInterrupted ResourceStatus = "interrupted"
+)
+
+// DeletionPolicy tells whether a resource is deleted with its project
+type DeletionPolicy string
+
+const (
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain protects a resource from DestroyProjectResources
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
 type ComponentType string
 
 const (
-	ComponentTypePutObject ComponentType = "s3:putObject"
+	ComponentTypePutObject    ComponentType = "s3:putObject"
+	ComponentTypeZipPutObject ComponentType = "s3:zipPutObject"
+	ComponentTypeSyncObjects  ComponentType = "s3:sync"
 )
 
 type WorkflowTriggerType string
@@ -24,3 +51,12 @@ type WorkflowTriggerType string
 const (
 	WorkflowTriggerTypeScheduled WorkflowTriggerType = "scheduled"
 )
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatZip    ArchiveFormat = "zip"
+	ArchiveFormatTar    ArchiveFormat = "tar"
+	ArchiveFormatTarGz  ArchiveFormat = "tar.gz"
+	ArchiveFormatTarZst ArchiveFormat = "tar.zst"
+)
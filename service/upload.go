@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// uploadOptions configures how objects are uploaded. Bodies larger than
+// partSize are sent as a multipart upload with up to concurrency parts in
+// flight, so at most partSize*concurrency bytes are buffered per upload.
+type uploadOptions struct {
+	partSize    int64
+	concurrency int
+}
+
+// parseUploadOptions validates the upload step options. The part size is given
+// in MiB, empty values fall back to the uploader defaults.
+func parseUploadOptions(partSizeMB, concurrency string) (uploadOptions, error) {
+	opts := uploadOptions{
+		partSize:    manager.DefaultUploadPartSize,
+		concurrency: manager.DefaultUploadConcurrency,
+	}
+
+	if partSizeMB != "" {
+		size, err := strconv.ParseInt(partSizeMB, 10, 64)
+		if err != nil {
+			return uploadOptions{}, fmt.Errorf("invalid part size %q: %v", partSizeMB, err)
+		}
+		opts.partSize = size * 1024 * 1024
+		if opts.partSize < manager.MinUploadPartSize {
+			return uploadOptions{}, fmt.Errorf("part size must be at least %d MiB", manager.MinUploadPartSize/1024/1024)
+		}
+	}
+
+	if concurrency != "" {
+		n, err := strconv.Atoi(concurrency)
+		if err != nil {
+			return uploadOptions{}, fmt.Errorf("invalid upload concurrency %q: %v", concurrency, err)
+		}
+		if n < 1 {
+			return uploadOptions{}, fmt.Errorf("upload concurrency must be positive")
+		}
+		opts.concurrency = n
+	}
+	return opts, nil
+}
+
+// maxStreamingParts is the number of parts of an upload of unknown size
+var maxStreamingParts = manager.MaxUploadParts
+
+// withMaxSize raises the part size so that an upload of unknown size, up to
+// maxSizeGB GiB, fits in maxStreamingParts parts
+func (o uploadOptions) withMaxSize(maxSizeGB string) (uploadOptions, error) {
+	if maxSizeGB == "" {
+		return o, nil
+	}
+	size, err := strconv.ParseInt(maxSizeGB, 10, 64)
+	if err != nil || size < 1 {
+		return uploadOptions{}, fmt.Errorf("invalid maximum size %q, expected a positive number of GiB", maxSizeGB)
+	}
+	// parts are a whole number of MiB
+	const mib = 1024 * 1024
+	parts := int64(maxStreamingParts)
+	partSize := (size*1024*mib + parts*mib - 1) / (parts * mib) * mib
+	if o.partSize == 0 {
+		o.partSize = manager.DefaultUploadPartSize
+	}
+	if partSize > o.partSize {
+		o.partSize = partSize
+	}
+	return o, nil
+}
+
+// sizeLimitReader fails the reads beyond max bytes
+type sizeLimitReader struct {
+	r        io.Reader
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		l.exceeded = true
+		return n, fmt.Errorf("more than %d bytes", l.max)
+	}
+	return n, err
+}
+
+func (o uploadOptions) newUploader(client manager.UploadAPIClient) *manager.Uploader {
+	return manager.NewUploader(client, func(u *manager.Uploader) {
+		if o.partSize > 0 {
+			u.PartSize = o.partSize
+		}
+		if o.concurrency > 0 {
+			u.Concurrency = o.concurrency
+		}
+	})
+}
+
+// parseWorkers validates the number of files uploaded concurrently, which
+// defaults to one.
+func parseWorkers(workers string) (int, error) {
+	if workers == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(workers)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number of upload workers %q: %v", workers, err)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("number of upload workers must be positive")
+	}
+	return n, nil
+}
+
+// forEachConcurrently calls fn for the indexes 0 to n-1 using the given number
+// of workers. The first error cancels the context passed to the other calls
+// and stops handing out the remaining indexes, it is the error returned since
+// the calls it cancels fail afterwards.
+func forEachConcurrently(ctx context.Context, workers int, n int, fn func(ctx context.Context, i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var firstErr error
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
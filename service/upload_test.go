@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEachConcurrently(t *testing.T) {
+	var running, maxRunning int32
+	err := forEachConcurrently(context.Background(), 3, 20, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 3 {
+		t.Fatalf("Expected at most 3 calls at the same time, got %d", maxRunning)
+	}
+}
+
+func TestForEachConcurrentlyFirstError(t *testing.T) {
+	// the last call fails while the others wait, they then fail with the
+	// cancel of the context
+	cause := errors.New("upload 5 failed")
+	started := make(chan struct{}, 5)
+	err := forEachConcurrently(context.Background(), 6, 6, func(ctx context.Context, i int) error {
+		if i < 5 {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		for j := 0; j < 5; j++ {
+			<-started
+		}
+		return cause
+	})
+	if err != cause {
+		t.Fatalf("Expected the error of the failed upload, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := forEachConcurrently(ctx, 2, 4, func(ctx context.Context, i int) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the cancel of the context, got %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DefinitionFormatYAML = "yaml"
+	DefinitionFormatJSON = "json"
+)
+
+// WorkflowDefinition is the file format of a workflow, the fields of
+// CreateWorkflowInput with the triggers of the workflow. Definition files are
+// YAML or JSON documents, e.g.
+//
+//	id: workflow_backup
+//	name: Backup Service
+//	input:
+//	  dir: /data
+//	steps:
+//	  - id: upload
+//	    type: S3:PutObject
+//	    input:
+//	      bucket: ${resources.backupBucket.name}
+//	triggers:
+//	  - id: nightly
+//	    type: scheduled
+//	    config:
+//	      cron: "0 2 * * *"
+type WorkflowDefinition struct {
+	CreateWorkflowInput
+	// Triggers default to the workflow of the definition
+	Triggers []CreateWorkflowTriggerInput `json:"triggers,omitempty"`
+}
+
+// WorkflowInputs, Variables and WorkflowOutputs accept a list of entries,
+// [{"name": "dir", "type": "string"}], or a map keyed by name,
+// {"dir": {"type": "string"}}. A string in the map is the default value of an
+// input or a variable, and the type of an output.
+type WorkflowInputs []WorkflowInput
+
+type Variables []Variable
+
+type WorkflowOutputs []WorkflowOutput
+
+func (l *WorkflowInputs) UnmarshalJSON(data []byte) error {
+	list, err := decodeNamedList(data, func(name string, raw json.RawMessage) (WorkflowInput, error) {
+		entry := WorkflowInput{}
+		if value, ok := namedListString(raw); ok {
+			entry.Type, entry.DefaultValue = "string", value
+		} else if err := json.Unmarshal(raw, &entry); err != nil {
+			return WorkflowInput{}, fmt.Errorf("invalid input %s: %v", name, err)
+		}
+		entry.Name = name
+		return entry, nil
+	})
+	*l = list
+	return err
+}
+
+func (l *Variables) UnmarshalJSON(data []byte) error {
+	list, err := decodeNamedList(data, func(name string, raw json.RawMessage) (Variable, error) {
+		entry := Variable{}
+		if value, ok := namedListString(raw); ok {
+			entry.Type, entry.DefaultValue = "string", value
+		} else if err := json.Unmarshal(raw, &entry); err != nil {
+			return Variable{}, fmt.Errorf("invalid variable %s: %v", name, err)
+		}
+		entry.Name = name
+		return entry, nil
+	})
+	*l = list
+	return err
+}
+
+func (l *WorkflowOutputs) UnmarshalJSON(data []byte) error {
+	list, err := decodeNamedList(data, func(name string, raw json.RawMessage) (WorkflowOutput, error) {
+		entry := WorkflowOutput{}
+		if value, ok := namedListString(raw); ok {
+			entry.Type = value
+		} else if err := json.Unmarshal(raw, &entry); err != nil {
+			return WorkflowOutput{}, fmt.Errorf("invalid output %s: %v", name, err)
+		}
+		entry.Name = name
+		return entry, nil
+	})
+	*l = list
+	return err
+}
+
+// decodeNamedList decodes the list form, or the map form in the order of its
+// keys with entry.
+func decodeNamedList[T any](data []byte, entry func(name string, raw json.RawMessage) (T, error)) ([]T, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		var list []T
+		err := json.Unmarshal(data, &list)
+		return list, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	list := make([]T, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := token.(string)
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		e, err := entry(name, raw)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+func namedListString(raw json.RawMessage) (string, bool) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// ParseWorkflowDefinitions reads the YAML or JSON workflow definitions of a
+// document, YAML documents may hold several definitions separated by ---.
+func ParseWorkflowDefinitions(data []byte) ([]WorkflowDefinition, error) {
+	definitions, err := ParseDefinitions(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(definitions.Projects) > 0 || len(definitions.Triggers) > 0 {
+		return nil, fmt.Errorf("only workflow definitions are accepted")
+	}
+	return definitions.Workflows, nil
+}
+
+// LoadWorkflowDefinitions reads a definition file, or the .yaml, .yml and
+// .json files of a directory.
+func LoadWorkflowDefinitions(path string) ([]WorkflowDefinition, error) {
+	files, err := definitionFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	definitions := make([]WorkflowDefinition, 0)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading workflow definitions: %v", err)
+		}
+		d, err := ParseWorkflowDefinitions(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		definitions = append(definitions, d...)
+	}
+	return definitions, nil
+}
+
+func definitionFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading definitions: %v", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading definitions: %v", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// MarshalWorkflowDefinition encodes a definition as DefinitionFormatYAML or
+// DefinitionFormatJSON
+func MarshalWorkflowDefinition(definition WorkflowDefinition, format string) ([]byte, error) {
+	b, err := json.MarshalIndent(definition, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error when encoding workflow definition: %v", err)
+	}
+	switch format {
+	case DefinitionFormatJSON:
+		return append(b, '\n'), nil
+	case DefinitionFormatYAML, "":
+		// the JSON document keeps the field order, it is converted to the
+		// block style of YAML
+		var document yaml.Node
+		if err := yaml.Unmarshal(b, &document); err != nil {
+			return nil, fmt.Errorf("error when encoding workflow definition: %v", err)
+		}
+		resetNodeStyle(&document)
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&document); err != nil {
+			return nil, fmt.Errorf("error when encoding workflow definition: %v", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("error when encoding workflow definition: %v", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported workflow definition format %s", format)
+	}
+}
+
+func resetNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetNodeStyle(child)
+	}
+}
+
+func (d *WorkflowDefinition) validate() error {
+	if d.ID == "" {
+		return fmt.Errorf("workflow definition has no id")
+	}
+	for i := range d.Triggers {
+		trigger := &d.Triggers[i]
+		if trigger.ID == "" {
+			return fmt.Errorf("trigger %d of workflow %s has no id", i+1, d.ID)
+		}
+		if trigger.WorkflowID == "" {
+			trigger.WorkflowID = d.ID
+		} else if trigger.WorkflowID != d.ID {
+			return fmt.Errorf("trigger %s of workflow %s belongs to workflow %s", trigger.ID, d.ID, trigger.WorkflowID)
+		}
+	}
+	return nil
+}
+
+// ExportWorkflow returns the definition of a workflow with its triggers
+func (wm *WorkflowManager) ExportWorkflow(ctx context.Context, workflowID string) (WorkflowDefinition, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	workflow, ok := wm.workflows[workflowID]
+	if !ok {
+		return WorkflowDefinition{}, fmt.Errorf("workflow %s not found", workflowID)
+	}
+	definition := WorkflowDefinition{
+		CreateWorkflowInput: CreateWorkflowInput{
+			ID:                 workflow.ID,
+			Name:               workflow.Name,
+			ProjectID:          workflow.ProjectID,
+			Inputs:             workflow.Inputs,
+			Variables:          workflow.Variables,
+			Components:         workflow.Components,
+			Status:             workflow.Status,
+			Output:             workflow.Output,
+			ProvisionResources: workflow.ProvisionResources,
+		},
+	}
+	for _, t := range wm.triggers {
+		if t.input.WorkflowID == workflowID {
+			definition.Triggers = append(definition.Triggers, t.input)
+		}
+	}
+	sort.Slice(definition.Triggers, func(i, j int) bool {
+		return definition.Triggers[i].ID < definition.Triggers[j].ID
+	})
+	return definition, nil
+}
+
+type ImportWorkflowsOutput struct {
+	Workflows []string `json:"workflows"`
+	Triggers  []string `json:"triggers"`
+}
+
+// ImportWorkflows creates the workflows and the triggers of definitions, an
+// existing workflow with the same ID is replaced. Every definition is checked
+// before the first one is imported, so that an invalid definition leaves the
+// workflows unchanged.
+func (wm *WorkflowManager) ImportWorkflows(ctx context.Context, definitions []WorkflowDefinition) (ImportWorkflowsOutput, error) {
+	out := ImportWorkflowsOutput{
+		Workflows: make([]string, 0, len(definitions)),
+		Triggers:  make([]string, 0),
+	}
+	for i := range definitions {
+		if err := definitions[i].validate(); err != nil {
+			return out, err
+		}
+		if err := wm.validateWorkflow(ctx, &definitions[i].CreateWorkflowInput); err != nil {
+			return out, fmt.Errorf("error when importing workflow %s: %v", definitions[i].ID, err)
+		}
+	}
+	for i := range definitions {
+		definition := definitions[i]
+		if _, err := wm.CreateWorkflow(ctx, &definition.CreateWorkflowInput); err != nil {
+			return out, fmt.Errorf("error when importing workflow %s: %v", definition.ID, err)
+		}
+		out.Workflows = append(out.Workflows, definition.ID)
+		for j := range definition.Triggers {
+			if err := wm.CreateWorkflowTrigger(ctx, &definition.Triggers[j]); err != nil {
+				return out, fmt.Errorf("error when importing trigger %s: %v", definition.Triggers[j].ID, err)
+			}
+			out.Triggers = append(out.Triggers, definition.Triggers[j].ID)
+		}
+	}
+	return out, nil
+}
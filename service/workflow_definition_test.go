@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const backupWorkflow = `
+id: workflow_backup
+name: Backup Service
+input:
+  dir: /data
+  retention:
+    type: int
+    defaultValue: "7"
+variables:
+  listOfFiles:
+    type: listOfString
+steps:
+  - id: read
+    type: ReadFile
+    next: upload
+  - id: upload
+    type: S3:PutObject
+    input:
+      bucket: backups
+      storageClass: "true"
+status: active
+output:
+  objects: listOfString
+triggers:
+  - id: nightly
+    name: Nightly backup
+    type: scheduled
+    config:
+      cron: "0 2 * * *"
+---
+{"id": "workflow_restore", "input": [{"name": "dir", "type": "string"}]}
+`
+
+func TestParseWorkflowDefinitions(t *testing.T) {
+	definitions, err := ParseWorkflowDefinitions([]byte(backupWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(definitions) != 2 {
+		t.Fatalf("Expected 2 definitions, got %d", len(definitions))
+	}
+
+	backup := definitions[0]
+	wantInputs := WorkflowInputs{
+		{Name: "dir", Type: "string", DefaultValue: "/data"},
+		{Name: "retention", Type: "int", DefaultValue: "7"},
+	}
+	if !reflect.DeepEqual(backup.Inputs, wantInputs) {
+		t.Fatalf("Expected inputs %+v, got %+v", wantInputs, backup.Inputs)
+	}
+	if want := (Variables{{Name: "listOfFiles", Type: "listOfString"}}); !reflect.DeepEqual(backup.Variables, want) {
+		t.Fatalf("Expected variables %+v, got %+v", want, backup.Variables)
+	}
+	if want := (WorkflowOutputs{{Name: "objects", Type: "listOfString"}}); !reflect.DeepEqual(backup.Output, want) {
+		t.Fatalf("Expected outputs %+v, got %+v", want, backup.Output)
+	}
+	if options := componentOptions(backup.Components[1]); options["bucket"] != "backups" || options["storageClass"] != "true" {
+		t.Fatalf("Expected the step options, got %v", options)
+	}
+	if len(backup.Triggers) != 1 || backup.Triggers[0].WorkflowID != "workflow_backup" || backup.Triggers[0].Config["cron"] != "0 2 * * *" {
+		t.Fatalf("Expected the trigger of the workflow, got %+v", backup.Triggers)
+	}
+	if want := (WorkflowInputs{{Name: "dir", Type: "string"}}); !reflect.DeepEqual(definitions[1].Inputs, want) {
+		t.Fatalf("Expected inputs %+v, got %+v", want, definitions[1].Inputs)
+	}
+}
+
+func TestParseWorkflowDefinitionsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		err      string
+	}{
+		{name: "no id", document: "name: backup", err: "workflow definition has no id"},
+		{name: "unknown field", document: "id: backup\nstep: []", err: `unknown field "step"`},
+		{name: "other workflow", document: "id: backup\ntriggers:\n  - id: nightly\n    workflowId: restore", err: "trigger nightly of workflow backup belongs to workflow restore"},
+		{name: "empty", document: "", err: "no definition found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseWorkflowDefinitions([]byte(test.document))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestExportImportWorkflows(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "backup.yaml"), []byte(backupWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# workflows"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	definitions, err := LoadWorkflowDefinitions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wm := &WorkflowManager{}
+	out, err := wm.ImportWorkflows(context.Background(), definitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"workflow_backup", "workflow_restore"}; !reflect.DeepEqual(out.Workflows, want) {
+		t.Fatalf("Expected workflows %v, got %v", want, out.Workflows)
+	}
+
+	for _, format := range []string{DefinitionFormatYAML, DefinitionFormatJSON} {
+		exported, err := wm.ExportWorkflow(context.Background(), "workflow_backup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		document, err := MarshalWorkflowDefinition(exported, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseWorkflowDefinitions(document)
+		if err != nil {
+			t.Fatalf("Expected the %s export to be parsed, got %v\n%s", format, err, document)
+		}
+		if !reflect.DeepEqual(parsed[0], definitions[0]) {
+			t.Fatalf("Expected the %s export to match the definition, got %+v", format, parsed[0])
+		}
+	}
+
+	if _, err := wm.ExportWorkflow(context.Background(), "workflow_unknown"); err == nil {
+		t.Fatalf("Expected an unknown workflow to fail")
+	}
+}
+
+func TestImportWorkflowsInvalid(t *testing.T) {
+	definitions, err := ParseWorkflowDefinitions([]byte(backupWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := &ResourceManager{}
+	wm := &WorkflowManager{Projects: rm}
+	if _, err := wm.ImportWorkflows(context.Background(), definitions); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second definition is linked to an unknown project, the first one is
+	// not imported either
+	changed, err := ParseWorkflowDefinitions([]byte(strings.Replace(backupWorkflow, "name: Backup Service", "name: Changed", 1) +
+		"---\n" + `{"id": "workflow_archive", "projectId": "project-unknown"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wm.ImportWorkflows(context.Background(), changed); err == nil || !strings.Contains(err.Error(), "workflow_archive") {
+		t.Fatalf("Expected the unknown project to be rejected, got %v", err)
+	}
+	if workflow, err := wm.GetWorkflow(context.Background(), "workflow_backup"); err != nil || workflow.Name != "Backup Service" {
+		t.Fatalf("Expected workflow_backup to be unchanged, got %+v, %v", workflow, err)
+	}
+	if _, err := wm.GetWorkflow(context.Background(), "workflow_archive"); err == nil {
+		t.Fatalf("Expected workflow_archive not to be imported")
+	}
+
+	// a trigger of another workflow fails the import in the same way
+	changed[2] = WorkflowDefinition{
+		CreateWorkflowInput: CreateWorkflowInput{ID: "workflow_archive"},
+		Triggers:            []CreateWorkflowTriggerInput{{ID: "archive", WorkflowID: "workflow_backup"}},
+	}
+	if _, err := wm.ImportWorkflows(context.Background(), changed); err == nil {
+		t.Fatalf("Expected the trigger of another workflow to be rejected")
+	}
+	if workflow, _ := wm.GetWorkflow(context.Background(), "workflow_backup"); workflow.Name != "Backup Service" {
+		t.Fatalf("Expected workflow_backup to be unchanged, got %+v", workflow)
+	}
+}
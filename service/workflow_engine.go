@@ -1,18 +1,374 @@
 package service
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
 
-type WorkflowEngine struct{}
+const (
+	StepStatusStarted   = "started"
+	StepStatusSucceeded = "succeeded"
+	StepStatusFailed    = "failed"
+	// StepStatusInterrupted is the status of a step canceled by Shutdown or
+	// by the context of the run
+	StepStatusInterrupted = "interrupted"
+)
 
+// ErrRunInterrupted is returned by the runs stopped by Shutdown or by the
+// cancellation of their context
+var ErrRunInterrupted = errors.New("run interrupted")
+
+// runReference matches references to run inputs and workflow variables in the
+// step options, e.g. ${input.dir} or ${variables.prefix}
+var runReference = regexp.MustCompile(`\$\{(input|variables)\.([A-Za-z0-9_-]+)\}`)
+
+type WorkflowEngine struct {
+	// Workflows holds the definitions of the workflows run by the engine
+	Workflows *WorkflowManager
+	// Progress is called when a step starts and when it ends, it may be nil
+	Progress func(event StepEvent)
+
+	mu       sync.Mutex
+	stopping bool
+	// cancels interrupts the runs in progress
+	cancels map[*workflowRun]context.CancelFunc
+	running sync.WaitGroup
+	// runs are the runs started by StartWorkflow, by ID, and order their IDs
+	// from the oldest
+	runs  map[string]*workflowRun
+	order []string
+}
+
+// RunWorkflow runs the steps of a workflow from the first one, following their
+// next step. The files produced by a step, e.g. the files read by ReadFile or
+// the archive written by ZipFile, are the input files of the next step. When a
+// step fails, the first HandleError step of the workflow is run with its error
+// and the run fails.
 func (we *WorkflowEngine) RunWorkflow(ctx context.Context, input RunWorkflowInput) error {
+	if we.Workflows == nil {
+		return fmt.Errorf("no workflow manager is configured")
+	}
+	run := &workflowRun{
+		id:         time.Now().UTC().Format("20060102T150405.000Z"),
+		workflowID: input.ID,
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := we.start(run, cancel); err != nil {
+		return err
+	}
+	defer we.end(run)
+	return we.execute(ctx, run, input)
+}
+
+// execute runs the steps of a started run
+func (we *WorkflowEngine) execute(ctx context.Context, run *workflowRun, input RunWorkflowInput) error {
+	if err := we.Workflows.PrepareRun(ctx, input.ID); err != nil {
+		return err
+	}
+	workflow, ok := we.Workflows.workflow(input.ID)
+	if !ok {
+		return fmt.Errorf("workflow %s not found", input.ID)
+	}
+	inputs, err := runInputs(workflow, input.Input)
+	if err != nil {
+		return err
+	}
+	components, err := we.Workflows.createWorkflowComponents(ctx, input.ID, inputs)
+	if err != nil {
+		return err
+	}
+
+	steps, handler, err := runSteps(workflow, components)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if we.isStopping() {
+			return fmt.Errorf("%w before step %s", ErrRunInterrupted, step.ID)
+		}
+		if err := we.runStep(ctx, run, step, components[step.ID]); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w during step %s", ErrRunInterrupted, step.ID)
+			}
+			if handler != nil {
+				run.err = err
+				// the run fails with the error of the step, whatever the
+				// handler returns
+				we.runStep(ctx, run, *handler, components[handler.ID])
+			}
+			return fmt.Errorf("step %s failed: %v", step.ID, err)
+		}
+	}
 	return nil
 }
 
+// Shutdown stops starting runs and steps, and waits for the steps in progress
+// to end. When ctx is done first, the steps in progress are canceled and
+// reported as interrupted.
+func (we *WorkflowEngine) Shutdown(ctx context.Context) error {
+	we.mu.Lock()
+	we.stopping = true
+	we.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		we.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+	we.mu.Lock()
+	for _, cancel := range we.cancels {
+		cancel()
+	}
+	we.mu.Unlock()
+	<-drained
+	return ctx.Err()
+}
+
+func (we *WorkflowEngine) start(run *workflowRun, cancel context.CancelFunc) error {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	if we.stopping {
+		return fmt.Errorf("workflow %s not started: %w", run.workflowID, ErrRunInterrupted)
+	}
+	if we.cancels == nil {
+		we.cancels = make(map[*workflowRun]context.CancelFunc)
+	}
+	we.cancels[run] = cancel
+	we.running.Add(1)
+	return nil
+}
+
+func (we *WorkflowEngine) end(run *workflowRun) {
+	we.mu.Lock()
+	delete(we.cancels, run)
+	we.mu.Unlock()
+	we.running.Done()
+}
+
+func (we *WorkflowEngine) isStopping() bool {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	return we.stopping
+}
+
 type RunWorkflowInput struct {
 	ID    string                 `json:"workflowId"`
 	Input map[string]interface{} `json:"input"`
 }
 
+// StepEvent reports the progress of a step of a run
+type StepEvent struct {
+	RunID  string `json:"runId"`
+	StepID string `json:"stepId"`
+	Type   string `json:"type"`
+	// Status is StepStatusStarted, StepStatusSucceeded, StepStatusFailed or
+	// StepStatusInterrupted
+	Status string `json:"status"`
+	// Summary describes the output of a step which succeeded, e.g. 3 files
+	Summary string `json:"summary,omitempty"`
+	// Objects are the files uploaded by a step which succeeded, with the bytes
+	// transferred for each of them
+	Objects  []UploadedObject `json:"objects,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Duration time.Duration    `json:"duration"`
+}
+
+// workflowRun carries the outputs of a step to the next one
+type workflowRun struct {
+	id         string
+	workflowID string
+	files      []string
+	// err is the error of the failed step, given to HandleError
+	err error
+	// status and events are guarded by the lock of the engine
+	status WorkflowRun
+	events []StepEvent
+}
+
+func (we *WorkflowEngine) runStep(ctx context.Context, run *workflowRun, step ComponentInfo, c Component) error {
+	event := StepEvent{RunID: run.id, StepID: step.ID, Type: step.Type, Status: StepStatusStarted}
+	we.report(run, event)
+	start := time.Now()
+	summary, objects, err := run.do(ctx, c)
+	event.Duration = time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		event.Status, event.Error = StepStatusInterrupted, err.Error()
+	} else if err != nil {
+		event.Status, event.Error = StepStatusFailed, err.Error()
+	} else {
+		event.Status, event.Summary, event.Objects = StepStatusSucceeded, summary, objects
+	}
+	we.report(run, event)
+	return err
+}
+
+func (we *WorkflowEngine) report(run *workflowRun, event StepEvent) {
+	we.mu.Lock()
+	run.events = append(run.events, event)
+	we.mu.Unlock()
+	if we.Progress != nil {
+		we.Progress(event)
+	}
+}
+
+// do runs a step with the outputs of the previous one and returns a summary
+// of its output, and the objects it uploaded
+func (run *workflowRun) do(ctx context.Context, c Component) (string, []UploadedObject, error) {
+	var input interface{}
+	switch c := c.(type) {
+	case *ComponentReadFile:
+		if c.directory == "" {
+			return "", nil, fmt.Errorf("the directory option is required")
+		}
+		input = ReadFileInput{directory: c.directory}
+	case *ComponentZipFile:
+		zipFile := c.zipFile
+		if zipFile == "" {
+			format := c.archive.format
+			if format == "" {
+				format = ArchiveFormatZip
+			}
+			zipFile = filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s.%s", run.workflowID, run.id, format))
+		}
+		input = ZipFileInput{files: run.files, zipFile: zipFile}
+	case *ComponentPutObject:
+		input = PutObjectInput{files: run.files, runID: run.id}
+	case *ComponentZipPutObject:
+		input = ZipPutObjectInput{key: c.key, files: run.files, runID: run.id}
+	case *ComponentSyncObjects:
+		input = SyncObjectsInput{files: run.files, runID: run.id}
+	case *ComponentHandleError:
+		input = ErrorHandleInput{err: run.err}
+	default:
+		return "", nil, fmt.Errorf("unsupported component %T", c)
+	}
+
+	output, err := c.Do(ctx, input)
+	if err != nil {
+		return "", nil, err
+	}
+	switch out := output.(type) {
+	case ReadFileOutput:
+		run.files = out.files
+		return fmt.Sprintf("%d files", len(out.files)), nil, nil
+	case ZipFileOutput:
+		run.files = []string{out.zipFile}
+		return fmt.Sprintf("archive %s", out.zipFile), nil, nil
+	case PutObjectOutput:
+		return fmt.Sprintf("%d objects uploaded, %d bytes", len(out.objects), uploadedBytes(out.objects)), out.objects, nil
+	case ZipPutObjectOutput:
+		return fmt.Sprintf("archive uploaded to %s", out.key), nil, nil
+	case SyncObjectsOutput:
+		return fmt.Sprintf("%d added, %d changed, %d skipped, %d deleted, %d bytes uploaded", out.added, out.changed, out.skipped, out.deleted, uploadedBytes(out.objects)), out.objects, nil
+	default:
+		return "", nil, nil
+	}
+}
+
+// uploadedBytes returns the bytes transferred for objects
+func uploadedBytes(objects []UploadedObject) int64 {
+	var total int64
+	for _, object := range objects {
+		total += object.Bytes
+	}
+	return total
+}
+
+// runSteps returns the steps of a run in order, from the first step which is
+// not a HandleError step, and the first HandleError step if any
+func runSteps(workflow *Workflow, components map[string]Component) ([]ComponentInfo, *ComponentInfo, error) {
+	byID := make(map[string]ComponentInfo, len(workflow.Components))
+	var first string
+	var handler *ComponentInfo
+	for i, ci := range workflow.Components {
+		if _, ok := components[ci.ID]; !ok {
+			return nil, nil, fmt.Errorf("step %s has unsupported type %q", ci.ID, ci.Type)
+		}
+		byID[ci.ID] = ci
+		if ci.Type == "HandleError" {
+			if handler == nil {
+				handler = &workflow.Components[i]
+			}
+		} else if first == "" {
+			first = ci.ID
+		}
+	}
+
+	steps := make([]ComponentInfo, 0, len(workflow.Components))
+	visited := make(map[string]bool)
+	for id := first; id != ""; id = byID[id].Next {
+		ci, ok := byID[id]
+		if !ok {
+			return nil, nil, fmt.Errorf("next step %s not found", id)
+		}
+		if visited[id] {
+			return nil, nil, fmt.Errorf("step %s is part of a loop", id)
+		}
+		visited[id] = true
+		steps = append(steps, ci)
+	}
+	return steps, handler, nil
+}
+
+// runInputs returns the input values of a run, the default values of the
+// workflow inputs overridden by the given ones
+func runInputs(workflow *Workflow, given map[string]interface{}) (map[string]string, error) {
+	inputs := make(map[string]string, len(workflow.Inputs))
+	for _, in := range workflow.Inputs {
+		inputs[in.Name] = in.DefaultValue
+	}
+	for name, value := range given {
+		if _, ok := inputs[name]; !ok {
+			return nil, fmt.Errorf("workflow %s has no input %s", workflow.ID, name)
+		}
+		if s, ok := value.(string); ok {
+			inputs[name] = s
+		} else {
+			inputs[name] = fmt.Sprint(value)
+		}
+	}
+	return inputs, nil
+}
+
+// resolveRunReferences replaces the references to run inputs and workflow
+// variables in s
+func resolveRunReferences(s string, inputs map[string]string, variables map[string]string) (string, error) {
+	var err error
+	resolved := runReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		m := runReference.FindStringSubmatch(ref)
+		values := variables
+		if m[1] == "input" {
+			values = inputs
+		}
+		value, ok := values[m[2]]
+		if !ok {
+			err = fmt.Errorf("unknown %s %s", m[1], m[2])
+		} else if value == "" && m[1] == "input" {
+			err = fmt.Errorf("input %s has no value", m[2])
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
 type WorkflowTrigger struct {
 	ID         string                 `json:"id"`
 	Name       string                 `json:"name"`
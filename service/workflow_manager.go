@@ -1,36 +1,54 @@
 package service
 
 import (
-	"archive/zip"
 	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
+	"sync"
+	"time"
 )
 
 type WorkflowManager struct {
+	// mu guards workflows and triggers, the workflows are replaced rather than
+	// changed in place
+	mu        sync.RWMutex
 	workflows map[string]*Workflow
 	triggers  map[string]*Trigger
+	// Clients builds the AWS clients of the components, the AWS SDK defaults
+	// are used when nil
+	Clients ClientProvider
+	// Projects resolves the references to project resource outputs in the
+	// step options, e.g. ${resources.backupBucket.name}
+	Projects *ResourceManager
 }
 
 func (wm *WorkflowManager) CreateWorkflow(ctx context.Context, input *CreateWorkflowInput) (CreateWorkflowOutput, error) {
+	if err := wm.validateWorkflow(ctx, input); err != nil {
+		return CreateWorkflowOutput{}, err
+	}
+
 	workflow := &Workflow{
-		ID:         input.ID,
-		Name:       input.Name,
-		Status:     input.Status,
-		Components: input.Components,
-		Variables:  input.Variables,
+		ID:                 input.ID,
+		Name:               input.Name,
+		ProjectID:          input.ProjectID,
+		ProvisionResources: input.ProvisionResources,
+		Status:             input.Status,
+		Inputs:             input.Inputs,
+		Components:         input.Components,
+		Variables:          input.Variables,
+		Output:             input.Output,
 	}
+	wm.mu.Lock()
 	if wm.workflows == nil {
 		wm.workflows = make(map[string]*Workflow)
 	}
 	wm.workflows[input.ID] = workflow
+	wm.mu.Unlock()
 
 	out := CreateWorkflowOutput{
 		Metadata: make(map[string]ComponentMetadata),
@@ -47,20 +65,62 @@ func (wm *WorkflowManager) CreateWorkflow(ctx context.Context, input *CreateWork
 	return out, nil
 }
 
+// validateWorkflow checks a workflow before it is stored, the project it is
+// linked to must exist
+func (wm *WorkflowManager) validateWorkflow(ctx context.Context, input *CreateWorkflowInput) error {
+	if input.ProjectID != "" {
+		if wm.Projects == nil {
+			return fmt.Errorf("workflow %s cannot be linked to a project, no resource manager is configured", input.ID)
+		}
+		if _, err := wm.Projects.GetProject(ctx, input.ProjectID); err != nil {
+			return err
+		}
+	} else if input.ProvisionResources {
+		return fmt.Errorf("workflow %s provisions resources but is not linked to a project", input.ID)
+	}
+	// the options of the archive steps which do not reference run inputs,
+	// variables or resources are known, they are checked as when the steps
+	// are built by a run
+	for _, ci := range input.Components {
+		if ci.Type != "ZipFile" && ci.Type != "S3:ZipPutObject" {
+			continue
+		}
+		options := componentOptions(ci)
+		format, level, encryptionKey := options["format"], options["level"], options["encryptionKey"]
+		if hasRunReferences(format) || hasRunReferences(level) || hasRunReferences(encryptionKey) {
+			continue
+		}
+		if _, err := parseArchiveOptions(format, level, encryptionKey); err != nil {
+			return fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+		}
+	}
+	return nil
+}
+
+// hasRunReferences tells whether an option references run inputs, workflow
+// variables or resources, it is only known when a run builds the steps
+func hasRunReferences(value string) bool {
+	return runReference.MatchString(value) || hasReferences(value)
+}
+
 type CreateWorkflowInput struct {
-	ID         string           `json:"id"`
-	Name       string           `json:"name"`
-	ProjectID  string           `json:"projectId"`
-	Inputs     []WorkflowInput  `json:"input"`
-	Variables  []Variable       `json:"variables"`
-	Components []ComponentInfo  `json:"steps"`
-	Status     Status           `json:"status"`
-	Output     []WorkflowOutput `json:"output"`
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	ProjectID  string          `json:"projectId"`
+	Inputs     WorkflowInputs  `json:"input"`
+	Variables  Variables       `json:"variables"`
+	Components []ComponentInfo `json:"steps"`
+	Status     Status          `json:"status"`
+	Output     WorkflowOutputs `json:"output"`
+	// ProvisionResources makes sure the project resources are provisioned
+	// before each run
+	ProvisionResources bool `json:"provisionResources"`
 }
 
 type WorkflowInput struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	DefaultValue string `json:"defaultValue"`
 }
 
 type WorkflowOutput struct {
@@ -79,32 +139,116 @@ type CreateWorkflowOutput struct {
 }
 
 type ComponentInfo struct {
-	ID      string     `json:"id"`
-	Type    string     `json:"type"`
-	Inputs  []Variable `json:"input"`
-	Outputs []Variable `json:"output"`
-	Next    string     `json:"next"` // next component id
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Inputs  Variables `json:"input"`
+	Outputs Variables `json:"output"`
+	Next    string    `json:"next"` // next component id
 }
 
-func (wm *WorkflowManager) createWorkflowComponents(ctx context.Context, workflowID string) (map[string]Component, error) {
-	componentsInfo := wm.workflows[workflowID].Components
+// createWorkflowComponents builds the steps of a workflow, inputs are the
+// values of the ${input.<name>} references of a run
+func (wm *WorkflowManager) createWorkflowComponents(ctx context.Context, workflowID string, inputs map[string]string) (map[string]Component, error) {
+	workflow, ok := wm.workflow(workflowID)
+	if !ok {
+		return nil, fmt.Errorf("workflow %s not found", workflowID)
+	}
 	components := make(map[string]Component, 0)
-	for _, ci := range componentsInfo {
+	for _, info := range workflow.Components {
+		ci, err := wm.resolveComponentInfo(ctx, workflow, info, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid options of component %s: %v", info.ID, err)
+		}
 		switch ci.Type {
 		case "S3:PutObject":
+			options := componentOptions(ci)
+			upload, err := parseUploadOptions(options["partSizeMB"], options["concurrency"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
+			workers, err := parseWorkers(options["workers"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
+			object, err := parseObjectOptions(options)
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
 			components[ci.ID] = &ComponentPutObject{
-				next: ci.Next,
+				id:      ci.ID,
+				next:    ci.Next,
+				clients: wm.clients(),
+				bucket:  options["bucket"],
+				region:  options["region"],
+				upload:  upload,
+				workers: workers,
+				object:  object,
 			}
 		case "ReadFile":
+			options := componentOptions(ci)
+			filter, err := parseFileFilter(options)
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
 			components[ci.ID] = &ComponentReadFile{
-				next: ci.Next,
+				id:        ci.ID,
+				directory: options["directory"],
+				next:      ci.Next,
+				filter:    filter,
 			}
 		case "ZipFile":
+			options := componentOptions(ci)
+			archive, err := parseArchiveOptions(options["format"], options["level"], options["encryptionKey"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
 			components[ci.ID] = &ComponentZipFile{
-				next: ci.Next,
+				id:            ci.ID,
+				next:          ci.Next,
+				zipFile:       options["zipFile"],
+				archive:       archive,
+				encryptionKey: options["encryptionKey"],
+			}
+		case "S3:ZipPutObject":
+			options := componentOptions(ci)
+			archive, err := parseArchiveOptions(options["format"], options["level"], options["encryptionKey"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
+			upload, err := parseUploadOptions(options["partSizeMB"], options["concurrency"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
+			if upload, err = upload.withMaxSize(options["maxSizeGB"]); err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
 			}
+			object, err := parseObjectOptions(options)
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
+			// the key of the archive is not the path of a local file
+			object.baseDir = ""
+			components[ci.ID] = &ComponentZipPutObject{
+				id:            ci.ID,
+				next:          ci.Next,
+				clients:       wm.clients(),
+				bucket:        options["bucket"],
+				region:        options["region"],
+				key:           options["key"],
+				archive:       archive,
+				encryptionKey: options["encryptionKey"],
+				upload:        upload,
+				object:        object,
+			}
+		case "S3:Sync":
+			component, err := newComponentSyncObjects(ci, wm.clients())
+			if err != nil {
+				return nil, fmt.Errorf("invalid options of component %s: %v", ci.ID, err)
+			}
+			components[ci.ID] = component
 		case "HandleError":
 			components[ci.ID] = &ComponentHandleError{
+				id:   ci.ID,
 				next: ci.Next,
 			}
 		default:
@@ -113,7 +257,56 @@ func (wm *WorkflowManager) createWorkflowComponents(ctx context.Context, workflo
 	return components, nil
 }
 
+func (wm *WorkflowManager) clients() ClientProvider {
+	if wm.Clients == nil {
+		return defaultClientProvider
+	}
+	return wm.Clients
+}
+
+// resolveComponentInfo replaces the references to project resource outputs in
+// the step options.
+func (wm *WorkflowManager) resolveComponentInfo(ctx context.Context, workflow *Workflow, ci ComponentInfo, runInputs map[string]string) (ComponentInfo, error) {
+	lookup := func(resourceID string, output string) (string, error) {
+		if wm.Projects == nil || workflow.ProjectID == "" {
+			return "", fmt.Errorf("resource %s cannot be referenced outside of a project", resourceID)
+		}
+		return wm.Projects.ResourceOutput(ctx, workflow.ProjectID, resourceID, output)
+	}
+	variables := make(map[string]string, len(workflow.Variables))
+	for _, v := range workflow.Variables {
+		variables[v.Name] = v.DefaultValue
+	}
+
+	inputs := make([]Variable, len(ci.Inputs))
+	for i, v := range ci.Inputs {
+		value, err := resolveRunReferences(v.DefaultValue, runInputs, variables)
+		if err == nil {
+			value, err = resolveReferences(value, lookup)
+		}
+		if err != nil {
+			return ComponentInfo{}, fmt.Errorf("error when resolving option %s: %v", v.Name, err)
+		}
+		v.DefaultValue = value
+		inputs[i] = v
+	}
+	ci.Inputs = inputs
+	return ci, nil
+}
+
+// componentOptions returns the step options, which are given as the default
+// values of the component inputs.
+func componentOptions(ci ComponentInfo) map[string]string {
+	options := make(map[string]string, len(ci.Inputs))
+	for _, v := range ci.Inputs {
+		options[v.Name] = v.DefaultValue
+	}
+	return options
+}
+
 func (wm *WorkflowManager) ListWorkflows() (ListWorkflowsOutput, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
 	workflows := make([]Workflow, 0)
 	for _, workflow := range wm.workflows {
 		workflows = append(workflows, *workflow)
@@ -124,6 +317,67 @@ func (wm *WorkflowManager) ListWorkflows() (ListWorkflowsOutput, error) {
 	}, nil
 }
 
+// GetWorkflow returns a workflow by ID
+func (wm *WorkflowManager) GetWorkflow(ctx context.Context, workflowID string) (Workflow, error) {
+	workflow, ok := wm.workflow(workflowID)
+	if !ok {
+		return Workflow{}, fmt.Errorf("workflow %s not found", workflowID)
+	}
+	return *workflow, nil
+}
+
+// ListProjectWorkflows lists the workflows linked to a project
+func (wm *WorkflowManager) ListProjectWorkflows(ctx context.Context, projectID string) (ListWorkflowsOutput, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	workflows := make([]Workflow, 0)
+	for _, workflow := range wm.workflows {
+		if workflow.ProjectID == projectID {
+			workflows = append(workflows, *workflow)
+		}
+	}
+
+	return ListWorkflowsOutput{
+		Workflows: workflows,
+	}, nil
+}
+
+// PrepareRun is called before a workflow run, it provisions the resources of
+// the project when the workflow asks for it.
+func (wm *WorkflowManager) PrepareRun(ctx context.Context, workflowID string) error {
+	workflow, ok := wm.workflow(workflowID)
+	if !ok {
+		return fmt.Errorf("workflow %s not found", workflowID)
+	}
+	if !workflow.ProvisionResources {
+		return nil
+	}
+	if wm.Projects == nil {
+		return fmt.Errorf("resources of workflow %s cannot be provisioned, no resource manager is configured", workflowID)
+	}
+	_, err := wm.Projects.CreateProjectResources(ctx, &CreateProjectResourcesInput{ProjectID: workflow.ProjectID})
+	if err != nil {
+		return fmt.Errorf("error when provisioning resources of project %s: %v", workflow.ProjectID, err)
+	}
+	return nil
+}
+
+// DeleteWorkflow removes a workflow, which must not have triggers
+func (wm *WorkflowManager) DeleteWorkflow(ctx context.Context, workflowID string) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	if _, ok := wm.workflows[workflowID]; !ok {
+		return fmt.Errorf("workflow %s not found", workflowID)
+	}
+	for _, t := range wm.triggers {
+		if t.input.WorkflowID == workflowID {
+			return fmt.Errorf("workflow %s has trigger %s, delete its triggers first", workflowID, t.input.ID)
+		}
+	}
+	delete(wm.workflows, workflowID)
+	return nil
+}
+
 type ListWorkflowsOutput struct {
 	Workflows []Workflow `json:"workflows"`
 }
@@ -133,6 +387,8 @@ func (wm *WorkflowManager) CreateWorkflowTrigger(ctx context.Context, input *Cre
 		input: *input,
 	}
 
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
 	if wm.triggers == nil {
 		wm.triggers = make(map[string]*Trigger)
 	}
@@ -155,6 +411,8 @@ type Trigger struct {
 }
 
 func (wm *WorkflowManager) ListWorkflowTriggers() (ListWorkflowTriggersOutput, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
 	triggers := make([]CreateWorkflowTriggerInput, 0)
 	for _, t := range wm.triggers {
 		triggers = append(triggers, t.input)
@@ -165,6 +423,60 @@ func (wm *WorkflowManager) ListWorkflowTriggers() (ListWorkflowTriggersOutput, e
 	}, nil
 }
 
+// GetWorkflowTrigger returns a trigger by ID
+func (wm *WorkflowManager) GetWorkflowTrigger(ctx context.Context, triggerID string) (CreateWorkflowTriggerInput, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	t, ok := wm.triggers[triggerID]
+	if !ok {
+		return CreateWorkflowTriggerInput{}, fmt.Errorf("trigger %s not found", triggerID)
+	}
+	return t.input, nil
+}
+
+// PauseWorkflowTrigger deactivates a trigger and returns it, the trigger keeps
+// its definition
+func (wm *WorkflowManager) PauseWorkflowTrigger(ctx context.Context, triggerID string) (CreateWorkflowTriggerInput, error) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	t, ok := wm.triggers[triggerID]
+	if !ok {
+		return CreateWorkflowTriggerInput{}, fmt.Errorf("trigger %s not found", triggerID)
+	}
+	t.input.Status = StatusDeactive
+	return t.input, nil
+}
+
+// DeleteWorkflowTrigger removes a trigger
+func (wm *WorkflowManager) DeleteWorkflowTrigger(ctx context.Context, triggerID string) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	if _, ok := wm.triggers[triggerID]; !ok {
+		return fmt.Errorf("trigger %s not found", triggerID)
+	}
+	delete(wm.triggers, triggerID)
+	return nil
+}
+
+type PauseWorkflowTriggerInput struct {
+	ID string `json:"id"`
+}
+
+// workflow returns a workflow by ID, which must not be changed
+func (wm *WorkflowManager) workflow(workflowID string) (*Workflow, bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	workflow, ok := wm.workflows[workflowID]
+	return workflow, ok
+}
+
+// definitionIDs returns the IDs of the workflows and of the triggers, sorted
+func (wm *WorkflowManager) definitionIDs() ([]string, []string) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	return sortedDefinitionIDs(wm.workflows), sortedDefinitionIDs(wm.triggers)
+}
+
 type ListWorkflowTriggersOutput struct {
 	Triggers []CreateWorkflowTriggerInput `json:"triggers"`
 }
@@ -172,10 +484,16 @@ type ListWorkflowTriggersOutput struct {
 type Workflow struct {
 	ID         string          `json:"id"`
 	Name       string          `json:"name"`
+	ProjectID  string          `json:"projectId"`
 	Endpoint   string          `json:"endpoint"`
 	Status     Status          `json:"status"`
+	Inputs     []WorkflowInput `json:"input"`
 	Components []ComponentInfo // ID to Component
 	Variables  []Variable
+	Output     []WorkflowOutput `json:"output"`
+	// ProvisionResources makes sure the project resources are provisioned
+	// before each run
+	ProvisionResources bool `json:"provisionResources"`
 }
 
 type ComponentMetadata struct {
@@ -194,6 +512,7 @@ type ComponentReadFile struct {
 	id        string
 	directory string
 	next      string
+	filter    fileFilter
 }
 
 type ReadFileInput struct {
@@ -201,7 +520,8 @@ type ReadFileInput struct {
 }
 
 type ReadFileOutput struct {
-	files []string
+	files   []string
+	entries []FileEntry
 }
 
 func (c *ComponentReadFile) ID() string { return c.id }
@@ -215,18 +535,22 @@ func (c *ComponentReadFile) Do(ctx context.Context, input interface{}) (output i
 }
 
 func (c *ComponentReadFile) do(ctx context.Context, input ReadFileInput) (ReadFileOutput, error) {
-	files := make([]string, 0)
-	err := filepath.WalkDir(input.directory, func(path string, d fs.DirEntry, err error) error {
-		if !d.IsDir() {
-			files = append(files, path)
-		}
-		return nil
-	})
+	filter := c.filter
+	if filter.symlinks == "" {
+		filter = fileFilter{symlinks: SymlinkInclude, maxSize: -1}
+	}
+	entries, err := filter.walkFiles(ctx, input.directory)
 	if err != nil {
 		return ReadFileOutput{}, err
 	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, entry.path)
+	}
 	return ReadFileOutput{
-		files: files,
+		files:   files,
+		entries: entries,
 	}, nil
 }
 
@@ -235,6 +559,12 @@ type ComponentZipFile struct {
 	id    string
 	files []string
 	next  string
+	// zipFile is the path of the archive, a file of the temporary directory
+	// named after the run is used when empty
+	zipFile string
+	archive archiveOptions
+	// encryptionKey is a secret reference, see ResolveSecret
+	encryptionKey string
 }
 
 type ZipFileInput struct {
@@ -257,52 +587,30 @@ func (c *ComponentZipFile) Do(ctx context.Context, input interface{}) (output in
 }
 
 func (c *ComponentZipFile) do(ctx context.Context, input ZipFileInput) (output ZipFileOutput, err error) {
-	// Create a new zip archive.
-	zipFile, err := os.Create(input.zipFile)
-	if err != nil {
-		return ZipFileOutput{}, fmt.Errorf("error when creating zip file %s: %v", input.zipFile, err)
+	opts := c.archive
+	if opts.format == "" {
+		opts.format = ArchiveFormatZip
+		opts.level = defaultCompressionLevel
 	}
-	defer zipFile.Close()
-
-	// Create a new writer for the zip archive.
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	for _, file := range input.files {
-		var fileInfo os.FileInfo
-		var header *zip.FileHeader
-		var writer io.Writer
-		// Open the file to be added to the archive.
-		fileToZip, err := os.Open(file)
-		defer fileToZip.Close()
-		if err != nil {
-			return ZipFileOutput{}, fmt.Errorf("error when opening file %s: %v", file, err)
-		}
-
-		// Create a new file header for the file.
-		fileInfo, err = fileToZip.Stat()
+	if c.encryptionKey != "" {
+		opts.password, err = ResolveSecret(c.encryptionKey)
 		if err != nil {
-			return ZipFileOutput{}, fmt.Errorf("error when stating file %s: %v", file, err)
+			return ZipFileOutput{}, fmt.Errorf("failed to resolve encryption key: %v", err)
 		}
+	}
 
-		header, err = zip.FileInfoHeader(fileInfo)
-		if err != nil {
-			return ZipFileOutput{}, fmt.Errorf("error when building info header of file %s: %v", file, err)
-		}
-		// Set the file header name to the name of the file.
-		header.Name = file
-
-		// Add the file header to the zip archive.
-		writer, err = zipWriter.CreateHeader(header)
-		if err != nil {
-			return ZipFileOutput{}, fmt.Errorf("error when creating zip writer of file %s: %v", file, err)
-		}
+	// Create a new archive.
+	zipFile, err := os.Create(input.zipFile)
+	if err != nil {
+		return ZipFileOutput{}, fmt.Errorf("error when creating zip file %s: %v", input.zipFile, err)
+	}
 
-		// Write the file contents to the zip archive.
-		_, err = io.Copy(writer, fileToZip)
-		if err != nil {
-			return ZipFileOutput{}, fmt.Errorf("error when zipping content of file %s: %v", file, err)
-		}
+	if err := writeArchive(ctx, zipFile, input.files, opts); err != nil {
+		zipFile.Close()
+		return ZipFileOutput{}, err
+	}
+	if err := zipFile.Close(); err != nil {
+		return ZipFileOutput{}, fmt.Errorf("error when closing zip file %s: %v", input.zipFile, err)
 	}
 
 	return ZipFileOutput{
@@ -312,18 +620,38 @@ func (c *ComponentZipFile) do(ctx context.Context, input ZipFileInput) (output Z
 
 // s3 PutObject component
 type ComponentPutObject struct {
-	id     string
-	client s3.Client
-	next   string
+	id      string
+	clients ClientProvider
+	next    string
+	// bucket and region are used when the input does not name a bucket
+	bucket string
+	region string
+	upload uploadOptions
+	// workers is the number of files uploaded at the same time
+	workers int
+	object  objectOptions
 }
 
 type PutObjectInput struct {
 	bucket string
 	region string
 	files  []string
+	// runID identifies the workflow run in the object keys
+	runID string
+}
+
+type PutObjectOutput struct {
+	objects []UploadedObject
 }
 
-type PutObjectOutput struct{}
+// UploadedObject reports a file uploaded by ComponentPutObject or
+// ComponentSyncObjects
+type UploadedObject struct {
+	File string `json:"file"`
+	Key  string `json:"key"`
+	// Bytes is the size of the file transferred
+	Bytes int64 `json:"bytes"`
+}
 
 func (c *ComponentPutObject) ID() string { return c.id }
 
@@ -336,29 +664,185 @@ func (c *ComponentPutObject) Do(ctx context.Context, input interface{}) (output
 }
 
 func (c *ComponentPutObject) do(ctx context.Context, input PutObjectInput) (output PutObjectOutput, err error) {
-	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(input.region),
-	)
+	if input.bucket == "" {
+		input.bucket, input.region = c.bucket, c.region
+	}
+	client, err := c.clients.S3(ctx, input.region)
 	if err != nil {
-		return PutObjectOutput{}, fmt.Errorf("failed to load aws config: %v", err)
+		return PutObjectOutput{}, fmt.Errorf("failed to create s3 client: %v", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
-	for _, file := range input.files {
-		body, err := os.Open(file)
+	uploader := c.upload.newUploader(client)
+	// all objects of a run share the same date in their keys
+	now := time.Now()
+
+	objects := make([]UploadedObject, len(input.files))
+	err = forEachConcurrently(ctx, c.workers, len(input.files), func(ctx context.Context, i int) error {
+		key, err := c.object.key(input.files[i], input.runID, now)
 		if err != nil {
-			return PutObjectOutput{}, fmt.Errorf("error when opening file %s: %v", file, err)
+			return err
 		}
-		_, err = client.PutObject(ctx, &s3.PutObjectInput{
-			Bucket: aws.String(input.bucket),
-			Key:    aws.String(file),
-			Body:   body,
-		})
+		objects[i], err = putFile(ctx, uploader, c.object, input.bucket, input.files[i], key)
+		return err
+	})
+	if err != nil {
+		return PutObjectOutput{}, err
+	}
+	return PutObjectOutput{
+		objects: objects,
+	}, nil
+}
+
+// putFile uploads a single file, switching to a multipart upload when the file
+// is larger than the configured part size.
+func putFile(ctx context.Context, uploader *manager.Uploader, object objectOptions, bucket string, file string, key string) (UploadedObject, error) {
+	body, err := os.Open(file)
+	if err != nil {
+		return UploadedObject{}, fmt.Errorf("error when opening file %s: %v", file, err)
+	}
+	defer body.Close()
+
+	fileInfo, err := body.Stat()
+	if err != nil {
+		return UploadedObject{}, fmt.Errorf("error when stating file %s: %v", file, err)
+	}
+
+	contentType, err := detectContentType(body)
+	if err != nil {
+		return UploadedObject{}, fmt.Errorf("error when detecting content type of file %s: %v", file, err)
+	}
+
+	putInput := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	object.apply(putInput)
+	_, err = uploader.Upload(ctx, putInput)
+	if err != nil {
+		return UploadedObject{}, fmt.Errorf("error when put object %s: %v", file, err)
+	}
+	return UploadedObject{
+		File:  file,
+		Key:   key,
+		Bytes: fileInfo.Size(),
+	}, nil
+}
+
+// s3 streaming archive component, the archive is piped into a multipart upload
+// instead of being written to the local disk first. The size of the archive is
+// unknown when the upload starts, so it is bounded by the parts of the upload:
+// about 48 GiB with the default part size of 5 MiB. The maxSizeGB option raises
+// the part size to fit a larger archive, and a larger archive fails the step.
+type ComponentZipPutObject struct {
+	id      string
+	next    string
+	clients ClientProvider
+	// bucket and region are used when the input does not name a bucket
+	bucket string
+	region string
+	// key is the object key of the archive
+	key     string
+	archive archiveOptions
+	// encryptionKey is a secret reference, see ResolveSecret
+	encryptionKey string
+	upload        uploadOptions
+	// object is applied to the archive, its key template renders the key
+	// option as RelPath
+	object objectOptions
+}
+
+type ZipPutObjectInput struct {
+	bucket string
+	region string
+	key    string
+	files  []string
+	// runID identifies the workflow run in the object key
+	runID string
+}
+
+type ZipPutObjectOutput struct {
+	key      string
+	location string
+}
+
+func (c *ComponentZipPutObject) ID() string { return c.id }
+
+func (c *ComponentZipPutObject) Do(ctx context.Context, input interface{}) (output interface{}, err error) {
+	in, ok := input.(ZipPutObjectInput)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast ZipPutObjectInput")
+	}
+	return c.do(ctx, in)
+}
+
+func (c *ComponentZipPutObject) do(ctx context.Context, input ZipPutObjectInput) (output ZipPutObjectOutput, err error) {
+	if input.key == "" && c.object.keyTemplate == nil {
+		return ZipPutObjectOutput{}, fmt.Errorf("object key of the archive is required")
+	}
+	if input.bucket == "" {
+		input.bucket, input.region = c.bucket, c.region
+	}
+	key, err := c.object.key(input.key, input.runID, time.Now())
+	if err != nil {
+		return ZipPutObjectOutput{}, err
+	}
+
+	opts := c.archive
+	if opts.format == "" {
+		opts.format = ArchiveFormatZip
+		opts.level = defaultCompressionLevel
+	}
+	if c.encryptionKey != "" {
+		opts.password, err = ResolveSecret(c.encryptionKey)
 		if err != nil {
-			return PutObjectOutput{}, fmt.Errorf("error when put object %s: %v", file, err)
+			return ZipPutObjectOutput{}, fmt.Errorf("failed to resolve encryption key: %v", err)
+		}
+	}
+
+	client, err := c.clients.S3(ctx, input.region)
+	if err != nil {
+		return ZipPutObjectOutput{}, fmt.Errorf("failed to create s3 client: %v", err)
+	}
+	uploader := c.upload.newUploader(client)
+	limit := &sizeLimitReader{max: uploader.PartSize * int64(maxStreamingParts)}
+
+	// The archive writer blocks until the uploader has consumed the previous
+	// chunk, which bounds the memory to the parts buffered by the uploader.
+	reader, writer := io.Pipe()
+	limit.r = reader
+	archived := make(chan error, 1)
+	go func() {
+		err := writeArchive(ctx, writer, input.files, opts)
+		writer.CloseWithError(err)
+		archived <- err
+	}()
+
+	putInput := &s3.PutObjectInput{
+		Bucket: aws.String(input.bucket),
+		Key:    aws.String(key),
+		Body:   limit,
+	}
+	c.object.apply(putInput)
+	result, err := uploader.Upload(ctx, putInput)
+	if err != nil {
+		// unblock the archive writer before waiting for it
+		reader.CloseWithError(err)
+		<-archived
+		if limit.exceeded {
+			return ZipPutObjectOutput{}, fmt.Errorf("archive %s is larger than %d bytes, the size of %d parts of %d MiB, raise the maxSizeGB or partSizeMB option", key, limit.max, maxStreamingParts, uploader.PartSize/1024/1024)
 		}
+		return ZipPutObjectOutput{}, fmt.Errorf("error when uploading archive %s: %v", key, err)
 	}
-	return PutObjectOutput{}, nil
+	if err := <-archived; err != nil {
+		return ZipPutObjectOutput{}, err
+	}
+
+	return ZipPutObjectOutput{
+		key:      key,
+		location: result.Location,
+	}, nil
 }
 
 // error handler component
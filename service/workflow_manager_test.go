@@ -0,0 +1,786 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	aeszip "github.com/yeka/zip"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFakeBucket(t *testing.T, name string) *FakeClientProvider {
+	t.Helper()
+	clients := NewFakeClientProvider()
+	if _, err := clients.FakeS3().CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(name)}); err != nil {
+		t.Fatal(err)
+	}
+	return clients
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string][]byte) []string {
+	t.Helper()
+	paths := make([]string, 0, len(files))
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, file)
+	}
+	return paths
+}
+
+func TestPutObjectMultipart(t *testing.T) {
+	clients := newFakeBucket(t, "backup")
+	dir := t.TempDir()
+	large := bytes.Repeat([]byte("x"), 6*1024*1024)
+	files := writeTestFiles(t, dir, map[string][]byte{"large.bin": large, "small.txt": []byte("small")})
+
+	upload, err := parseUploadOptions("5", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, err := parseObjectOptions(map[string]string{"baseDir": dir, "prefix": "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ComponentPutObject{clients: clients, upload: upload, workers: 2, object: object}
+	out, err := c.do(context.Background(), PutObjectInput{bucket: "backup", files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, uploaded := range out.objects {
+		body, ok := clients.FakeS3().Object("backup", uploaded.Key)
+		if !ok {
+			t.Fatalf("Expected object %s to be uploaded", uploaded.Key)
+		}
+		if int64(len(body)) != uploaded.Bytes {
+			t.Fatalf("Expected %d bytes reported for %s, got %d", len(body), uploaded.Key, uploaded.Bytes)
+		}
+	}
+	if body, _ := clients.FakeS3().Object("backup", "nightly/large.bin"); !bytes.Equal(body, large) {
+		t.Fatalf("Expected nightly/large.bin to hold the large file")
+	}
+}
+
+func TestPutObjectConcurrent(t *testing.T) {
+	clients := newFakeBucket(t, "backup")
+	dir := t.TempDir()
+	contents := make(map[string][]byte)
+	for i := 0; i < 12; i++ {
+		contents[fmt.Sprintf("file-%02d.txt", i)] = []byte(fmt.Sprintf("content %d", i))
+	}
+	files := writeTestFiles(t, dir, contents)
+	object, err := parseObjectOptions(map[string]string{"baseDir": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ComponentPutObject{clients: clients, upload: uploadOptions{partSize: 5 * 1024 * 1024, concurrency: 1}, workers: 4, object: object}
+	out, err := c.do(context.Background(), PutObjectInput{bucket: "backup", files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.objects) != len(files) {
+		t.Fatalf("Expected %d uploaded objects, got %d", len(files), len(out.objects))
+	}
+	for name, content := range contents {
+		if body, _ := clients.FakeS3().Object("backup", name); !bytes.Equal(body, content) {
+			t.Fatalf("Expected %s to be uploaded, got %q", name, body)
+		}
+	}
+
+	missing := filepath.Join(dir, "missing.txt")
+	_, err = c.do(context.Background(), PutObjectInput{bucket: "backup", files: append(files, missing)})
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("Expected the missing file to be reported, got %v", err)
+	}
+}
+
+func TestZipPutObjectStreaming(t *testing.T) {
+	clients := newFakeBucket(t, "backup")
+	files := writeTestFiles(t, t.TempDir(), map[string][]byte{"a.log": []byte("a"), "b.log": []byte("b")})
+
+	archive, err := parseArchiveOptions("tar.gz", "9", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ComponentZipPutObject{clients: clients, archive: archive}
+	out, err := c.do(context.Background(), ZipPutObjectInput{bucket: "backup", key: "logs.tar.gz", files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, ok := clients.FakeS3().Object("backup", out.key)
+	if !ok {
+		t.Fatalf("Expected archive %s to be uploaded", out.key)
+	}
+	if got := readArchiveEntry(t, ArchiveFormatTarGz, body, files[0]); got != "a" && got != "b" {
+		t.Fatalf("Unexpected archive entry content %q", got)
+	}
+
+	if _, err := c.do(context.Background(), ZipPutObjectInput{bucket: "missing", key: "logs.tar.gz", files: files}); err == nil {
+		t.Fatalf("Expected uploading to a missing bucket to fail")
+	}
+}
+
+func TestZipPutObjectLayout(t *testing.T) {
+	clients := newFakeBucket(t, "backup")
+	files := writeTestFiles(t, t.TempDir(), map[string][]byte{"a.log": []byte("a")})
+
+	object, err := parseObjectOptions(map[string]string{
+		"prefix":       "archives",
+		"keyTemplate":  "{{.RunID}}/{{.Base}}",
+		"storageClass": "STANDARD_IA",
+		"metadata":     "source=nightly",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ComponentZipPutObject{clients: clients, object: object}
+	out, err := c.do(context.Background(), ZipPutObjectInput{bucket: "backup", key: "logs.zip", files: files, runID: "run-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.key != "archives/run-1/logs.zip" {
+		t.Fatalf("Expected the key template to be applied, got %s", out.key)
+	}
+	got, err := clients.FakeS3().GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("backup"), Key: aws.String(out.key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StorageClass != "STANDARD_IA" || got.Metadata["source"] != "nightly" {
+		t.Fatalf("Expected the object attributes to be applied, got %s and %v", got.StorageClass, got.Metadata)
+	}
+}
+
+func TestZipPutObjectSizeLimit(t *testing.T) {
+	defer func(parts int32) { maxStreamingParts = parts }(maxStreamingParts)
+	if upload, err := (uploadOptions{}).withMaxSize("100"); err != nil || upload.partSize != 11*1024*1024 {
+		t.Fatalf("Expected 100 GiB to need parts of 11 MiB, got %d: %v", upload.partSize, err)
+	}
+	if upload, err := (uploadOptions{}).withMaxSize("1"); err != nil || upload.partSize != 5*1024*1024 {
+		t.Fatalf("Expected 1 GiB to keep the default part size, got %d: %v", upload.partSize, err)
+	}
+	if _, err := (uploadOptions{}).withMaxSize("0"); err == nil {
+		t.Fatalf("Expected a zero maximum size to be rejected")
+	}
+
+	// random data does not compress, so the archive needs several parts
+	maxStreamingParts = 1
+	clients := newFakeBucket(t, "backup")
+	large := make([]byte, 6*1024*1024)
+	rand.New(rand.NewSource(1)).Read(large)
+	files := writeTestFiles(t, t.TempDir(), map[string][]byte{"large.bin": large})
+	c := &ComponentZipPutObject{clients: clients}
+	_, err := c.do(context.Background(), ZipPutObjectInput{bucket: "backup", key: "large.zip", files: files})
+	if err == nil || !strings.Contains(err.Error(), "raise the maxSizeGB or partSizeMB option") {
+		t.Fatalf("Expected the archive to exceed the parts of the upload, got %v", err)
+	}
+	if _, ok := clients.FakeS3().Object("backup", "large.zip"); ok {
+		t.Fatalf("Expected no archive to be uploaded")
+	}
+}
+
+func TestZipPutObjectEncryptedMultipart(t *testing.T) {
+	// the archive must not be written to the temporary directory
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv("TEST_GOLDEN_ARCHIVE_KEY", "s3cr3t")
+	clients := newFakeBucket(t, "backup")
+	// random data does not compress, so the archive needs several parts
+	large := make([]byte, 12*1024*1024)
+	rand.New(rand.NewSource(1)).Read(large)
+	files := writeTestFiles(t, t.TempDir(), map[string][]byte{"large.bin": large})
+
+	upload, err := parseUploadOptions("5", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ComponentZipPutObject{clients: clients, upload: upload, encryptionKey: "env:TEST_GOLDEN_ARCHIVE_KEY"}
+	if _, err := c.do(context.Background(), ZipPutObjectInput{bucket: "backup", key: "large.zip", files: files}); err != nil {
+		t.Fatal(err)
+	}
+	body, ok := clients.FakeS3().Object("backup", "large.zip")
+	if !ok || int64(len(body)) <= upload.partSize {
+		t.Fatalf("Expected an archive larger than a part, got %d bytes", len(body))
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("Expected no temporary file, got %d", len(entries))
+	}
+
+	r, err := aeszip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.File[0].SetPassword("s3cr3t")
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, large) {
+		t.Fatalf("Expected the decrypted entry to hold the large file")
+	}
+
+	c.encryptionKey = "env:TEST_GOLDEN_UNSET_KEY"
+	if _, err := c.do(context.Background(), ZipPutObjectInput{bucket: "backup", key: "large.zip", files: files}); err == nil {
+		t.Fatalf("Expected an unset encryption key to fail")
+	}
+}
+
+func TestSyncObjects(t *testing.T) {
+	for _, source := range []string{SyncSourceManifest, SyncSourceBucket} {
+		clients := newFakeBucket(t, "backup")
+		dir := t.TempDir()
+		files := writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b")})
+
+		c, err := newComponentSyncObjects(ComponentInfo{Inputs: []Variable{
+			{Name: "baseDir", DefaultValue: dir},
+			{Name: "compare", DefaultValue: SyncCompareHash},
+			{Name: "source", DefaultValue: source},
+			{Name: "delete", DefaultValue: "true"},
+			{Name: "prefix", DefaultValue: "sync"},
+		}}, clients)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.added != 2 || out.changed != 0 || out.skipped != 0 || out.deleted != 0 {
+			t.Fatalf("%s: unexpected first sync %+v", source, out)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = writeTestFiles(t, dir, map[string][]byte{"c.txt": []byte("c")})
+		files = append(files, filepath.Join(dir, "a.txt"))
+		out, err = c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.added != 1 || out.changed != 1 || out.skipped != 0 || out.deleted != 1 {
+			t.Fatalf("%s: unexpected second sync %+v", source, out)
+		}
+		if _, ok := clients.FakeS3().Object("backup", "sync/b.txt"); ok {
+			t.Fatalf("%s: expected b.txt to be deleted", source)
+		}
+
+		out, err = c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.skipped != 2 || len(out.objects) != 0 {
+			t.Fatalf("%s: expected unchanged files to be skipped, got %+v", source, out)
+		}
+	}
+}
+
+func TestSyncObjectsChecksum(t *testing.T) {
+	for _, source := range []string{SyncSourceManifest, SyncSourceBucket} {
+		clients := newFakeBucket(t, "backup")
+		dir := t.TempDir()
+		// the large file is uploaded in parts, neither ETag is the MD5 of the content
+		files := writeTestFiles(t, dir, map[string][]byte{
+			"small.txt": []byte("small"),
+			"large.bin": bytes.Repeat([]byte("x"), 6<<20),
+		})
+
+		c, err := newComponentSyncObjects(ComponentInfo{ID: "sync", Inputs: []Variable{
+			{Name: "baseDir", DefaultValue: dir},
+			{Name: "compare", DefaultValue: SyncCompareHash},
+			{Name: "source", DefaultValue: source},
+			{Name: "prefix", DefaultValue: "sync"},
+			{Name: "sseKmsKeyId", DefaultValue: "alias/backup"},
+			{Name: "partSizeMB", DefaultValue: "5"},
+		}}, clients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.ID() != "sync" {
+			t.Fatalf("Expected the component ID sync, got %q", c.ID())
+		}
+
+		out, err := c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.added != 2 {
+			t.Fatalf("%s: unexpected first sync %+v", source, out)
+		}
+
+		out, err = c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.skipped != 2 || len(out.objects) != 0 {
+			t.Fatalf("%s: expected the encrypted and multipart objects to be skipped, got %+v", source, out)
+		}
+
+		// a change which keeps the size is detected by the checksum
+		if err := os.WriteFile(filepath.Join(dir, "small.txt"), []byte("SMALL"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err = c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.changed != 1 || out.skipped != 1 {
+			t.Fatalf("%s: expected small.txt to be changed, got %+v", source, out)
+		}
+	}
+}
+
+func TestSyncObjectsDeleteOutsidePrefix(t *testing.T) {
+	clients := newFakeBucket(t, "backup")
+	options := []Variable{
+		{Name: "source", DefaultValue: SyncSourceBucket},
+		{Name: "delete", DefaultValue: "true"},
+	}
+	if _, err := newComponentSyncObjects(ComponentInfo{Inputs: options}, clients); err == nil || !strings.Contains(err.Error(), "needs a prefix") {
+		t.Fatalf("Expected deleting without a prefix to be rejected, got %v", err)
+	}
+
+	// the objects outside of the prefix are kept
+	if _, err := clients.FakeS3().PutObject(context.Background(), &s3.PutObjectInput{Bucket: aws.String("backup"), Key: aws.String("other/data.txt"), Body: strings.NewReader("data")}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a")})
+	options = append(options, Variable{Name: "prefix", DefaultValue: "sync"}, Variable{Name: "baseDir", DefaultValue: dir})
+	c, err := newComponentSyncObjects(ComponentInfo{Inputs: options}, clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.do(context.Background(), SyncObjectsInput{bucket: "backup", files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.added != 1 || out.deleted != 0 {
+		t.Fatalf("Expected a single upload, got %+v", out)
+	}
+	if _, ok := clients.FakeS3().Object("backup", "other/data.txt"); !ok {
+		t.Fatalf("Expected the object outside of the prefix to be kept")
+	}
+}
+
+func TestResourceReferencesInStepOptions(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID:        "project-001",
+		Resources: []Resource{{ID: "backupBucket", Type: "S3:Bucket", Properties: map[string]string{"BucketName": "backup"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a")})
+	wm := &WorkflowManager{Clients: clients, Projects: rm}
+	_, err = wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{
+		ID:        "workflow-001",
+		ProjectID: "project-001",
+		Components: []ComponentInfo{{
+			ID:   "upload",
+			Type: "S3:PutObject",
+			Inputs: []Variable{
+				{Name: "bucket", DefaultValue: "${resources.backupBucket.name}"},
+				{Name: "baseDir", DefaultValue: dir},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// outputs are only available once the resources are provisioned
+	if _, err := wm.createWorkflowComponents(context.Background(), "workflow-001", nil); err == nil {
+		t.Fatalf("Expected reference to a pending resource to fail")
+	}
+	if _, err := rm.CreateProjectResources(context.Background(), &CreateProjectResourcesInput{ProjectID: "project-001"}); err != nil {
+		t.Fatal(err)
+	}
+	components, err := wm.createWorkflowComponents(context.Background(), "workflow-001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := components["upload"].(*ComponentPutObject)
+	if _, err := c.do(context.Background(), PutObjectInput{files: files}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clients.FakeS3().Object("backup", "a.txt"); !ok {
+		t.Fatalf("Expected a.txt to be uploaded to the referenced bucket")
+	}
+}
+
+func TestWorkflowProjectLink(t *testing.T) {
+	clients := NewFakeClientProvider()
+	rm := &ResourceManager{Clients: clients}
+	wm := &WorkflowManager{Clients: clients, Projects: rm}
+	err := rm.CreateProject(context.Background(), &CreateProjectInput{
+		ID:        "project-001",
+		Resources: []Resource{{Type: "S3:Bucket", Properties: map[string]string{"BucketName": "backup"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{ID: "workflow-001", ProjectID: "project-002"}); err == nil {
+		t.Fatalf("Expected a workflow of an unknown project to be rejected")
+	}
+	if _, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{ID: "workflow-001", ProvisionResources: true}); err == nil {
+		t.Fatalf("Expected provisioning without a project to be rejected")
+	}
+	for _, input := range []*CreateWorkflowInput{
+		{ID: "workflow-001", ProjectID: "project-001", ProvisionResources: true},
+		{ID: "workflow-002"},
+	} {
+		if _, err := wm.CreateWorkflow(context.Background(), input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := wm.ListProjectWorkflows(context.Background(), "project-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Workflows) != 1 || out.Workflows[0].ID != "workflow-001" {
+		t.Fatalf("Expected workflow-001 to be listed, got %+v", out.Workflows)
+	}
+
+	engine := &WorkflowEngine{Workflows: wm}
+	if err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clients.s3.buckets["backup"]; !ok {
+		t.Fatalf("Expected bucket backup to be provisioned before the run")
+	}
+	if err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-003"}); err == nil {
+		t.Fatalf("Expected run of an unknown workflow to fail")
+	}
+}
+
+func TestCreateWorkflowArchiveEncryption(t *testing.T) {
+	wm := &WorkflowManager{Clients: NewFakeClientProvider()}
+	for _, stepType := range []string{"ZipFile", "S3:ZipPutObject"} {
+		_, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{
+			ID: "workflow-001",
+			Components: []ComponentInfo{{ID: "archive", Type: stepType, Inputs: []Variable{
+				{Name: "format", DefaultValue: "tar.gz"},
+				{Name: "encryptionKey", DefaultValue: "env:BACKUP_KEY"},
+			}}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "encryption is not supported") {
+			t.Fatalf("%s: expected an encrypted tar archive to be rejected, got %v", stepType, err)
+		}
+	}
+
+	// a format given by the run is checked when the run builds the steps
+	_, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{
+		ID:     "workflow-002",
+		Inputs: WorkflowInputs{{Name: "format"}},
+		Components: []ComponentInfo{{ID: "archive", Type: "ZipFile", Inputs: []Variable{
+			{Name: "format", DefaultValue: "${input.format}"},
+			{Name: "encryptionKey", DefaultValue: "env:BACKUP_KEY"},
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := &WorkflowEngine{Workflows: wm}
+	err = engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-002", Input: map[string]interface{}{"format": "tar"}})
+	if err == nil || !strings.Contains(err.Error(), "encryption is not supported") {
+		t.Fatalf("Expected the run to reject an encrypted tar archive, got %v", err)
+	}
+}
+
+func TestRunWorkflow(t *testing.T) {
+	clients := newFakeBucket(t, "backup")
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a"), "sub/b.txt": []byte("b")})
+
+	wm := &WorkflowManager{Clients: clients}
+	_, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{
+		ID:     "workflow-001",
+		Inputs: []WorkflowInput{{Name: "dir"}, {Name: "bucket", DefaultValue: "backup"}},
+		Components: []ComponentInfo{
+			{ID: "notify", Type: "HandleError"},
+			{ID: "read", Type: "ReadFile", Next: "upload", Inputs: []Variable{{Name: "directory", DefaultValue: "${input.dir}"}}},
+			{ID: "upload", Type: "S3:PutObject", Inputs: []Variable{
+				{Name: "bucket", DefaultValue: "${input.bucket}"},
+				{Name: "baseDir", DefaultValue: "${input.dir}"},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []StepEvent
+	engine := &WorkflowEngine{Workflows: wm, Progress: func(e StepEvent) { events = append(events, e) }}
+	if err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"}); err == nil {
+		t.Fatalf("Expected a run without the dir input to fail")
+	}
+	if err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001", Input: map[string]interface{}{"path": dir}}); err == nil {
+		t.Fatalf("Expected an unknown input to be rejected")
+	}
+	if err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001", Input: map[string]interface{}{"dir": dir}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clients.FakeS3().Object("backup", "sub/b.txt"); !ok {
+		t.Fatalf("Expected sub/b.txt to be uploaded")
+	}
+	want := []string{"read started", "read succeeded", "upload started", "upload succeeded"}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), events)
+	}
+	for i, e := range events {
+		if got := e.StepID + " " + e.Status; got != want[i] {
+			t.Fatalf("Expected event %q, got %q", want[i], got)
+		}
+	}
+	if events[1].Summary != "2 files" || events[3].Summary != "2 objects uploaded, 2 bytes" {
+		t.Fatalf("Unexpected summaries %q and %q", events[1].Summary, events[3].Summary)
+	}
+	uploaded := make(map[string]UploadedObject)
+	for _, object := range events[3].Objects {
+		uploaded[object.Key] = object
+	}
+	if len(uploaded) != 2 || uploaded["a.txt"].Bytes != 1 || uploaded["sub/b.txt"].File != filepath.Join(dir, "sub", "b.txt") || uploaded["sub/b.txt"].Bytes != 1 {
+		t.Fatalf("Expected the bytes of each uploaded file, got %+v", events[3].Objects)
+	}
+
+	// a failed step runs the error handler
+	events = nil
+	err = engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001", Input: map[string]interface{}{"dir": dir, "bucket": "missing"}})
+	if err == nil {
+		t.Fatalf("Expected the upload to a missing bucket to fail")
+	}
+	last := events[len(events)-1]
+	if events[3].Status != StepStatusFailed || last.StepID != "notify" || last.Status != StepStatusSucceeded {
+		t.Fatalf("Expected the failed upload to be handled, got %+v", events)
+	}
+}
+
+func TestRunWorkflowSteps(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []ComponentInfo
+		want  string
+	}{
+		{"loop", []ComponentInfo{{ID: "a", Type: "HandleError", Next: "b"}, {ID: "b", Type: "ReadFile", Next: "c"}, {ID: "c", Type: "ReadFile", Next: "b"}}, "step b is part of a loop"},
+		{"missing next", []ComponentInfo{{ID: "a", Type: "ReadFile", Next: "z"}}, "next step z not found"},
+		{"unsupported", []ComponentInfo{{ID: "a", Type: "Lambda:Invoke"}}, `step a has unsupported type "Lambda:Invoke"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wm := &WorkflowManager{}
+			if _, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{ID: "workflow-001", Components: test.steps}); err != nil {
+				t.Fatal(err)
+			}
+			engine := &WorkflowEngine{Workflows: wm}
+			err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"})
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("Expected %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+// blockingS3 blocks the uploads until they are canceled
+type blockingS3 struct {
+	*FakeS3
+	started chan struct{}
+}
+
+func (s blockingS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type blockingClients struct {
+	*FakeClientProvider
+	s3 blockingS3
+}
+
+func (p blockingClients) S3(ctx context.Context, region string) (S3API, error) {
+	return p.s3, nil
+}
+
+func TestWorkflowEngineShutdown(t *testing.T) {
+	fake := newFakeBucket(t, "backup")
+	clients := blockingClients{FakeClientProvider: fake, s3: blockingS3{FakeS3: fake.FakeS3(), started: make(chan struct{})}}
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a")})
+
+	wm := &WorkflowManager{Clients: clients}
+	_, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{
+		ID: "workflow-001",
+		Components: []ComponentInfo{
+			{ID: "read", Type: "ReadFile", Next: "upload", Inputs: []Variable{{Name: "directory", DefaultValue: dir}}},
+			{ID: "upload", Type: "S3:PutObject", Inputs: []Variable{{Name: "bucket", DefaultValue: "backup"}, {Name: "baseDir", DefaultValue: dir}}},
+			{ID: "notify", Type: "HandleError"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan StepEvent, 10)
+	engine := &WorkflowEngine{Workflows: wm, Progress: func(e StepEvent) { events <- e }}
+	done := make(chan error, 1)
+	go func() { done <- engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"}) }()
+	<-clients.s3.started
+
+	// the upload does not end within the grace period, so it is interrupted
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the grace period to expire, got %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrRunInterrupted) {
+		t.Fatalf("Expected the run to be interrupted, got %v", err)
+	}
+	close(events)
+	var last StepEvent
+	for e := range events {
+		last = e
+	}
+	if last.StepID != "upload" || last.Status != StepStatusInterrupted {
+		t.Fatalf("Expected the upload to be reported as interrupted without running the error handler, got %+v", last)
+	}
+
+	if err := engine.RunWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"}); !errors.Is(err, ErrRunInterrupted) {
+		t.Fatalf("Expected runs to be refused after the shutdown, got %v", err)
+	}
+}
+
+func TestStartWorkflow(t *testing.T) {
+	fake := newFakeBucket(t, "backup")
+	clients := blockingClients{FakeClientProvider: fake, s3: blockingS3{FakeS3: fake.FakeS3(), started: make(chan struct{})}}
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"a.txt": []byte("a")})
+
+	wm := &WorkflowManager{Clients: clients}
+	_, err := wm.CreateWorkflow(context.Background(), &CreateWorkflowInput{
+		ID: "workflow-001",
+		Components: []ComponentInfo{
+			{ID: "read", Type: "ReadFile", Next: "upload", Inputs: []Variable{{Name: "directory", DefaultValue: dir}}},
+			{ID: "upload", Type: "S3:PutObject", Inputs: []Variable{{Name: "bucket", DefaultValue: "backup"}, {Name: "baseDir", DefaultValue: dir}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &WorkflowEngine{Workflows: wm}
+	if _, err := engine.StartWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-002"}); err == nil {
+		t.Fatalf("Expected a run of an unknown workflow to be rejected")
+	}
+	run, err := engine.StartWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID == "" || run.WorkflowID != "workflow-001" || run.Status != RunStatusRunning {
+		t.Fatalf("Expected a running run, got %+v", run)
+	}
+	<-clients.s3.started
+	events, err := engine.RunLogs(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[2].StepID != "upload" || events[2].Status != StepStatusStarted {
+		t.Fatalf("Expected the upload to be in progress, got %+v", events)
+	}
+
+	if _, err := engine.CancelRun(run.ID); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for run.Status == RunStatusRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		if run, err = engine.GetRun(run.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if run.Status != RunStatusInterrupted || run.EndedAt == nil {
+		t.Fatalf("Expected the canceled run to be interrupted, got %+v", run)
+	}
+	if _, err := engine.CancelRun(run.ID); err == nil {
+		t.Fatalf("Expected an ended run not to be canceled")
+	}
+	if _, err := engine.GetRun("unknown"); err == nil {
+		t.Fatalf("Expected an unknown run not to be found")
+	}
+}
+
+func TestPauseWorkflowTrigger(t *testing.T) {
+	wm := &WorkflowManager{}
+	if err := wm.CreateWorkflowTrigger(context.Background(), &CreateWorkflowTriggerInput{ID: "nightly", WorkflowID: "workflow-001", Status: StatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	trigger, err := wm.PauseWorkflowTrigger(context.Background(), "nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger.Status != StatusDeactive {
+		t.Fatalf("Expected the trigger to be deactivated, got %s", trigger.Status)
+	}
+	if trigger, _ = wm.GetWorkflowTrigger(context.Background(), "nightly"); trigger.Status != StatusDeactive {
+		t.Fatalf("Expected the pause to be kept, got %s", trigger.Status)
+	}
+	if _, err := wm.PauseWorkflowTrigger(context.Background(), "weekly"); err == nil {
+		t.Fatalf("Expected an unknown trigger not to be paused")
+	}
+}
+
+func TestStartWorkflowConcurrentChanges(t *testing.T) {
+	dir := t.TempDir()
+	wm := &WorkflowManager{}
+	workflow := &CreateWorkflowInput{
+		ID:         "workflow-001",
+		Components: []ComponentInfo{{ID: "read", Type: "ReadFile", Inputs: []Variable{{Name: "directory", DefaultValue: dir}}}},
+	}
+	if _, err := wm.CreateWorkflow(context.Background(), workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	// the definitions change while the runs read them
+	engine := &WorkflowEngine{Workflows: wm}
+	for i := 0; i < 10; i++ {
+		if _, err := engine.StartWorkflow(context.Background(), RunWorkflowInput{ID: "workflow-001"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wm.CreateWorkflow(context.Background(), workflow); err != nil {
+			t.Fatal(err)
+		}
+		if err := wm.CreateWorkflowTrigger(context.Background(), &CreateWorkflowTriggerInput{ID: fmt.Sprintf("trigger-%d", i), WorkflowID: "workflow-001"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The statuses of a workflow run
+const (
+	RunStatusRunning   = "running"
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+	// RunStatusInterrupted is the status of a run canceled by CancelRun or
+	// stopped by Shutdown
+	RunStatusInterrupted = "interrupted"
+)
+
+// maxKeptRuns is the number of runs kept by an engine, the oldest ended runs
+// are forgotten first
+const maxKeptRuns = 1000
+
+// WorkflowRun is the status of a run started by StartWorkflow
+type WorkflowRun struct {
+	ID         string     `json:"id"`
+	WorkflowID string     `json:"workflowId"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	EndedAt    *time.Time `json:"endedAt,omitempty"`
+}
+
+// StartWorkflow starts a run of a workflow in the background, see RunWorkflow,
+// and returns its status. The run is canceled with ctx, its status and the
+// events of its steps are kept by the engine, see GetRun and RunLogs.
+func (we *WorkflowEngine) StartWorkflow(ctx context.Context, input RunWorkflowInput) (WorkflowRun, error) {
+	if we.Workflows == nil {
+		return WorkflowRun{}, fmt.Errorf("no workflow manager is configured")
+	}
+	if _, err := we.Workflows.GetWorkflow(ctx, input.ID); err != nil {
+		return WorkflowRun{}, err
+	}
+	now := time.Now().UTC()
+	run := &workflowRun{
+		id:         now.Format("20060102T150405.000Z"),
+		workflowID: input.ID,
+	}
+	run.status = WorkflowRun{WorkflowID: input.ID, Status: RunStatusRunning, StartedAt: now}
+	ctx, cancel := context.WithCancel(ctx)
+	if err := we.start(run, cancel); err != nil {
+		cancel()
+		return WorkflowRun{}, err
+	}
+	status := we.keep(run)
+
+	go func() {
+		defer cancel()
+		defer we.end(run)
+		err := we.execute(ctx, run, input)
+
+		we.mu.Lock()
+		defer we.mu.Unlock()
+		ended := time.Now().UTC()
+		run.status.EndedAt = &ended
+		switch {
+		case err == nil:
+			run.status.Status = RunStatusSucceeded
+		case errors.Is(err, ErrRunInterrupted) || ctx.Err() != nil:
+			run.status.Status, run.status.Error = RunStatusInterrupted, err.Error()
+		default:
+			run.status.Status, run.status.Error = RunStatusFailed, err.Error()
+		}
+	}()
+	return status, nil
+}
+
+// GetRun returns the status of a run started by StartWorkflow
+func (we *WorkflowEngine) GetRun(runID string) (WorkflowRun, error) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	run, ok := we.runs[runID]
+	if !ok {
+		return WorkflowRun{}, fmt.Errorf("run %s not found", runID)
+	}
+	return run.status, nil
+}
+
+// RunLogs returns the events of the steps of a run started by StartWorkflow,
+// oldest first
+func (we *WorkflowEngine) RunLogs(runID string) ([]StepEvent, error) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	run, ok := we.runs[runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s not found", runID)
+	}
+	return append(make([]StepEvent, 0, len(run.events)), run.events...), nil
+}
+
+// CancelRun interrupts a run started by StartWorkflow, the step in progress is
+// canceled and the run ends as interrupted
+func (we *WorkflowEngine) CancelRun(runID string) (WorkflowRun, error) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	run, ok := we.runs[runID]
+	if !ok {
+		return WorkflowRun{}, fmt.Errorf("run %s not found", runID)
+	}
+	cancel, ok := we.cancels[run]
+	if !ok {
+		return WorkflowRun{}, fmt.Errorf("run %s is not running", runID)
+	}
+	cancel()
+	return run.status, nil
+}
+
+// keep records a started run under a unique ID, and forgets the oldest ended
+// runs beyond maxKeptRuns
+func (we *WorkflowEngine) keep(run *workflowRun) WorkflowRun {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	if we.runs == nil {
+		we.runs = make(map[string]*workflowRun)
+	}
+	id := run.id
+	for n := 2; we.runs[id] != nil; n++ {
+		id = fmt.Sprintf("%s-%d", run.id, n)
+	}
+	run.id, run.status.ID = id, id
+	we.runs[id] = run
+	we.order = append(we.order, id)
+
+	kept := we.order[:0]
+	excess := len(we.order) - maxKeptRuns
+	for _, id := range we.order {
+		if excess > 0 && we.runs[id].status.EndedAt != nil {
+			delete(we.runs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	we.order = kept
+	return run.status
+}
+
+type CancelRunInput struct {
+	RunID string `json:"runId"`
+}